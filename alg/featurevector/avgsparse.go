@@ -176,8 +176,8 @@ type AvgSparse struct {
 }
 
 func (v *AvgSparse) Value(transition int, feature interface{}) int64 {
-	transitions, exists := v.Vals[feature]
-	if exists && transition < transitions.Len() {
+	// GetValue bounds the transition, Len of a sparse store is not its range
+	if transitions, exists := v.Vals[feature]; exists {
 		if histValue := transitions.GetValue(transition); histValue != nil {
 			return histValue.Value
 		}
@@ -339,6 +339,67 @@ func (v *AvgSparse) Deserialize(serialized interface{}, generation int) {
 	}
 }
 
+// HistoryState is the state of a HistoryValue, for checkpoints
+type HistoryState struct {
+	Generation, PrevGeneration int
+	Value, Total               int64
+}
+
+// State returns the weights with their averaging history, unlike Serialize
+func (v *AvgSparse) State() map[interface{}]map[int]HistoryState {
+	v.RLock()
+	defer v.RUnlock()
+	retval := make(map[interface{}]map[int]HistoryState, len(v.Vals))
+	for k, store := range v.Vals {
+		states := make(map[int]HistoryState, store.Len())
+		store.Each(func(i int, value *HistoryValue) {
+			if value != nil {
+				states[i] = HistoryState{value.Generation, value.PrevGeneration, value.Value, value.Total}
+			}
+		})
+		retval[k] = states
+	}
+	return retval
+}
+
+// SetState sets the weights and their averaging history to a State
+func (v *AvgSparse) SetState(state map[interface{}]map[int]HistoryState) {
+	v.Vals = make(map[Feature]TransitionScoreStore, len(state))
+	for k, states := range state {
+		size := len(states)
+		if v.Dense {
+			size = 0
+			for i := range states {
+				if i >= size {
+					size = i + 1
+				}
+			}
+		}
+		scoreStore := v.newTransitionScoreStore(size)
+		for i, s := range states {
+			scoreStore.SetValue(i, &HistoryValue{Generation: s.Generation, PrevGeneration: s.PrevGeneration, Value: s.Value, Total: s.Total})
+		}
+		v.Vals[k] = scoreStore
+	}
+}
+
+// CopyValues returns a copy of the weights, without their averaging history
+func (v *AvgSparse) CopyValues() *AvgSparse {
+	v.RLock()
+	defer v.RUnlock()
+	retval := MakeAvgSparse(v.Dense)
+	for k, store := range v.Vals {
+		scoreStore := v.newTransitionScoreStore(store.Len())
+		store.Each(func(i int, value *HistoryValue) {
+			if value != nil {
+				scoreStore.SetValue(i, NewHistoryValue(0, value.Value))
+			}
+		})
+		retval.Vals[k] = scoreStore
+	}
+	return retval
+}
+
 func (v *AvgSparse) newTransitionScoreStore(size int) TransitionScoreStore {
 	if v.Dense {
 		return &LockedArray{Vals: make([]*HistoryValue, size)}
@@ -0,0 +1,101 @@
+package perceptron
+
+import (
+	"log"
+	"sync"
+)
+
+// Mixing weights of the models trained in parallel (see Mixer)
+const (
+	MIX_UNIFORM = "uniform"
+	MIX_ERRORS  = "errors"
+)
+
+var MixingWeights = []string{MIX_UNIFORM, MIX_ERRORS}
+
+// Mixer mixes models trained in parallel on shards of the training
+// instances into a shared model
+type Mixer interface {
+	// Local returns a copy of the weights of m, to train on a shard
+	Local(m Model) Model
+	// Mix sets the weights of m to the weighted sum of those of locals
+	Mix(m Model, locals []Model, weights []float64)
+}
+
+// trainParallel trains iteration i with iterative parameter mixing
+// (McDonald et al., 2010): the instances are split among m.Workers shards,
+// each trained on in parallel by a local copy of the model, and the local
+// models are mixed into the model, uniformly or weighted by their number
+// of errors; the model is averaged over the mixed weights
+func (m *LinearPerceptron) trainParallel(i int, goldInstances []DecodedInstance, order []int) {
+	shards := make([][]int, m.Workers)
+	for j := range goldInstances {
+		instance := j
+		if order != nil {
+			instance = order[j]
+		}
+		shards[j%m.Workers] = append(shards[j%m.Workers], instance)
+	}
+	var (
+		locals  = make([]Model, m.Workers)
+		updates = make([]int, m.Workers)
+		trained = make([]int, m.Workers)
+		failed  = make([]int, m.Workers)
+		// gold decoding shares the transition system's oracle
+		goldMutex sync.Mutex
+		wg        sync.WaitGroup
+	)
+	for w := range shards {
+		locals[w] = m.Mixer.Local(m.Model)
+		wg.Add(1)
+		go func(w int, decoder EarlyUpdateInstanceDecoder) {
+			defer wg.Done()
+			for _, instance := range shards[w] {
+				goldMutex.Lock()
+				goldDecoded, _ := m.GoldDecoder.DecodeGold(goldInstances[instance], locals[w])
+				goldMutex.Unlock()
+				if goldDecoded == nil && i == 0 {
+					if m.Log {
+						log.Println("At instance", instance, "skipped (decode)")
+					}
+					failed[w]++
+					continue
+				}
+				decoded, erred := m.learn(instance, goldDecoded, locals[w], decoder)
+				if !decoded {
+					failed[w]++
+					continue
+				}
+				trained[w]++
+				if erred {
+					updates[w]++
+				}
+			}
+		}(w, m.NewDecoder())
+	}
+	wg.Wait()
+
+	var totalUpdates, totalTrained int
+	for w := range shards {
+		totalUpdates += updates[w]
+		totalTrained += trained[w]
+		m.FailedInstances += failed[w]
+	}
+	weights := make([]float64, m.Workers)
+	for w := range weights {
+		if m.Mixing == MIX_ERRORS && totalUpdates > 0 {
+			weights[w] = float64(updates[w]) / float64(totalUpdates)
+		} else {
+			weights[w] = 1.0 / float64(m.Workers)
+		}
+	}
+	m.Mixer.Mix(m.Model, locals, weights)
+	if m.Log {
+		log.Println("Mixed", m.Workers, "models with", m.Mixing, "weights, errors by shard", updates)
+	}
+	// the mixed weights are averaged once for every instance trained on
+	for j := 0; j < totalTrained; j++ {
+		m.Generations += 1
+		m.Updater.Update(m.Model)
+	}
+}
@@ -0,0 +1,124 @@
+package perceptron
+
+import (
+	"math"
+	"testing"
+)
+
+// testMixer mixes testModels, recording the mixing weights
+type testMixer struct {
+	Weights [][]float64
+}
+
+var _ Mixer = &testMixer{}
+
+func (p *testMixer) Local(m Model) Model {
+	return m.Copy()
+}
+
+func (p *testMixer) Mix(m Model, locals []Model, weights []float64) {
+	p.Weights = append(p.Weights, weights)
+	mixed := make(map[string]float64)
+	for l, local := range locals {
+		for f, w := range local.(*testModel).Weights {
+			mixed[f] += weights[l] * float64(w)
+		}
+	}
+	t := m.(*testModel)
+	t.Weights = make(map[string]int64, len(mixed))
+	for f, w := range mixed {
+		t.Weights[f] = int64(math.Round(w))
+	}
+}
+
+func newTestPerceptron(iterations, workers int, mixing string, step int64) *LinearPerceptron {
+	m := &LinearPerceptron{
+		Decoder:     &testDecoder{},
+		GoldDecoder: &testDecoder{},
+		Updater:     &testUpdater{},
+		Iterations:  iterations,
+		Learner:     &PerceptronLearner{Step: step},
+		Workers:     workers,
+		NewDecoder:  func() EarlyUpdateInstanceDecoder { return &testDecoder{} },
+		Mixer:       &testMixer{},
+		Mixing:      mixing,
+	}
+	m.Init(newTestModel())
+	return m
+}
+
+func testInstances(labels string, features ...[]string) []DecodedInstance {
+	instances := make([]DecodedInstance, len(labels))
+	for i := range instances {
+		instance := &testInstance{i, labels[i : i+1], features[i]}
+		instances[i] = &Decoded{instance, instance}
+	}
+	return instances
+}
+
+func TestTrainParallelOneWorker(t *testing.T) {
+	instances := testInstances("BBAB", []string{"x", "y"}, []string{"x"}, []string{"y", "z"}, []string{"z"})
+	sequential := newTestPerceptron(3, 1, MIX_UNIFORM, 0)
+	sequential.Train(instances)
+
+	// a single shard, mixed with weight 1, is trained on as sequentially
+	parallel := newTestPerceptron(3, 1, MIX_UNIFORM, 0)
+	for i := 0; i < parallel.Iterations; i++ {
+		parallel.trainParallel(i, instances, nil)
+	}
+	expected, weights := sequential.Model.(*testModel).Weights, parallel.Model.(*testModel).Weights
+	if len(weights) != len(expected) {
+		t.Errorf("weights %v, expected %v", weights, expected)
+	}
+	for f, w := range expected {
+		if weights[f] != w {
+			t.Errorf("weight of %v is %v, expected %v", f, weights[f], w)
+		}
+	}
+	if parallel.Generations != sequential.Generations {
+		t.Errorf("%v generations, expected %v", parallel.Generations, sequential.Generations)
+	}
+	if updates := parallel.Updater.(*testUpdater).Updates; updates != 3*len(instances) {
+		t.Errorf("%v averaging updates, expected %v", updates, 3*len(instances))
+	}
+}
+
+func TestTrainParallelMixing(t *testing.T) {
+	// shards of instances 0, 2 and 1, 3; all but instance 3 are errors of
+	// the empty model, which decodes "A"
+	instances := testInstances("BBBA", []string{"p"}, []string{"q"}, []string{"r"}, []string{"s"})
+	for _, test := range []struct {
+		Mixing   string
+		Weights  []float64
+		Expected map[string]int64
+	}{
+		{MIX_UNIFORM, []float64{0.5, 0.5}, map[string]int64{
+			"p|B": 5000, "p|A": -5000, "q|B": 5000, "q|A": -5000, "r|B": 5000, "r|A": -5000,
+		}},
+		{MIX_ERRORS, []float64{2.0 / 3, 1.0 / 3}, map[string]int64{
+			"p|B": 6667, "p|A": -6667, "q|B": 3333, "q|A": -3333, "r|B": 6667, "r|A": -6667,
+		}},
+	} {
+		m := newTestPerceptron(1, 2, test.Mixing, 10000)
+		m.trainParallel(0, instances, nil)
+		mixer := m.Mixer.(*testMixer)
+		if len(mixer.Weights) != 1 || len(mixer.Weights[0]) != len(test.Weights) {
+			t.Fatalf("%v: mixed with weights %v, expected %v", test.Mixing, mixer.Weights, test.Weights)
+		}
+		for w, weight := range test.Weights {
+			if math.Abs(mixer.Weights[0][w]-weight) > 1e-9 {
+				t.Errorf("%v: mixing weight %v is %v, expected %v", test.Mixing, w, mixer.Weights[0][w], weight)
+			}
+		}
+		weights := m.Model.(*testModel).Weights
+		for f, w := range test.Expected {
+			if weights[f] != w {
+				t.Errorf("%v: weight of %v is %v, expected %v", test.Mixing, f, weights[f], w)
+			}
+		}
+		// the mixed model is averaged once for every instance trained on
+		if updates := m.Updater.(*testUpdater).Updates; updates != len(instances) || m.Generations != len(instances) {
+			t.Errorf("%v: %v averaging updates and %v generations, expected %v", test.Mixing, updates, m.Generations, len(instances))
+		}
+	}
+}
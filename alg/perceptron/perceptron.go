@@ -7,6 +7,7 @@ import (
 	"fmt"
 	// "io"
 	"log"
+	"math/rand"
 
 // "os"
 )
@@ -25,8 +26,32 @@ type LinearPerceptron struct {
 	TempLines      int
 
 	FailedInstances int
+	// number of instances trained on (model updates) so far
+	Generations int
+
+	// Seed, if not 0, seeds the shuffling of the training instances every
+	// iteration; if 0 they are trained on in the order given
+	Seed int64
 
 	Continue StopCondition
+
+	// Learner updates the model on errors, with a fixed step perceptron
+	// update if nil
+	Learner Learner
+
+	// Workers, if more than 1, train each iteration in parallel with
+	// iterative parameter mixing (see trainParallel), each with a decoder
+	// from NewDecoder; their models are mixed by Mixer with Mixing weights
+	Workers    int
+	NewDecoder func() EarlyUpdateInstanceDecoder
+	Mixer      Mixer
+	Mixing     string
+
+	// Checkpoint, if set, is called every CheckpointEvery instances (if
+	// positive) and at the end of every iteration, with TrainI and TrainJ
+	// set to the position training would resume from (see Resume)
+	Checkpoint      func(m *LinearPerceptron)
+	CheckpointEvery int
 }
 
 var _ SupervisedTrainer = &LinearPerceptron{}
@@ -42,7 +67,21 @@ var PercepAllOut bool = false
 func (m *LinearPerceptron) Init(newModel Model) {
 	m.Model = newModel
 	m.TrainI, m.TrainJ = 0, -1
+	m.Generations = 0
 	m.Updater.Init(m.Model, m.Iterations)
+	if m.Learner == nil {
+		m.Learner = &PerceptronLearner{}
+	}
+	m.Learner.Init(m.Model)
+}
+
+// Resume sets the position to continue training from, after instance
+// trainJ of iteration trainI (or from the start of iteration trainI if
+// trainJ is -1); the model and updater are restored by the caller
+func (m *LinearPerceptron) Resume(trainI, trainJ, generations, failedInstances int) {
+	m.TrainI, m.TrainJ = trainI, trainJ
+	m.Generations = generations
+	m.FailedInstances = failedInstances
 }
 
 func DefaultStopCondition(iteration, iterations, generations int, model Model) bool {
@@ -58,8 +97,7 @@ func (m *LinearPerceptron) Train(goldInstances []DecodedInstance) {
 
 func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyUpdateInstanceDecoder, iterations int) {
 	var (
-		generations int
-		logPrefix   string
+		logPrefix string
 	)
 	if m.Model == nil {
 		panic("Model not initialized")
@@ -68,7 +106,18 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 	prevFlags := log.Flags()
 	// prevGC := debug.SetGCPercent(-1)
 	// var score int64
-	for i := m.TrainI; m.Continue(i, iterations, generations, m.Model); i++ {
+	// an iteration resumed in its middle was already evaluated
+	resumed := m.TrainJ >= 0
+	var shuffler *rand.Rand
+	if m.Seed != 0 {
+		shuffler = rand.New(rand.NewSource(m.Seed))
+		// replay the shuffles of the iterations before the one resumed from
+		for i := 0; i < m.TrainI; i++ {
+			shuffler.Perm(len(goldInstances))
+		}
+	}
+	for i := m.TrainI; resumed || m.Continue(i, iterations, m.Generations, m.Model); i++ {
+		resumed = false
 		logPrefix = "IT #" + fmt.Sprintf("%v ", i) + prevPrefix
 		log.SetPrefix(logPrefix)
 		// log.Println("Starting iteration", i)
@@ -76,117 +125,69 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 			log.SetPrefix("")
 			log.SetFlags(0)
 		}
-		for j, goldInstance := range goldInstances[m.TrainJ+1:] {
-			// if m.Log {
-			// 	if j%100 == 0 {
-			// 		runtime.GC()
-			// 	}
-			// }
-			// log.Println("At goldinstance", j)
-			log.SetPrefix(logPrefix + fmt.Sprintf("sent %v ", j))
-			goldDecoded, _ := m.GoldDecoder.DecodeGold(goldInstance, m.Model)
-			log.SetPrefix(logPrefix)
-			if goldDecoded == nil && i == 0 {
-				if m.Log {
-					log.Println("At instance", j, "skipped (decode)")
-
-				}
-				m.FailedInstances++
-				continue
-			}
-			decodedInstance, decodedFeatures, goldFeatures, earlyUpdatedAt, goldSize, score := decoder.DecodeEarlyUpdate(goldDecoded, m.Model)
-			if decodedInstance == nil {
-				if m.Log {
-					log.Println("At instance", j, "skipped (parse)")
+		var order []int
+		if shuffler != nil {
+			order = shuffler.Perm(len(goldInstances))
+		}
+		if m.Workers > 1 {
+			m.trainParallel(i, goldInstances, order)
+		} else {
+			start := m.TrainJ + 1
+			for j := start; j < len(goldInstances); j++ {
+				instance := j
+				if order != nil {
+					instance = order[j]
 				}
-				m.FailedInstances++
-				continue
-			}
-			if !goldDecoded.Equal(decodedInstance) {
-				if m.Log {
-					// if PercepAllOut {
-					// score = m.Model.Score(decodedFeatures)
-					// }
-					if earlyUpdatedAt >= 0 {
-						if PercepAllOut {
-							log.Printf("Error at %d of %d ; score %v\n", earlyUpdatedAt, goldSize, score)
-						} else {
-							log.Println("At instance", j, "failed", earlyUpdatedAt, "of", goldSize)
-						}
-					} else {
-						if PercepAllOut {
-							log.Printf("Error at %d of %d ; score %v\n", goldSize-1, goldSize, score)
-						} else {
-							log.Println("At instance", j, "failed", goldSize, "of", goldSize)
-						}
+				goldInstance := goldInstances[instance]
+				// if m.Log {
+				// 	if j%100 == 0 {
+				// 		runtime.GC()
+				// 	}
+				// }
+				// log.Println("At goldinstance", j)
+				log.SetPrefix(logPrefix + fmt.Sprintf("sent %v ", instance))
+				goldDecoded, _ := m.GoldDecoder.DecodeGold(goldInstance, m.Model)
+				log.SetPrefix(logPrefix)
+				if goldDecoded == nil && i == 0 {
+					if m.Log {
+						log.Println("At instance", instance, "skipped (decode)")
+
 					}
-					// log.Println("Decoded did not equal gold, updating")
-					// log.Println("Decoded:")
-					// log.Println(decodedInstance.Decoded())
-					// log.Println("Gold:")
-					// log.Println(goldDecoded.Decoded())
-					// if goldFeatures != nil {
-					// 	log.Println("Add Gold:", goldFeatures, "features")
-					// } else {
-					// 	panic("Decode failed but got nil gold model")
-					// }
-					// if decodedFeatures != nil {
-					// 	log.Println("Sub Pred:", decodedFeatures, "features")
-					// } else {
-					// 	panic("Decode failed but got nil decode model")
-					// }
+					m.FailedInstances++
+					continue
 				}
-				if PercepAllOut {
-					log.Println("Score 1 to")
-				}
-				m.Model.AddSubtract(goldFeatures, decodedFeatures, 1.0)
-				if PercepAllOut {
-					log.Println("Score -1 to")
+				if decoded, _ := m.learn(instance, goldDecoded, m.Model, decoder); !decoded {
+					m.FailedInstances++
+					continue
 				}
-				m.Model.AddSubtract(decodedFeatures, decodedFeatures, -1.0)
-				if PercepAllOut {
-					log.Println("ITERATION COMPLETE")
+				m.Generations += 1
+				m.Updater.Update(m.Model)
+				if m.Checkpoint != nil && m.CheckpointEvery > 0 && (j+1)%m.CheckpointEvery == 0 && j < len(goldInstances)-1 {
+					m.TrainI, m.TrainJ = i, j
+					m.Checkpoint(m)
 				}
-
-				// if m.Log {
-				// 	log.Println("After Model Update:")
-				// 	log.Println("\n", m.Model)
-				// }
-				// log.Println()
-
-				// log.Println("Model after:")
-				// for k, v := range *m.Model {
-				// 	log.Println(k, v)
+				// if m.TempLines > 0 && j > 0 && j%m.TempLines == 0 {
+				// 	// m.TrainJ = j
+				// 	// m.TrainI = i
+				// 	// if m.Log {
+				// 	// 	log.Println("Dumping at iteration", i, "after sent", j)
+				// 	// }
+				// 	// m.TempDump(m.Tempfile)
+				// 	if m.Log && !PercepAllOut {
+				// 		log.Println("\tBefore GC")
+				// 		util.LogMemory()
+				// 		log.Println("\tRunning GC")
+				// 	}
+				// 	debug.SetGCPercent(prevGC)
+				// 	runtime.GC()
+				// 	prevGC = debug.SetGCPercent(-1)
+				// 	if m.Log && !PercepAllOut {
+				// 		log.Println("\tAfter GC")
+				// 		util.LogMemory()
+				// 		log.Println("\tDone GC")
+				// 	}
 				// }
-				// log.Println()
-			} else {
-				if m.Log && !PercepAllOut {
-					log.Println("At instance", j, "success")
-				}
 			}
-			generations += 1
-			m.Updater.Update(m.Model)
-			// if m.TempLines > 0 && j > 0 && j%m.TempLines == 0 {
-			// 	// m.TrainJ = j
-			// 	// m.TrainI = i
-			// 	// if m.Log {
-			// 	// 	log.Println("Dumping at iteration", i, "after sent", j)
-			// 	// }
-			// 	// m.TempDump(m.Tempfile)
-			// 	if m.Log && !PercepAllOut {
-			// 		log.Println("\tBefore GC")
-			// 		util.LogMemory()
-			// 		log.Println("\tRunning GC")
-			// 	}
-			// 	debug.SetGCPercent(prevGC)
-			// 	runtime.GC()
-			// 	prevGC = debug.SetGCPercent(-1)
-			// 	if m.Log && !PercepAllOut {
-			// 		log.Println("\tAfter GC")
-			// 		util.LogMemory()
-			// 		log.Println("\tDone GC")
-			// 	}
-			// }
 		}
 
 		// if m.Log {
@@ -202,6 +203,10 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 		// }
 
 		// log.Println("Ending iteration", i)
+		m.TrainI, m.TrainJ = i+1, -1
+		if m.Checkpoint != nil {
+			m.Checkpoint(m)
+		}
 	}
 	log.SetPrefix(prevPrefix)
 	log.SetFlags(prevFlags)
@@ -209,6 +214,75 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 	// debug.SetGCPercent(prevGC)
 }
 
+// learn decodes an instance with decoder, updating model with the Learner
+// if it was decoded wrongly; decoded is false if decoding failed, erred if
+// the model was updated
+func (m *LinearPerceptron) learn(instance int, goldDecoded DecodedInstance, model Model, decoder EarlyUpdateInstanceDecoder) (decoded, erred bool) {
+	decodedInstance, decodedFeatures, goldFeatures, earlyUpdatedAt, goldSize, score := decoder.DecodeEarlyUpdate(goldDecoded, model)
+	if decodedInstance == nil {
+		if m.Log {
+			log.Println("At instance", instance, "skipped (parse)")
+		}
+		return false, false
+	}
+	if !goldDecoded.Equal(decodedInstance) {
+		if m.Log {
+			// if PercepAllOut {
+			// score = m.Model.Score(decodedFeatures)
+			// }
+			if earlyUpdatedAt >= 0 {
+				if PercepAllOut {
+					log.Printf("Error at %d of %d ; score %v\n", earlyUpdatedAt, goldSize, score)
+				} else {
+					log.Println("At instance", instance, "failed", earlyUpdatedAt, "of", goldSize)
+				}
+			} else {
+				if PercepAllOut {
+					log.Printf("Error at %d of %d ; score %v\n", goldSize-1, goldSize, score)
+				} else {
+					log.Println("At instance", instance, "failed", goldSize, "of", goldSize)
+				}
+			}
+			// log.Println("Decoded did not equal gold, updating")
+			// log.Println("Decoded:")
+			// log.Println(decodedInstance.Decoded())
+			// log.Println("Gold:")
+			// log.Println(goldDecoded.Decoded())
+			// if goldFeatures != nil {
+			// 	log.Println("Add Gold:", goldFeatures, "features")
+			// } else {
+			// 	panic("Decode failed but got nil gold model")
+			// }
+			// if decodedFeatures != nil {
+			// 	log.Println("Sub Pred:", decodedFeatures, "features")
+			// } else {
+			// 	panic("Decode failed but got nil decode model")
+			// }
+		}
+		m.Learner.Learn(model, goldFeatures, decodedFeatures)
+		if PercepAllOut {
+			log.Println("ITERATION COMPLETE")
+		}
+
+		// if m.Log {
+		// 	log.Println("After Model Update:")
+		// 	log.Println("\n", m.Model)
+		// }
+		// log.Println()
+
+		// log.Println("Model after:")
+		// for k, v := range *m.Model {
+		// 	log.Println(k, v)
+		// }
+		// log.Println()
+		return true, true
+	}
+	if m.Log && !PercepAllOut {
+		log.Println("At instance", instance, "success")
+	}
+	return true, false
+}
+
 // func (m *LinearPerceptron) Read(reader io.Reader) {
 // 	dec := gob.NewDecoder(reader)
 // 	model := make(Model)
@@ -277,6 +351,41 @@ type UpdateStrategy interface {
 	Finalize(m Model) Model
 }
 
+// Learner updates a model towards the gold features of a training instance
+// and away from the features it was wrongly decoded with; with parallel
+// training Learn is called concurrently for different models
+type Learner interface {
+	Init(m Model)
+	Learn(m Model, goldFeatures, decodedFeatures interface{})
+}
+
+// PerceptronLearner is the standard perceptron update, with a fixed step
+// of Step (1 if 0)
+type PerceptronLearner struct {
+	Step int64
+}
+
+var _ Learner = &PerceptronLearner{}
+
+func (l *PerceptronLearner) Init(m Model) {
+
+}
+
+func (l *PerceptronLearner) Learn(m Model, goldFeatures, decodedFeatures interface{}) {
+	var step int64 = 1
+	if l.Step != 0 {
+		step = l.Step
+	}
+	if PercepAllOut {
+		log.Println("Score", step, "to")
+	}
+	m.AddSubtract(goldFeatures, decodedFeatures, step)
+	if PercepAllOut {
+		log.Println("Score", -step, "to")
+	}
+	m.AddSubtract(decodedFeatures, decodedFeatures, -step)
+}
+
 type TrivialStrategy struct{}
 
 func (u *TrivialStrategy) Init(m Model, iterations int) {
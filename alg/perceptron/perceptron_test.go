@@ -1,34 +1,222 @@
 package perceptron
 
-import "testing"
+import (
+	"testing"
+
+	"yap/util"
+)
+
+// testModel is a Model of weights of string features; its features are a
+// []string, and AddSubtract adds the gold features only, as AvgMatrixSparse
+type testModel struct {
+	Weights map[string]int64
+}
+
+var _ Model = &testModel{}
+
+func newTestModel() *testModel {
+	return &testModel{make(map[string]int64)}
+}
+
+func (t *testModel) Score(features interface{}) int64 {
+	var score int64
+	for _, f := range features.([]string) {
+		score += t.Weights[f]
+	}
+	return score
+}
+
+func (t *testModel) Add(features interface{}) Model {
+	t.AddSubtract(features, nil, 1)
+	return t
+}
+
+func (t *testModel) Subtract(features interface{}) Model {
+	t.AddSubtract(features, nil, -1)
+	return t
+}
+
+func (t *testModel) AddSubtract(goldFeatures, decodedFeatures interface{}, amount int64) {
+	for _, f := range goldFeatures.([]string) {
+		t.Weights[f] += amount
+	}
+}
+
+func (t *testModel) ScalarDivide(val int64) {
+	for f, w := range t.Weights {
+		t.Weights[f] = w / val
+	}
+}
+
+func (t *testModel) Copy() Model {
+	copied := newTestModel()
+	copied.AddModel(t)
+	return copied
+}
+
+func (t *testModel) AddModel(m Model) {
+	for f, w := range m.(*testModel).Weights {
+		t.Weights[f] += w
+	}
+}
+
+func (t *testModel) New() Model {
+	return newTestModel()
+}
+
+// testInstance is a training instance classified with one of two labels
+type testInstance struct {
+	ID       int
+	Label    string
+	Features []string
+}
+
+func (i *testInstance) Equal(other util.Equaler) bool {
+	o := other.(*testInstance)
+	return i.ID == o.ID && i.Label == o.Label
+}
+
+// testDecoder classifies a testInstance with the label of its highest
+// scoring features, "A" on ties
+type testDecoder struct{}
+
+var _ InstanceDecoder = &testDecoder{}
+var _ EarlyUpdateInstanceDecoder = &testDecoder{}
+
+func labeled(features []string, label string) []string {
+	result := make([]string, len(features))
+	for i, f := range features {
+		result[i] = f + "|" + label
+	}
+	return result
+}
+
+func (d *testDecoder) Decode(i Instance, m Model) (DecodedInstance, interface{}) {
+	instance := i.(*testInstance)
+	label := "A"
+	if m.Score(labeled(instance.Features, "B")) > m.Score(labeled(instance.Features, "A")) {
+		label = "B"
+	}
+	decoded := &testInstance{instance.ID, label, instance.Features}
+	return &Decoded{instance, decoded}, labeled(instance.Features, label)
+}
+
+func (d *testDecoder) DecodeGold(i DecodedInstance, m Model) (DecodedInstance, interface{}) {
+	return i, nil
+}
+
+func (d *testDecoder) DecodeEarlyUpdate(i DecodedInstance, m Model) (DecodedInstance, interface{}, interface{}, int, int, float64) {
+	gold := i.Decoded().(*testInstance)
+	decoded, features := d.Decode(gold, m)
+	return decoded, features, labeled(gold.Features, gold.Label), -1, 1, float64(m.Score(features))
+}
+
+// testUpdater counts its updates, leaving the model as is
+type testUpdater struct {
+	Updates int
+}
+
+func (u *testUpdater) Init(m Model, iterations int) {
+	u.Updates = 0
+}
+
+func (u *testUpdater) Update(m Model) {
+	u.Updates++
+}
+
+func (u *testUpdater) Finalize(m Model) Model {
+	return m
+}
 
 func TestPerceptron(t *testing.T) {
 
 }
 
 func TestTrivialStrategy(t *testing.T) {
-	v := make(featurevector.Sparse)
+	v := newTestModel()
 	w := new(TrivialStrategy)
-	w.Init(&v, 10)
-	w.Update(&v)
-	if &v != w.Finalize(&v) {
+	w.Init(v, 10)
+	w.Update(v)
+	if v != w.Finalize(v) {
 		t.Error("Should return trivial value")
 	}
 }
 
 func TestAveragedStrategy(t *testing.T) {
-	v := make(featurevector.Sparse)
-	v[Feature("a")] = 4.0 // (1/8)
-	v[Feature("b")] = 1.0
+	v := newTestModel()
+	v.Weights["a"] = 4
+	v.Weights["b"] = 1
 	w := new(AveragedStrategy)
-	w.Init(&v, 4)
-	w.Update(&v)
-	w.Update(&v)
-	avg := *(w.Finalize(&v))
-	if avg[Feature("a")] != 1.0 {
-		t.Error("Got averaged value", avg[Feature("a")], "expected", 1.0)
-	}
-	if avg[Feature("b")] != 0.25 {
-		t.Error("Got averaged value", avg[Feature("a")], "expected", 0.25)
+	w.Init(v, 4)
+	w.Update(v)
+	v.Weights["a"] = 8
+	w.Update(v)
+	avg := w.Finalize(v).(*testModel).Weights
+	if avg["a"] != 6 {
+		t.Error("Got averaged value", avg["a"], "expected", 6)
+	}
+	if avg["b"] != 1 {
+		t.Error("Got averaged value", avg["b"], "expected", 1)
+	}
+}
+
+// orderDecoder is a testDecoder recording the IDs of the gold instances it
+// decodes, in the order trained on
+type orderDecoder struct {
+	testDecoder
+	IDs []int
+}
+
+func (d *orderDecoder) DecodeGold(i DecodedInstance, m Model) (DecodedInstance, interface{}) {
+	d.IDs = append(d.IDs, i.Decoded().(*testInstance).ID)
+	return d.testDecoder.DecodeGold(i, m)
+}
+
+// trainOrder trains on n instances with a shuffling seed, returning the order
+// of instances trained on in every iteration
+func trainOrder(seed int64, iterations, n int) [][]int {
+	labels, features := make([]byte, n), make([][]string, n)
+	for i := range labels {
+		labels[i], features[i] = 'A', []string{"x"}
+	}
+	m := newTestPerceptron(iterations, 1, MIX_UNIFORM, 0)
+	gold := &orderDecoder{}
+	m.GoldDecoder, m.Seed = gold, seed
+	m.Train(testInstances(string(labels), features...))
+	orders := make([][]int, iterations)
+	for i := range orders {
+		orders[i] = gold.IDs[i*n : (i+1)*n]
+	}
+	return orders
+}
+
+func TestTrainSeed(t *testing.T) {
+	const iterations, n = 3, 10
+	for i, order := range trainOrder(0, iterations, n) {
+		for j, id := range order {
+			if id != j {
+				t.Fatalf("seed 0 trained iteration %v in order %v, expected file order", i, order)
+			}
+		}
+	}
+
+	orders, again := trainOrder(5, iterations, n), trainOrder(5, iterations, n)
+	for i, order := range orders {
+		seen := make(map[int]bool, n)
+		shuffled := false
+		for j, id := range order {
+			seen[id] = true
+			shuffled = shuffled || id != j
+			if again[i][j] != id {
+				t.Errorf("seed 5 trained iteration %v in order %v, then %v", i, order, again[i])
+				break
+			}
+		}
+		if len(seen) != n {
+			t.Errorf("seed 5 trained iteration %v in order %v, not a permutation", i, order)
+		}
+		if !shuffled {
+			t.Errorf("seed 5 trained iteration %v in file order", i)
+		}
 	}
 }
@@ -2,6 +2,7 @@ package search
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -47,6 +48,22 @@ type Beam struct {
 	NoRecover          bool
 	Align              bool
 
+	// per sentence time budget (0 for none) when parsing; once it has passed
+	// (or parsing is cancelled) the parse fails, unless CompleteGreedily is set
+	// in which case the current best candidate is completed greedily
+	Timeout          time.Duration
+	CompleteGreedily bool
+
+	// if set, called with the number of beam rounds of each parse
+	RoundsObserver func(rounds int)
+
+	// training update strategy, one of UpdateStrategies (empty for early)
+	Update string
+	// cut the gold features of an update where the decoded ones are cut,
+	// for learners aligning their feature deltas from the first transition
+	// (the perceptron update applies as many gold transitions as decoded)
+	CutGold bool
+
 	// used for performance tuning
 	lastRoundStart time.Time
 	DurTotal       time.Duration
@@ -61,6 +78,8 @@ type Beam struct {
 
 var _ Interface = &Beam{}
 var _ perceptron.EarlyUpdateInstanceDecoder = &Beam{}
+var _ KBest = &Beam{}
+var _ GoldScorer = &Beam{}
 
 func (b *Beam) Name() string {
 	notAligned := ""
@@ -228,7 +247,10 @@ func (b *Beam) Expand(c Candidate, p Problem, candidateNum int) chan Candidate {
 			log.Println("Features")
 		}
 		feats := b.FeatExtractor.Features(conf, false, transType, transitions)
-		b.FeatExtractor.SetLog(false)
+		if ShowFeats {
+			// the extractor is shared by parallel decoders, only touch it when logging
+			b.FeatExtractor.SetLog(false)
+		}
 		featuring += time.Since(lastMem)
 
 		var newFeatList *transition.FeaturesList
@@ -421,12 +443,100 @@ func (b *Beam) TopB(a Agenda, B int) ([]Candidate, bool) {
 	return candidates, allTerminal
 }
 
+// BestK returns copies of the (at most) k best terminal candidates of the
+// agenda, best first; if none is terminal (the search was stopped) it
+// returns the best candidate, as Best
+func (b *Beam) BestK(a Agenda, k int) []Candidate {
+	agenda := a.(*BaseAgenda)
+	// sorts the agenda, best first
+	best := b.Best(agenda)
+	candidates := make([]Candidate, 0, k)
+	for _, candidate := range agenda.Confs {
+		if len(candidates) == k {
+			break
+		}
+		candidate.Expand(b.TransFunc)
+		if candidate.Terminal() {
+			candidates = append(candidates, candidate.Copy())
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, best.Copy())
+	}
+	return candidates
+}
+
+// ScoredParse is one of the k best parses of a problem, with its model score
+type ScoredParse struct {
+	C     transition.Configuration
+	Score float64
+}
+
+// ParseKBest returns the (at most) k best complete parses of the problem,
+// best first; there are at most as many as the beam's Size
+func (b *Beam) ParseKBest(problem Problem, k int) []ScoredParse {
+	parses, err := b.ParseKBestContext(context.Background(), problem, k)
+	if err != nil {
+		panic(fmt.Sprintf("Failed parsing: %v", err))
+	}
+	return parses
+}
+
+// ParseKBestContext is ParseKBest, stopping as ParseContext
+func (b *Beam) ParseKBestContext(ctx context.Context, problem Problem, k int) ([]ScoredParse, error) {
+	start := time.Now()
+	prefix := log.Prefix()
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	candidates, rounds, err := SearchKBestContext(ctx, b.CompleteGreedily, b, problem, b.Size, k)
+	if b.RoundsObserver != nil {
+		b.RoundsObserver(rounds)
+	}
+	log.SetPrefix(prefix)
+	b.DurTotal += time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	parses := make([]ScoredParse, len(candidates))
+	for i, candidate := range candidates {
+		parses[i] = ScoredParse{candidate.(*ScoredConfiguration).C, candidate.Score()}
+	}
+	return parses, nil
+}
+
 func (b *Beam) Parse(problem Problem) (transition.Configuration, interface{}) {
+	conf, resultParams, err := b.ParseContext(context.Background(), problem)
+	if err != nil {
+		panic(fmt.Sprintf("Failed parsing: %v", err))
+	}
+	return conf, resultParams
+}
+
+// ParseContext parses the problem, stopping when ctx is done or when the
+// beam's Timeout has passed; see CompleteGreedily
+func (b *Beam) ParseContext(ctx context.Context, problem Problem) (transition.Configuration, interface{}, error) {
 	start := time.Now()
 	prefix := log.Prefix()
 	// log.SetPrefix("Parsing ")
 	// log.Println("Starting parse")
-	beamScored := Search(b, problem, b.Size).(*ScoredConfiguration)
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	beamCandidate, rounds, err := SearchContext(ctx, b.CompleteGreedily, b, problem, b.Size)
+	if b.RoundsObserver != nil {
+		b.RoundsObserver(rounds)
+	}
+	if err != nil {
+		log.SetPrefix(prefix)
+		b.DurTotal += time.Since(start)
+		return nil, nil, err
+	}
+	beamScored := beamCandidate.(*ScoredConfiguration)
 	// build result parameters
 	var resultParams *ParseResultParameters
 	if b.ReturnModelValue || b.ReturnSequence {
@@ -447,7 +557,7 @@ func (b *Beam) Parse(problem Problem) (transition.Configuration, interface{}) {
 	// log.Println("\n", beamScored.C.GetSequence())
 	log.SetPrefix(prefix)
 	b.DurTotal += time.Since(start)
-	return beamScored.C, resultParams
+	return beamScored.C, resultParams, nil
 }
 
 func (b *Beam) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
@@ -470,7 +580,7 @@ func (b *Beam) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perc
 	b.ReturnModelValue = true
 
 	// log.Println("Begin search..")
-	beamResult, goldResult := SearchEarlyUpdate(b, sent, b.Size, goldSequence)
+	beamResult, goldResult := SearchUpdate(b, sent, b.Size, goldSequence, b.Update)
 	// log.Println("Search ended")
 
 	beamScored := beamResult.(*ScoredConfiguration)
@@ -532,6 +642,17 @@ func (b *Beam) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perc
 		if curBeamFeatures != nil {
 			curBeamFeatures.Previous = nil
 		}
+		if b.CutGold {
+			// cut the gold features at the same prefix, so both start at the
+			// first transition they differ in
+			curGoldFeatures := goldFeatures
+			for j := 0; j <= i-diffParsedGold && curGoldFeatures != nil; j++ {
+				curGoldFeatures = curGoldFeatures.Previous
+			}
+			if curGoldFeatures != nil {
+				curGoldFeatures.Previous = nil
+			}
+		}
 	}
 
 	// parsedFeatures := beamScored.ModelValue.(*PerceptronModelValue).vector
@@ -596,6 +717,21 @@ func (b *Beam) Idle(c Candidate, candidateNum int) Candidate {
 	return scored
 }
 
+func (b *Beam) ScoreGold(prev, next Candidate) {
+	prevScored, nextScored := prev.(*ScoredConfiguration), next.(*ScoredConfiguration)
+	nextTransition := nextScored.C.GetLastTransition()
+	scores := b.candidateScorePool.Get().(featurevector.ScoredStore)
+	scores.Clear()
+	scores.SetTransitions([]int{nextTransition.Value()})
+	scorer := b.Model.(*TransitionModel.AvgMatrixSparse)
+	scorer.SetTransitionScores(prevScored.Features.Features, scores, b.DecodeTest)
+	score, _ := scores.Get(nextTransition.Value())
+	b.candidateScorePool.Put(scores)
+	nextScored.InternalScores = prevScored.InternalScores.Copy()
+	nextScored.Averaged = b.Averaged
+	nextScored.AddScore(score, prevScored.C.Assignment())
+}
+
 type AssignmentScore struct {
 	Total  int64
 	Number uint16
@@ -691,16 +827,10 @@ func (scs ScoredConfigurations) Get(i int) Candidate {
 }
 
 func (scs ScoredConfigurations) Equal(otherEq util.Equaler) bool {
-	switch other := otherEq.(type) {
-	case Candidate:
-		return scs[0].Equal(other)
-	default:
-		// log.Println("Equating", scs[len(scs)-1].C, "and", otherEq)
-		// log.Println(scs[len(scs)-1].C.GetSequence())
-		// log.Println(otherEq.GetSequence())
-		return otherEq.Equal(scs[len(scs)-1].C)
-		panic("Cannot compare to other")
-	}
+	// log.Println("Equating", scs[len(scs)-1].C, "and", otherEq)
+	// log.Println(scs[len(scs)-1].C.GetSequence())
+	// log.Println(otherEq.GetSequence())
+	return otherEq.Equal(scs[len(scs)-1].C)
 }
 
 func (s *ScoredConfiguration) AddScore(newScore int64, assignment uint16) {
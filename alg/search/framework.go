@@ -1,8 +1,10 @@
 package search
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"yap/alg/transition"
 	"yap/util"
@@ -12,6 +14,18 @@ const (
 	MAX_TRANSITIONS = 800
 )
 
+// Structured perceptron update strategies of SearchUpdate: early update at
+// the first prefix the gold falls off the beam, max-violation update at the
+// prefix where the best candidate's score most exceeds the gold's, and
+// latest update at the last prefix where it exceeds the gold's
+const (
+	UPDATE_EARLY         = "early"
+	UPDATE_MAX_VIOLATION = "max-violation"
+	UPDATE_LATEST        = "latest"
+)
+
+var UpdateStrategies = []string{UPDATE_EARLY, UPDATE_MAX_VIOLATION, UPDATE_LATEST}
+
 var AllOut bool = true
 
 type Agenda interface {
@@ -56,6 +70,28 @@ type Interface interface {
 	Aligned() bool
 }
 
+// KBest is implemented by searches that can return the k best candidates
+// of their final agenda
+type KBest interface {
+	BestK(a Agenda, k int) []Candidate
+}
+
+// GoldScorer is implemented by searches that can score the gold sequence
+// as they score candidates, required by the max-violation and latest updates
+type GoldScorer interface {
+	// ScoreGold sets next's score to prev's and that of the transition
+	// from prev to next
+	ScoreGold(prev, next Candidate)
+}
+
+// violation is a prefix of the search where the best candidate scored
+// higher than the gold
+type violation struct {
+	best, gold Candidate
+	goldIndex  int
+	score      float64
+}
+
 type IdleFunc func(c Candidate, candidateNum int) Candidate
 
 type Idle interface {
@@ -63,16 +99,49 @@ type Idle interface {
 }
 
 func Search(b Interface, problem Problem, B int) Candidate {
-	candidate, _ := search(b, problem, B, 1, false, nil)
+	candidate, _, _, _, _ := search(nil, false, b, problem, B, 1, false, "", nil)
 	return candidate
 }
 
+// SearchContext is Search, checking ctx for cancellation (or a passed deadline)
+// between beam rounds; once ctx is done the search either aborts with ctx's error,
+// or if completeGreedily is set continues with only the current best candidate
+// (a beam of 1) until it reaches a terminal state
+// Also returns the number of rounds searched
+func SearchContext(ctx context.Context, completeGreedily bool, b Interface, problem Problem, B int) (Candidate, int, error) {
+	candidate, _, _, rounds, err := search(ctx, completeGreedily, b, problem, B, 1, false, "", nil)
+	return candidate, rounds, err
+}
+
+// SearchKBestContext is SearchContext returning the (at most) k best
+// candidates of the final agenda, best first
+func SearchKBestContext(ctx context.Context, completeGreedily bool, b Interface, problem Problem, B, k int) ([]Candidate, int, error) {
+	if _, ok := b.(KBest); !ok {
+		panic("Can't search for k best candidates when search does not have a BestK function")
+	}
+	_, candidates, _, rounds, err := search(ctx, completeGreedily, b, problem, B, k, false, "", nil)
+	return candidates, rounds, err
+}
+
 func SearchEarlyUpdate(b Interface, problem Problem, B int, goldSequence Candidates) (Candidate, Candidate) {
-	return search(b, problem, B, 1, true, goldSequence)
+	return SearchUpdate(b, problem, B, goldSequence, UPDATE_EARLY)
 }
 
-func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSequence Candidates) (Candidate, Candidate) {
+// SearchUpdate is SearchEarlyUpdate with the given update strategy; unless
+// it is early update the search continues after the gold falls off the beam,
+// until the end of the gold sequence, returning the violating prefixes
+// of the chosen update
+func SearchUpdate(b Interface, problem Problem, B int, goldSequence Candidates, update string) (Candidate, Candidate) {
+	candidate, _, goldValue, _, _ := search(nil, false, b, problem, B, 1, true, update, goldSequence)
+	return candidate, goldValue
+}
+
+func search(ctx context.Context, completeGreedily bool, b Interface, problem Problem, B, topK int, earlyUpdate bool, update string, goldSequence Candidates) (Candidate, []Candidate, Candidate, int, error) {
 	var (
+		// for cancellation
+		done   <-chan struct{}
+		greedy bool
+
 		goldValue Candidate
 		best      Candidate
 		agenda    Agenda
@@ -91,8 +160,15 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 		idleCandidates        bool = false
 		idleFunc              IdleFunc
 		idleGoldTransitions   int
+
+		// for max-violation and latest update
+		goldScorer GoldScorer
+		chosen     *violation
 	)
 	tempAgendas := make([][]Candidate, 0, B)
+	if ctx != nil {
+		done = ctx.Done()
+	}
 
 	if idleCandidates {
 		idlingInterface, idles := b.(Idle)
@@ -120,6 +196,13 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 	if earlyUpdate {
 		goldValue = goldSequence.Get(0)
 		goldIndex = 0
+		if len(update) > 0 && update != UPDATE_EARLY {
+			scorer, scores := b.(GoldScorer)
+			if !scores {
+				panic("Can't use " + update + " update when search does not have a gold scoring function")
+			}
+			goldScorer = scorer
+		}
 	}
 	// loop do
 	for {
@@ -251,7 +334,9 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 
 		// early update
 		if earlyUpdate {
-			if !goldExists || goldIndex+1 >= (goldSequence.Len()+idleGoldTransitions) {
+			// with max-violation and latest updates the search goes on after
+			// the gold falls off the beam
+			if (!goldExists && goldScorer == nil) || goldIndex+1 >= (goldSequence.Len()+idleGoldTransitions) {
 				if AllOut {
 					log.Println("EARLY UPDATE")
 				}
@@ -272,6 +357,9 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 						goldIndex++
 						nextValue := goldSequence.Get(goldIndex)
 						nextValue.(*ScoredConfiguration).C.SetPrevious(goldValue.(*ScoredConfiguration).C)
+						if goldScorer != nil {
+							goldScorer.ScoreGold(goldValue, nextValue)
+						}
 						goldValue = nextValue
 					} else {
 						if AllOut {
@@ -287,18 +375,49 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 					}
 				} else {
 					goldIndex++
-					goldValue = goldSequence.Get(goldIndex)
-					if goldValue == nil {
+					nextValue := goldSequence.Get(goldIndex)
+					if nextValue == nil {
 						panic("Got nil gold value")
 					}
+					if goldScorer != nil {
+						goldScorer.ScoreGold(goldValue, nextValue)
+					}
+					goldValue = nextValue
 				}
 			}
 			// best <- TOP(AGENDA)
 			best = b.Top(agenda)
+			if goldScorer != nil {
+				if score := best.Score() - goldValue.Score(); score > 0 {
+					if chosen == nil || update == UPDATE_LATEST || score > chosen.score {
+						chosen = &violation{best, goldValue, goldIndex, score}
+					}
+				}
+			}
+		}
+
+		// stop or fall back to greedy search if cancelled
+		if !greedy {
+			select {
+			case <-done:
+				if !completeGreedily {
+					agenda = b.Clear(agenda)
+					return nil, nil, nil, i, ctx.Err()
+				}
+				log.Println("Search interrupted at round", i, "-", ctx.Err(), "- completing best candidate greedily")
+				greedy = true
+			default:
+			}
 		}
 
-		// candidates <- TOP-B(agenda, B)
-		candidates, allTerminal = b.TopB(agenda, B)
+		if greedy {
+			// candidates <- {TOP(agenda)}
+			best = b.Top(agenda)
+			candidates, allTerminal = []Candidate{best}, best.Terminal()
+		} else {
+			// candidates <- TOP-B(agenda, B)
+			candidates, allTerminal = b.TopB(agenda, B)
+		}
 
 		// if GOALTEST(problem,best)
 		if ((allTerminal || earlyUpdate) && b.GoalTest(problem, best, i)) || i > MAX_TRANSITIONS {
@@ -320,10 +439,26 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 			log.Println("Next Round", i-1)
 		}
 	}
+	if chosen != nil {
+		if AllOut {
+			log.Println(strings.ToUpper(update), "UPDATE at", chosen.goldIndex)
+		}
+		best, goldValue = chosen.best, chosen.gold
+		b.SetEarlyUpdate(util.Min(chosen.goldIndex, best.Len()-1))
+	}
+	var kBest []Candidate
 	if !earlyUpdate {
-		best = b.Best(agenda)
+		if topK > 1 {
+			// the k best are copied by BestK
+			kBest = b.(KBest).BestK(agenda, topK)
+			best = kBest[0]
+		} else {
+			best = b.Best(agenda)
+		}
+	}
+	if kBest == nil {
+		best = best.Copy()
 	}
-	best = best.Copy()
 	agenda = b.Clear(agenda)
-	return best, goldValue
+	return best, kBest, goldValue, i, nil
 }
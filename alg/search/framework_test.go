@@ -0,0 +1,220 @@
+package search
+
+import (
+	"sort"
+	"testing"
+)
+
+// testCandidate is a sequence of labels 0 and 1
+type testCandidate struct {
+	Seq []int
+	Scr float64
+}
+
+func (c *testCandidate) Copy() Candidate {
+	return &testCandidate{append([]int(nil), c.Seq...), c.Scr}
+}
+
+func (c *testCandidate) Equal(other Candidate) bool {
+	o := other.(*testCandidate)
+	if len(c.Seq) != len(o.Seq) {
+		return false
+	}
+	for i, label := range c.Seq {
+		if o.Seq[i] != label {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *testCandidate) Score() float64 {
+	return c.Scr
+}
+
+func (c *testCandidate) Len() int {
+	return len(c.Seq)
+}
+
+func (c *testCandidate) Terminal() bool {
+	return false
+}
+
+type testAgenda struct {
+	Candidates []Candidate
+}
+
+func (a *testAgenda) AddCandidates(cs []Candidate, best Candidate, minAlignment int) (Candidate, int) {
+	for _, c := range cs {
+		a.Candidates = append(a.Candidates, c)
+		if best == nil || c.Score() > best.Score() {
+			best = c
+		}
+	}
+	return best, minAlignment
+}
+
+func (a *testAgenda) Contains(c Candidate) bool {
+	for _, other := range a.Candidates {
+		if other.Equal(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *testAgenda) Len() int {
+	return len(a.Candidates)
+}
+
+func (a *testAgenda) Clear() {
+	a.Candidates = nil
+}
+
+// testSearch labels sequences of Length by the scores of each label given
+// the previous label (0 at the start), at each position
+type testSearch struct {
+	Scores      [][2][2]float64
+	EarlyUpdate int
+}
+
+var _ Interface = &testSearch{}
+var _ GoldScorer = &testSearch{}
+
+func (s *testSearch) score(c *testCandidate, label int) float64 {
+	prev := 0
+	if len(c.Seq) > 0 {
+		prev = c.Seq[len(c.Seq)-1]
+	}
+	return c.Scr + s.Scores[len(c.Seq)][prev][label]
+}
+
+func (s *testSearch) StartItem(p Problem) []Candidate {
+	return []Candidate{&testCandidate{}}
+}
+
+func (s *testSearch) Clear(a Agenda) Agenda {
+	return &testAgenda{}
+}
+
+func (s *testSearch) Insert(cs chan Candidate, a Agenda) []Candidate {
+	var candidates []Candidate
+	for c := range cs {
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+func (s *testSearch) Expand(c Candidate, p Problem, candidateNum int) chan Candidate {
+	cs := make(chan Candidate, 2)
+	candidate := c.(*testCandidate)
+	for label := 0; label < 2; label++ {
+		cs <- &testCandidate{append(append([]int(nil), candidate.Seq...), label), s.score(candidate, label)}
+	}
+	close(cs)
+	return cs
+}
+
+func (s *testSearch) sorted(a Agenda) []Candidate {
+	candidates := a.(*testAgenda).Candidates
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score() > candidates[j].Score()
+	})
+	return candidates
+}
+
+func (s *testSearch) Top(a Agenda) Candidate {
+	return s.sorted(a)[0]
+}
+
+func (s *testSearch) Best(a Agenda) Candidate {
+	return s.Top(a)
+}
+
+func (s *testSearch) GoalTest(p Problem, c Candidate, rounds int) bool {
+	return c.Len() >= len(s.Scores)
+}
+
+func (s *testSearch) TopB(a Agenda, B int) ([]Candidate, bool) {
+	candidates := s.sorted(a)
+	if len(candidates) > B {
+		candidates = candidates[:B]
+	}
+	return candidates, false
+}
+
+func (s *testSearch) Concurrent() bool {
+	return false
+}
+
+func (s *testSearch) SetEarlyUpdate(i int) {
+	s.EarlyUpdate = i
+}
+
+func (s *testSearch) Name() string {
+	return "Test"
+}
+
+func (s *testSearch) Aligned() bool {
+	return false
+}
+
+func (s *testSearch) ScoreGold(prev, next Candidate) {
+	last := next.(*testCandidate).Seq[next.Len()-1]
+	next.(*testCandidate).Scr = s.score(prev.(*testCandidate), last)
+}
+
+// testGold is the gold sequence of labels, by its prefixes
+type testGold []int
+
+func (g testGold) Get(i int) Candidate {
+	return &testCandidate{Seq: append([]int(nil), g[:i]...)}
+}
+
+func (g testGold) Len() int {
+	return len(g) + 1
+}
+
+func TestSearchUpdate(t *testing.T) {
+	defer func(allOut bool) { AllOut = allOut }(AllOut)
+	AllOut = false
+	// with a beam of 1 the search labels 1 1 1 1, the gold is 0 0 0 0;
+	// the best prefix outscores the gold's by 1, 3, 2 and 0.5
+	scores := [][2][2]float64{
+		{{0, 1}, {0, 0}},
+		{{0, 0}, {0, 2}},
+		{{1, 0}, {-1, 0}},
+		{{1.5, 0}, {-5, 0}},
+	}
+	gold := testGold{0, 0, 0, 0}
+	for _, test := range []struct {
+		Update      string
+		Best        []int
+		Gold        []int
+		BestScore   float64
+		GoldScore   float64
+		EarlyUpdate int
+	}{
+		// at the first prefix the gold falls off the beam
+		{UPDATE_EARLY, []int{1}, []int{0}, 1, 0, 0},
+		// at the prefix of the largest violation
+		{UPDATE_MAX_VIOLATION, []int{1, 1}, []int{0, 0}, 3, 0, 1},
+		// at the last prefix the best outscores the gold
+		{UPDATE_LATEST, []int{1, 1, 1, 1}, []int{0, 0, 0, 0}, 3, 2.5, 3},
+	} {
+		s := &testSearch{Scores: scores, EarlyUpdate: -1}
+		best, goldValue := SearchUpdate(s, nil, 1, gold, test.Update)
+		if !best.Equal(&testCandidate{Seq: test.Best}) || best.Score() != test.BestScore {
+			t.Errorf("%v: best %v, expected %v scored %v", test.Update, best, test.Best, test.BestScore)
+		}
+		if !goldValue.Equal(&testCandidate{Seq: test.Gold}) {
+			t.Errorf("%v: gold %v, expected %v", test.Update, goldValue, test.Gold)
+		}
+		if test.Update != UPDATE_EARLY && goldValue.Score() != test.GoldScore {
+			t.Errorf("%v: gold scored %v, expected %v", test.Update, goldValue.Score(), test.GoldScore)
+		}
+		if s.EarlyUpdate != test.EarlyUpdate {
+			t.Errorf("%v: updated at %v, expected %v", test.Update, s.EarlyUpdate, test.EarlyUpdate)
+		}
+	}
+}
@@ -469,11 +469,13 @@ func (x *GenericExtractor) Features(instance Instance, idle bool, transType byte
 	// build element cache
 	for i, elementTemplate := range elements {
 		// log.Println("At template", i, elementTemplate.ConfStr)
-		element, exists, attIsGenerator := x.GetFeatureElement(conf, &elements[i], attrArray[0:0], transitions)
-		elementIsGenerator[i] = attIsGenerator
+		// elements are generators per configuration, templates are shared by
+		// concurrent decoders and left as set up
+		element, exists, isGenerator := x.GetFeatureElement(conf, &elements[i], attrArray[0:0], transitions)
+		elementIsGenerator[i] = isGenerator
 		if exists {
 			if x.Log {
-				log.Printf("%d %s: %v , isGen = %v, isElGen = %v\n", i, elementTemplate.ConfStr, element, elementTemplate.IsGenerator, elementIsGenerator[i])
+				log.Printf("%d %s: %v , isGen = %v\n", i, elementTemplate.ConfStr, element, elementIsGenerator[i])
 			}
 			// zpar bug parity
 			if _Zpar_Bug_S0R2L && i == S0R2l { // un-documented code in zpar uses S0rl instead of S0r2l (wtf?!)
@@ -523,8 +525,7 @@ func (x *GenericExtractor) Features(instance Instance, idle bool, transType byte
 		if hasNilRequirement {
 			features[i] = nil
 		} else {
-			(&featureTemplates[i]).Elements[0].IsGenerator = elements[template.CachedElementIDs[0]].IsGenerator
-			if elements[template.CachedElementIDs[0]].IsGenerator || elementIsGenerator[template.CachedElementIDs[0]] {
+			if elementIsGenerator[template.CachedElementIDs[0]] {
 				if x.Log {
 					log.Printf("\t\tIsGenerator")
 				}
@@ -562,7 +563,7 @@ func (x *GenericExtractor) Features(instance Instance, idle bool, transType byte
 			}
 			if x.Log && features[i] != nil {
 				// log.Println(x.EWord)
-				log.Printf("\t\t%s", template.FormatWithGenerator(features[i], elements[template.CachedElementIDs[0]].IsGenerator))
+				log.Printf("\t\t%s", template.FormatWithGenerator(features[i], elementIsGenerator[template.CachedElementIDs[0]]))
 			}
 		}
 	}
@@ -639,7 +640,6 @@ func (x *GenericExtractor) GetFeatureElement(conf Configuration, templateElement
 	if isGenerator {
 		addresses = conf.GenerateAddresses(address, []byte(templateElement.Address))
 		resultArray = make([]interface{}, len(addresses))
-		templateElement.IsGenerator = true
 	} else {
 		singleAddress[0] = address
 		addresses = singleAddress[0:1]
@@ -670,9 +670,9 @@ func (x *GenericExtractor) GetFeatureElement(conf Configuration, templateElement
 		resultArray[addressID] = GetArray(attrValues)
 	}
 	if isGenerator && !attIsGenerator {
-		return resultArray, true, attIsGenerator
+		return resultArray, true, isGenerator
 	} else {
-		return resultArray[0], true, attIsGenerator
+		return resultArray[0], true, isGenerator
 	}
 }
 
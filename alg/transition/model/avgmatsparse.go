@@ -364,3 +364,35 @@ func (u *AveragedModelStrategy) Finalize(m perceptron.Model) perceptron.Model {
 	u.accumModel.Integrate()
 	return u.accumModel
 }
+
+// AvgMatrixSparseState is the state of a model in training, including the
+// averaging history of its weights
+type AvgMatrixSparseState struct {
+	Generation int
+	Mat        []map[interface{}]map[int]HistoryState
+}
+
+// State returns the model's state, to resume training with SetState
+func (t *AvgMatrixSparse) State() *AvgMatrixSparseState {
+	state := &AvgMatrixSparseState{
+		Generation: t.Generation,
+		Mat:        make([]map[interface{}]map[int]HistoryState, len(t.Mat)),
+	}
+	for i, val := range t.Mat {
+		state.Mat[i] = val.State()
+	}
+	return state
+}
+
+// SetState sets the weights of a model created for training (e.g. with
+// NewAvgMatrixSparse) to a State
+func (t *AvgMatrixSparse) SetState(state *AvgMatrixSparseState) error {
+	if len(state.Mat) != len(t.Mat) {
+		return fmt.Errorf("state has %v feature templates, the model has %v", len(state.Mat), len(t.Mat))
+	}
+	t.Generation = state.Generation
+	for i, val := range state.Mat {
+		t.Mat[i].SetState(val)
+	}
+	return nil
+}
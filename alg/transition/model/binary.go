@@ -0,0 +1,412 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	. "yap/alg/featurevector"
+	"yap/util"
+)
+
+// BINARY_MAGIC starts weights written in the binary format
+var BINARY_MAGIC = []byte("YAPW\x00\x01")
+
+// The binary format of AvgMatrixSparse weights:
+//
+//	magic, generation, feature template names
+//	number of templates, then per template:
+//		number of feature keys, the keys
+//		number of transitions of each key
+//		flat arrays of all transitions (uint32) and weights (int64)
+//
+// Numbers are varints and arrays little endian; feature keys are encoded
+// with their types (see binaryWriter.value), so they decode to the same values gob
+// decodes them to.
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// types of feature key elements, by kind
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  reflect.TypeOf(""),
+}
+
+// IsBinary returns whether data starts with weights in the binary format
+func IsBinary(data []byte) bool {
+	return bytes.HasPrefix(data, BINARY_MAGIC)
+}
+
+// WriteBinary writes serialized weights in the binary format
+func (s *AvgMatrixSparseSerialized) WriteBinary(writer io.Writer) error {
+	w := &binaryWriter{w: bufio.NewWriter(writer)}
+	w.w.Write(BINARY_MAGIC)
+	w.uvarint(uint64(s.Generation))
+	w.uvarint(uint64(len(s.Features)))
+	for _, name := range s.Features {
+		w.string(name)
+	}
+	w.uvarint(uint64(len(s.Mat)))
+	for i, val := range s.Mat {
+		data, ok := val.(map[interface{}]map[int]int64)
+		if !ok {
+			return fmt.Errorf("template %v: can't write unknown serialization %T", i, val)
+		}
+		if err := w.template(data); err != nil {
+			return fmt.Errorf("template %v: %v", i, err)
+		}
+	}
+	return w.w.Flush()
+}
+
+// ReadBinary reads weights written by WriteBinary into the model, without
+// their serialized form; it returns the length of the weights in data
+func (t *AvgMatrixSparse) ReadBinary(data []byte) (n int, err error) {
+	r := &binaryReader{data: data}
+	defer r.recover(&err)
+	generation, _ := r.header()
+	t.Generation = generation
+	t.Mat = make([]*AvgSparse, r.count())
+	t.Features = len(t.Mat)
+	for i := range t.Mat {
+		avgSparse := &AvgSparse{}
+		keys, counts := r.keys()
+		avgSparse.Vals = make(map[Feature]TransitionScoreStore, len(keys))
+		// a key's values are in a single allocation of all the template's
+		values := make([]HistoryValue, r.total(counts))
+		transitions, weights := r.arrays(len(values))
+		var pos int
+		for j, key := range keys {
+			scoreStore := &LockedMap{Vals: make(map[int]*HistoryValue, counts[j])}
+			for k := 0; k < counts[j]; k++ {
+				value := &values[pos]
+				value.Generation = generation
+				value.Value = int64(binary.LittleEndian.Uint64(weights[pos*8:]))
+				scoreStore.Vals[int(binary.LittleEndian.Uint32(transitions[pos*4:]))] = value
+				pos++
+			}
+			avgSparse.Vals[key] = scoreStore
+		}
+		t.Mat[i] = avgSparse
+	}
+	return r.pos, nil
+}
+
+// ReadBinarySerialized reads weights written by WriteBinary to their
+// serialized form (e.g. to write them with gob)
+func ReadBinarySerialized(data []byte) (s *AvgMatrixSparseSerialized, err error) {
+	r := &binaryReader{data: data}
+	defer r.recover(&err)
+	s = &AvgMatrixSparseSerialized{}
+	s.Generation, s.Features = r.header()
+	s.Mat = make([]interface{}, r.count())
+	for i := range s.Mat {
+		keys, counts := r.keys()
+		transitions, weights := r.arrays(r.total(counts))
+		mat := make(map[interface{}]map[int]int64, len(keys))
+		var pos int
+		for j, key := range keys {
+			scores := make(map[int]int64, counts[j])
+			for k := 0; k < counts[j]; k++ {
+				scores[int(binary.LittleEndian.Uint32(transitions[pos*4:]))] = int64(binary.LittleEndian.Uint64(weights[pos*8:]))
+				pos++
+			}
+			mat[key] = scores
+		}
+		s.Mat[i] = mat
+	}
+	return s, nil
+}
+
+// BinaryTemplates returns the number of feature templates of weights
+// written by WriteBinary, without reading them
+func BinaryTemplates(data []byte) (templates int, err error) {
+	r := &binaryReader{data: data}
+	defer r.recover(&err)
+	r.header()
+	return int(r.uvarint()), nil
+}
+
+type binaryWriter struct {
+	w       *bufio.Writer
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (w *binaryWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(w.scratch[:], v)
+	w.w.Write(w.scratch[:n])
+}
+
+func (w *binaryWriter) varint(v int64) {
+	n := binary.PutVarint(w.scratch[:], v)
+	w.w.Write(w.scratch[:n])
+}
+
+func (w *binaryWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.w.WriteString(s)
+}
+
+func (w *binaryWriter) template(data map[interface{}]map[int]int64) error {
+	// sorted as in AvgSparse.Deserialize, so the output is deterministic
+	keys := make(util.ByGeneric, 0, len(data))
+	for k := range data {
+		keys = append(keys, util.Generic{Key: fmt.Sprintf("%v", k), Value: k})
+	}
+	sort.Sort(keys)
+	w.uvarint(uint64(len(keys)))
+	for _, k := range keys {
+		key := k.Value
+		if err := w.value(reflect.ValueOf(&key).Elem()); err != nil {
+			return err
+		}
+	}
+	transitions := make([][]int, len(keys))
+	for i, k := range keys {
+		for transition := range data[k.Value] {
+			transitions[i] = append(transitions[i], transition)
+		}
+		sort.Ints(transitions[i])
+		w.uvarint(uint64(len(transitions[i])))
+	}
+	var scratch [8]byte
+	for _, keyTransitions := range transitions {
+		for _, transition := range keyTransitions {
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(transition))
+			w.w.Write(scratch[:4])
+		}
+	}
+	for i, k := range keys {
+		for _, transition := range transitions[i] {
+			binary.LittleEndian.PutUint64(scratch[:], uint64(data[k.Value][transition]))
+			w.w.Write(scratch[:])
+		}
+	}
+	return nil
+}
+
+// writeType writes the type of a feature key element: its kind, and for
+// arrays their length and element type
+func (w *binaryWriter) writeType(t reflect.Type) error {
+	switch t.Kind() {
+	case reflect.Array:
+		w.w.WriteByte(byte(reflect.Array))
+		w.uvarint(uint64(t.Len()))
+		return w.writeType(t.Elem())
+	case reflect.Interface:
+		if t != interfaceType {
+			return fmt.Errorf("unsupported feature key type %v", t)
+		}
+	default:
+		if basicTypes[t.Kind()] != t {
+			return fmt.Errorf("unsupported feature key type %v", t)
+		}
+	}
+	w.w.WriteByte(byte(t.Kind()))
+	return nil
+}
+
+// value writes a feature key element; values of interface elements are
+// preceded by their type (reflect.Invalid for nil)
+func (w *binaryWriter) value(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			w.w.WriteByte(byte(reflect.Invalid))
+			return nil
+		}
+		if err := w.writeType(v.Elem().Type()); err != nil {
+			return err
+		}
+		return w.value(v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := w.value(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Bool:
+		if v.Bool() {
+			w.w.WriteByte(1)
+		} else {
+			w.w.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.varint(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		w.uvarint(v.Uint())
+	case reflect.Float64:
+		w.uvarint(math.Float64bits(v.Float()))
+	case reflect.String:
+		w.string(v.String())
+	default:
+		return fmt.Errorf("unsupported feature key type %v", v.Type())
+	}
+	return nil
+}
+
+type binaryReader struct {
+	data []byte
+	pos  int
+}
+
+// binaryError is panicked by binaryReader on malformed data
+type binaryError string
+
+func (r *binaryReader) recover(err *error) {
+	if rec := recover(); rec != nil {
+		if e, ok := rec.(binaryError); ok {
+			*err = fmt.Errorf("%v at byte %v", string(e), r.pos)
+			return
+		}
+		panic(rec)
+	}
+}
+
+func (r *binaryReader) header() (int, []string) {
+	if !IsBinary(r.data) {
+		panic(binaryError("not binary weights"))
+	}
+	r.pos = len(BINARY_MAGIC)
+	generation := int(r.uvarint())
+	names := make([]string, r.count())
+	for i := range names {
+		names[i] = string(r.bytes(int(r.uvarint())))
+	}
+	return generation, names
+}
+
+func (r *binaryReader) keys() ([]interface{}, []int) {
+	keys := make([]interface{}, r.count())
+	for i := range keys {
+		keys[i] = r.value(interfaceType).Interface()
+	}
+	counts := make([]int, len(keys))
+	for i := range counts {
+		counts[i] = r.count()
+	}
+	return keys, counts
+}
+
+// total returns the number of weights of a template, each taking 12 bytes
+func (r *binaryReader) total(counts []int) int {
+	var total int
+	for _, count := range counts {
+		total += count
+		if total > (len(r.data)-r.pos)/12 {
+			panic(binaryError("truncated binary weights"))
+		}
+	}
+	return total
+}
+
+// arrays returns the flat arrays of a template's transitions and weights
+func (r *binaryReader) arrays(n int) ([]byte, []byte) {
+	return r.bytes(n * 4), r.bytes(n * 8)
+}
+
+func (r *binaryReader) bytes(n int) []byte {
+	if n < 0 || r.pos+n > len(r.data) {
+		panic(binaryError("truncated binary weights"))
+	}
+	r.pos += n
+	return r.data[r.pos-n : r.pos]
+}
+
+// count reads a number of elements, each taking at least a byte, so that
+// corrupt data can't allocate more than its size
+func (r *binaryReader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data)-r.pos) {
+		panic(binaryError("truncated binary weights"))
+	}
+	return int(n)
+}
+
+func (r *binaryReader) byte() byte {
+	return r.bytes(1)[0]
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic(binaryError("truncated binary weights"))
+	}
+	r.pos += n
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	v, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		panic(binaryError("truncated binary weights"))
+	}
+	r.pos += n
+	return v
+}
+
+// readType reads the type of a feature key element of the given kind; the
+// elements of arrays (of arrays) take at least a byte each
+func (r *binaryReader) readType(kind reflect.Kind) reflect.Type {
+	return r.readArrayType(kind, 1)
+}
+
+func (r *binaryReader) readArrayType(kind reflect.Kind, elements int) reflect.Type {
+	switch kind {
+	case reflect.Array:
+		length := r.count()
+		if length > 0 && elements*length > len(r.data)-r.pos {
+			panic(binaryError("truncated binary weights"))
+		}
+		return reflect.ArrayOf(length, r.readArrayType(reflect.Kind(r.byte()), elements*util.Max(length, 1)))
+	case reflect.Interface:
+		return interfaceType
+	}
+	t, exists := basicTypes[kind]
+	if !exists {
+		panic(binaryError(fmt.Sprintf("unknown feature key kind %v", kind)))
+	}
+	return t
+}
+
+func (r *binaryReader) value(t reflect.Type) reflect.Value {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Interface:
+		kind := reflect.Kind(r.byte())
+		if kind == reflect.Invalid {
+			return v
+		}
+		v.Set(r.value(r.readType(kind)))
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			v.Index(i).Set(r.value(t.Elem()))
+		}
+	case reflect.Bool:
+		v.SetBool(r.byte() != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(r.varint())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(r.uvarint())
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(r.uvarint()))
+	case reflect.String:
+		v.SetString(string(r.bytes(int(r.uvarint()))))
+	}
+	return v
+}
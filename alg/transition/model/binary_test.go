@@ -0,0 +1,146 @@
+package model
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// testSerialized has feature keys of the types templates generate:
+// strings, numbers and arrays of interfaces (some nil) or numbers
+func testSerialized() *AvgMatrixSparseSerialized {
+	return &AvgMatrixSparseSerialized{
+		Generation: 42,
+		Features:   []string{"s0|w", "s0|p+n0|p", "s0|l+d"},
+		Mat: []interface{}{
+			map[interface{}]map[int]int64{
+				"dog": {1: 5, 7: -3},
+				"":    {0: 1},
+				"כלב": {2: math.MaxInt64, 3: math.MinInt64},
+			},
+			map[interface{}]map[int]int64{
+				[2]interface{}{"dog", "NN"}:     {2: 100},
+				[3]interface{}{1, "x", nil}:     {3: -1, 4: 2},
+				[2]interface{}{[2]int{1, 2}, 3}: {5: 6},
+			},
+			map[interface{}]map[int]int64{
+				5:            {0: 7},
+				[2]int{1, 2}: {1: -8},
+				2.5:          {9: 10},
+			},
+		},
+	}
+}
+
+func gobRoundTrip(t *testing.T, s *AvgMatrixSparseSerialized) *AvgMatrixSparseSerialized {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		t.Fatal(err)
+	}
+	decoded := &AvgMatrixSparseSerialized{}
+	if err := gob.NewDecoder(&buf).Decode(decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func writeBinary(t *testing.T, s *AvgMatrixSparseSerialized) []byte {
+	var buf bytes.Buffer
+	if err := s.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	fromGob := gobRoundTrip(t, testSerialized())
+	data := writeBinary(t, fromGob)
+	if !IsBinary(data) {
+		t.Fatal("binary weights without magic")
+	}
+	if !bytes.Equal(data, writeBinary(t, fromGob)) {
+		t.Error("binary weights are not deterministic")
+	}
+
+	serialized, err := ReadBinarySerialized(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(serialized, fromGob) {
+		t.Errorf("binary weights read as %v, expected %v", serialized, fromGob)
+	}
+	if backToGob := gobRoundTrip(t, serialized); !reflect.DeepEqual(backToGob, fromGob) {
+		t.Errorf("binary weights converted to gob as %v, expected %v", backToGob, fromGob)
+	}
+	if templates, err := BinaryTemplates(data); err != nil || templates != len(fromGob.Mat) {
+		t.Errorf("%v templates (%v), expected %v", templates, err, len(fromGob.Mat))
+	}
+
+	// read into a model, as if deserialized from gob
+	expected := &AvgMatrixSparse{}
+	expected.Deserialize(fromGob)
+	m := &AvgMatrixSparse{}
+	n, err := m.ReadBinary(append(data, "trailing"...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) {
+		t.Errorf("read %v bytes of weights, expected %v", n, len(data))
+	}
+	if m.Generation != expected.Generation || m.Features != expected.Features {
+		t.Errorf("read generation %v features %v, expected %v %v", m.Generation, m.Features, expected.Generation, expected.Features)
+	}
+	for i, val := range expected.Mat {
+		if len(m.Mat[i].Vals) != len(val.Vals) {
+			t.Errorf("template %v: %v keys, expected %v", i, len(m.Mat[i].Vals), len(val.Vals))
+		}
+		for key, store := range fromGob.Mat[i].(map[interface{}]map[int]int64) {
+			for transition, weight := range store {
+				if value := m.Mat[i].Value(transition, key); value != weight {
+					t.Errorf("template %v: weight of %v %v is %v, expected %v", i, key, transition, value, weight)
+				}
+			}
+		}
+	}
+	if !reflect.DeepEqual(m.Serialize(-1), expected.Serialize(-1)) {
+		t.Error("model read from binary weights serializes differently than from gob")
+	}
+}
+
+func TestBinaryTruncated(t *testing.T) {
+	data := writeBinary(t, testSerialized())
+	for n := 0; n < len(data); n++ {
+		if _, err := ReadBinarySerialized(data[:n]); err == nil {
+			t.Errorf("weights truncated to %v of %v bytes read", n, len(data))
+		}
+		if _, err := (&AvgMatrixSparse{}).ReadBinary(data[:n]); err == nil {
+			t.Errorf("weights truncated to %v of %v bytes read into model", n, len(data))
+		}
+	}
+}
+
+// corrupt weights (checked by the model file checksum) must fail or read
+// some weights, without panicking or allocating beyond their size
+func TestBinaryCorrupted(t *testing.T) {
+	data := writeBinary(t, testSerialized())
+	for i := range data {
+		for _, corruption := range []byte{0x01, 0x80, 0xff} {
+			corrupted := append([]byte(nil), data...)
+			corrupted[i] ^= corruption
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("byte %v ^ %#x: panicked: %v", i, corruption, r)
+					}
+				}()
+				ReadBinarySerialized(corrupted)
+				(&AvgMatrixSparse{}).ReadBinary(corrupted)
+			}()
+		}
+	}
+	if _, err := ReadBinarySerialized(append([]byte("YAPW\x00\x02"), data[len(BINARY_MAGIC):]...)); err == nil {
+		t.Error("read weights with a wrong magic")
+	}
+}
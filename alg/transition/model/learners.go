@@ -0,0 +1,229 @@
+package model
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+
+	. "yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+)
+
+const (
+	LEARNER_PERCEPTRON = "perceptron"
+	LEARNER_PA         = "pa"
+	LEARNER_ADAGRAD    = "adagrad"
+
+	// LEARNER_SCALE is the weight of a step of 1.0 of the real valued
+	// learners; the integer weights of a model trained by them are scaled by it
+	LEARNER_SCALE = 10000
+)
+
+var Learners = []string{LEARNER_PERCEPTRON, LEARNER_PA, LEARNER_ADAGRAD}
+
+// NewLearner returns the named learner, with its aggressiveness (pa) or
+// learning rate (adagrad)
+func NewLearner(name string, rate float64) (perceptron.Learner, error) {
+	switch name {
+	case LEARNER_PERCEPTRON:
+		return &perceptron.PerceptronLearner{}, nil
+	case LEARNER_PA:
+		return &PassiveAggressive{C: rate}, nil
+	case LEARNER_ADAGRAD:
+		return &AdaGrad{Eta: rate}, nil
+	default:
+		return nil, fmt.Errorf("unknown learner %v", name)
+	}
+}
+
+// featureKey is a weight of an AvgMatrixSparse
+type featureKey struct {
+	Template, Transition int
+	Feature              interface{}
+}
+
+// featureDelta is the difference between the gold and decoded feature
+// counts of an update, for the transitions of both feature lists (see
+// AvgMatrixSparse.AddSubtract), and the Hamming distance between their
+// transitions aligned from the first; the transitions only one of them has
+// are all errors
+func featureDelta(goldFeatures, decodedFeatures interface{}) (map[featureKey]int64, int) {
+	var (
+		delta = make(map[featureKey]int64)
+		loss  int
+	)
+	gold := featuresSequence(goldFeatures.(*transition.FeaturesList))
+	decoded := featuresSequence(decodedFeatures.(*transition.FeaturesList))
+	for i := 0; i < len(gold) || i < len(decoded); i++ {
+		switch {
+		case i >= len(decoded):
+			addFeatures(delta, gold[i], 1)
+			loss++
+		case i >= len(gold):
+			addFeatures(delta, decoded[i], -1)
+			loss++
+		default:
+			addFeatures(delta, gold[i], 1)
+			addFeatures(delta, decoded[i], -1)
+			if !gold[i].Transition.Equal(decoded[i].Transition) {
+				loss++
+			}
+		}
+	}
+	for key, count := range delta {
+		if count == 0 {
+			delete(delta, key)
+		}
+	}
+	return delta, loss
+}
+
+// featuresSequence returns the nodes of a feature list which have
+// transition features, first transition first
+func featuresSequence(f *transition.FeaturesList) []*transition.FeaturesList {
+	var seq []*transition.FeaturesList
+	for ; f != nil && f.Previous != nil; f = f.Previous {
+		seq = append(seq, f)
+	}
+	for i, j := 0, len(seq)-1; i < j; i, j = i+1, j-1 {
+		seq[i], seq[j] = seq[j], seq[i]
+	}
+	return seq
+}
+
+// addFeatures adds the features of the last transition of f to delta, as
+// AvgMatrixSparse.apply
+func addFeatures(delta map[featureKey]int64, f *transition.FeaturesList, amount int64) {
+	intTrans := f.Transition.Value()
+	for i, feature := range f.Previous.Features {
+		switch feat := feature.(type) {
+		case nil:
+		case []interface{}:
+			for _, generatedFeat := range feat {
+				delta[featureKey{i, intTrans, generatedFeat}] += amount
+			}
+		case TAF:
+			for taf, transitions := range feat.GetTransFeatures() {
+				if _, tExists := transitions[intTrans]; tExists {
+					delta[featureKey{i, intTrans, taf}] += amount
+				}
+			}
+		default:
+			delta[featureKey{i, intTrans, feat}] += amount
+		}
+	}
+}
+
+// addWeights adds the amount of each weight to the model
+func (t *AvgMatrixSparse) addWeights(amounts map[featureKey]int64) {
+	var wg sync.WaitGroup
+	for key, amount := range amounts {
+		if amount == 0 {
+			continue
+		}
+		wg.Add(1)
+		t.Mat[key.Template].Add(t.Generation, key.Transition, key.Feature, amount, &wg)
+	}
+	wg.Wait()
+}
+
+// PassiveAggressive is the PA-I (1-best MIRA) learner: the step of each
+// update is the smallest that makes the gold outscore the decoded features
+// by their loss, the Hamming distance between their transitions (counting
+// both morphological and attachment errors), capped by the aggressiveness C
+type PassiveAggressive struct {
+	C float64
+}
+
+var _ perceptron.Learner = &PassiveAggressive{}
+
+func (l *PassiveAggressive) Init(m perceptron.Model) {
+	if _, ok := m.(*AvgMatrixSparse); !ok {
+		panic("PassiveAggressive requires AvgMatrixSparse model")
+	}
+}
+
+func (l *PassiveAggressive) Learn(m perceptron.Model, goldFeatures, decodedFeatures interface{}) {
+	t := m.(*AvgMatrixSparse)
+	delta, loss := featureDelta(goldFeatures, decodedFeatures)
+	var margin, norm float64
+	for key, count := range delta {
+		margin += float64(count * t.Mat[key.Template].Value(key.Transition, key.Feature))
+		norm += float64(count * count)
+	}
+	if norm == 0 {
+		return
+	}
+	step := (float64(loss) - margin/LEARNER_SCALE) / norm
+	if step <= 0 {
+		return
+	}
+	step = math.Min(step, l.C)
+	if perceptron.PercepAllOut {
+		log.Println("PA step", step, "loss", loss, "margin", margin/LEARNER_SCALE)
+	}
+	amounts := make(map[featureKey]int64, len(delta))
+	for key, count := range delta {
+		amounts[key] = int64(math.Round(step * LEARNER_SCALE * float64(count)))
+	}
+	t.addWeights(amounts)
+}
+
+// AdaGrad is the perceptron update with a per weight learning rate,
+// Eta divided by the root of the sum of its squared updates
+type AdaGrad struct {
+	Eta float64
+
+	// sums of squared updates, shared by models trained in parallel
+	Sums  map[featureKey]float64
+	mutex sync.Mutex
+}
+
+var _ perceptron.Learner = &AdaGrad{}
+
+func (l *AdaGrad) Init(m perceptron.Model) {
+	if _, ok := m.(*AvgMatrixSparse); !ok {
+		panic("AdaGrad requires AvgMatrixSparse model")
+	}
+	l.Sums = make(map[featureKey]float64)
+}
+
+func (l *AdaGrad) Learn(m perceptron.Model, goldFeatures, decodedFeatures interface{}) {
+	t := m.(*AvgMatrixSparse)
+	delta, _ := featureDelta(goldFeatures, decodedFeatures)
+	amounts := make(map[featureKey]int64, len(delta))
+	l.mutex.Lock()
+	for key, count := range delta {
+		gradient := float64(count)
+		l.Sums[key] += gradient * gradient
+		amounts[key] = int64(math.Round(l.Eta * LEARNER_SCALE * gradient / math.Sqrt(l.Sums[key])))
+	}
+	l.mutex.Unlock()
+	t.addWeights(amounts)
+}
+
+// AdaGradSum is the sum of squared updates of a weight, for checkpoints
+type AdaGradSum struct {
+	Template, Transition int
+	Feature              interface{}
+	Sum                  float64
+}
+
+// State returns the sums of squared updates, to resume training with SetState
+func (l *AdaGrad) State() []AdaGradSum {
+	state := make([]AdaGradSum, 0, len(l.Sums))
+	for key, sum := range l.Sums {
+		state = append(state, AdaGradSum{key.Template, key.Transition, key.Feature, sum})
+	}
+	return state
+}
+
+// SetState sets the sums of squared updates of an initialized learner
+func (l *AdaGrad) SetState(state []AdaGradSum) {
+	l.Sums = make(map[featureKey]float64, len(state))
+	for _, sum := range state {
+		l.Sums[featureKey{sum.Template, sum.Transition, sum.Feature}] = sum.Sum
+	}
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	. "yap/alg/featurevector"
+	"yap/alg/transition"
+)
+
+// featuresList chains the features and transitions of a sequence, first
+// transition first, as the decoders build them: each node holds the
+// features its transition was scored with in its predecessor
+func featuresList(features []Feature, transitions ...int) *transition.FeaturesList {
+	list := &transition.FeaturesList{features, transition.ConstTransition(0), nil}
+	for _, t := range transitions {
+		list = &transition.FeaturesList{features, transition.ConstTransition(t), list}
+	}
+	return list
+}
+
+func TestFeatureDeltaUnequalLengths(t *testing.T) {
+	features := []Feature{"a"}
+	delta, loss := featureDelta(featuresList(features, 1, 3, 4), featuresList(features, 1, 2))
+	// aligned from the first transition: 1=1, 3!=2, and 4 is gold only
+	if loss != 2 {
+		t.Errorf("loss %v, expected 2", loss)
+	}
+	expected := map[featureKey]int64{
+		{0, 3, "a"}: 1,
+		{0, 4, "a"}: 1,
+		{0, 2, "a"}: -1,
+	}
+	if len(delta) != len(expected) {
+		t.Errorf("delta %v, expected %v", delta, expected)
+	}
+	for key, count := range expected {
+		if delta[key] != count {
+			t.Errorf("delta of %v is %v, expected %v", key, delta[key], count)
+		}
+	}
+
+	// symmetric for decoded transitions past the gold's
+	delta, loss = featureDelta(featuresList(features, 1, 2), featuresList(features, 1, 3, 4))
+	if loss != 2 || delta[featureKey{0, 4, "a"}] != -1 || delta[featureKey{0, 2, "a"}] != 1 {
+		t.Errorf("loss %v delta %v of decoded longer than gold", loss, delta)
+	}
+}
+
+func TestPassiveAggressiveStep(t *testing.T) {
+	features := []Feature{"a", "b"}
+	gold, decoded := featuresList(features, 1), featuresList(features, 2)
+	m := NewAvgMatrixSparse(len(features), nil, false)
+	learner := &PassiveAggressive{C: 1}
+	learner.Init(m)
+
+	// loss 1, margin 0, norm 4 (+1 twice, -1 twice): tau = 1/4
+	learner.Learn(m, gold, decoded)
+	for i, feature := range features {
+		if value := m.Mat[i].Value(1, feature); value != 2500 {
+			t.Errorf("gold weight of %v is %v, expected 2500", feature, value)
+		}
+		if value := m.Mat[i].Value(2, feature); value != -2500 {
+			t.Errorf("decoded weight of %v is %v, expected -2500", feature, value)
+		}
+	}
+
+	// the gold now outscores the decoded by its loss: no update
+	learner.Learn(m, gold, decoded)
+	if value := m.Mat[0].Value(1, "a"); value != 2500 {
+		t.Errorf("weight %v after separating update, expected 2500", value)
+	}
+
+	// loss 2 of gold 1, 3 vs decoded 2, margin 1 (4 times 0.25 of
+	// transition 1 vs 2), norm 6: tau = (2 - 1) / 6
+	learner.Learn(m, featuresList(features, 1, 3), decoded)
+	if value := m.Mat[0].Value(3, "a"); value != 1667 {
+		t.Errorf("gold only weight %v, expected 1667", value)
+	}
+	if value := m.Mat[0].Value(2, "a"); value != -2500-1667 {
+		t.Errorf("decoded weight %v, expected %v", value, -2500-1667)
+	}
+}
+
+func TestPassiveAggressiveCap(t *testing.T) {
+	features := []Feature{"a"}
+	m := NewAvgMatrixSparse(len(features), nil, false)
+	learner := &PassiveAggressive{C: 0.1}
+	learner.Init(m)
+
+	// tau = 1/2 capped at C
+	learner.Learn(m, featuresList(features, 1), featuresList(features, 2))
+	if value := m.Mat[0].Value(1, "a"); value != 1000 {
+		t.Errorf("capped weight %v, expected 1000", value)
+	}
+}
+
+func TestAdaGradRates(t *testing.T) {
+	features := []Feature{"a"}
+	gold, decoded := featuresList(features, 1), featuresList(features, 2)
+	m := NewAvgMatrixSparse(len(features), nil, false)
+	learner := &AdaGrad{Eta: 1}
+	learner.Init(m)
+
+	learner.Learn(m, gold, decoded)
+	if value := m.Mat[0].Value(1, "a"); value != 10000 {
+		t.Errorf("weight %v after first update, expected 10000", value)
+	}
+	if sum := learner.Sums[featureKey{0, 1, "a"}]; sum != 1 {
+		t.Errorf("sum %v after first update, expected 1", sum)
+	}
+
+	// the rate of a weight decays with its own updates only
+	learner.Learn(m, gold, featuresList(features, 3))
+	expected := int64(10000 + math.Round(10000/math.Sqrt(2)))
+	if value := m.Mat[0].Value(1, "a"); value != expected {
+		t.Errorf("weight %v after second update, expected %v", value, expected)
+	}
+	if value := m.Mat[0].Value(3, "a"); value != -10000 {
+		t.Errorf("weight %v of a new feature, expected -10000", value)
+	}
+	for key, expected := range map[featureKey]float64{
+		{0, 1, "a"}: 2,
+		{0, 2, "a"}: 1,
+		{0, 3, "a"}: 1,
+	} {
+		if sum := learner.Sums[key]; sum != expected {
+			t.Errorf("sum of %v is %v, expected %v", key, sum, expected)
+		}
+	}
+
+	// checkpoint state round trip
+	resumed := &AdaGrad{Eta: 1}
+	resumed.Init(m)
+	resumed.SetState(learner.State())
+	if len(resumed.Sums) != len(learner.Sums) {
+		t.Fatalf("resumed %v sums, expected %v", len(resumed.Sums), len(learner.Sums))
+	}
+	for key, sum := range learner.Sums {
+		if resumed.Sums[key] != sum {
+			t.Errorf("resumed sum of %v is %v, expected %v", key, resumed.Sums[key], sum)
+		}
+	}
+}
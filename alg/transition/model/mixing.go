@@ -0,0 +1,52 @@
+package model
+
+import (
+	"math"
+
+	. "yap/alg/featurevector"
+	"yap/alg/perceptron"
+)
+
+// ParameterMixer mixes AvgMatrixSparse models trained in parallel; the
+// weights of the models are integers, so they should be trained with
+// steps of LEARNER_SCALE or larger to keep their precision when mixed
+type ParameterMixer struct{}
+
+var _ perceptron.Mixer = &ParameterMixer{}
+
+func (p *ParameterMixer) Local(m perceptron.Model) perceptron.Model {
+	t := m.(*AvgMatrixSparse)
+	local := &AvgMatrixSparse{
+		Mat:        make([]*AvgSparse, len(t.Mat)),
+		Features:   t.Features,
+		Formatters: t.Formatters,
+		Extractor:  t.Extractor,
+	}
+	for i, val := range t.Mat {
+		local.Mat[i] = val.CopyValues()
+	}
+	return local
+}
+
+// Mix adds the difference between the mixed weights and the model's to it,
+// at its current generation, keeping its averaging history
+func (p *ParameterMixer) Mix(m perceptron.Model, locals []perceptron.Model, weights []float64) {
+	t := m.(*AvgMatrixSparse)
+	for i := range t.Mat {
+		mixed := make(map[featureKey]float64)
+		for l, local := range locals {
+			for feature, store := range local.(*AvgMatrixSparse).Mat[i].Vals {
+				store.Each(func(transition int, value *HistoryValue) {
+					if value != nil {
+						mixed[featureKey{i, transition, feature}] += weights[l] * float64(value.Value)
+					}
+				})
+			}
+		}
+		amounts := make(map[featureKey]int64, len(mixed))
+		for key, value := range mixed {
+			amounts[key] = int64(math.Round(value)) - t.Mat[i].Value(key.Transition, key.Feature)
+		}
+		t.addWeights(amounts)
+	}
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+
+	"yap/alg/perceptron"
+)
+
+func TestParameterMixerLocal(t *testing.T) {
+	m := NewAvgMatrixSparse(1, nil, false)
+	m.addWeights(map[featureKey]int64{{0, 1, "x"}: 10000})
+	local := (&ParameterMixer{}).Local(m).(*AvgMatrixSparse)
+	local.addWeights(map[featureKey]int64{{0, 1, "x"}: 5, {0, 2, "y"}: 3})
+	if value := local.Mat[0].Value(1, "x"); value != 10005 {
+		t.Errorf("local weight %v, expected 10005", value)
+	}
+	if value, other := m.Mat[0].Value(1, "x"), m.Mat[0].Value(2, "y"); value != 10000 || other != 0 {
+		t.Errorf("model weights %v %v changed by its local copy", value, other)
+	}
+}
+
+func TestParameterMixerMix(t *testing.T) {
+	for _, test := range []struct {
+		Name     string
+		Weights  []float64
+		Expected map[featureKey]int64
+	}{
+		// y and z are mixed to 1.5 and 2.5, rounded away from zero
+		{"uniform", []float64{0.5, 0.5}, map[featureKey]int64{
+			{0, 1, "x"}: 15000, {0, 2, "y"}: 2, {0, 3, "z"}: 3, {0, 4, "w"}: -5000,
+		}},
+		{"errors", []float64{0.25, 0.75}, map[featureKey]int64{
+			{0, 1, "x"}: 17500, {0, 2, "y"}: 1, {0, 3, "z"}: 4, {0, 4, "w"}: -2500,
+		}},
+	} {
+		m := NewAvgMatrixSparse(1, nil, false)
+		m.addWeights(map[featureKey]int64{{0, 1, "x"}: 10000, {0, 4, "w"}: -10000})
+		mixer := &ParameterMixer{}
+		first, second := mixer.Local(m).(*AvgMatrixSparse), mixer.Local(m).(*AvgMatrixSparse)
+		first.addWeights(map[featureKey]int64{{0, 2, "y"}: 3})
+		second.addWeights(map[featureKey]int64{{0, 1, "x"}: 10000, {0, 3, "z"}: 5, {0, 4, "w"}: 10000})
+		mixer.Mix(m, []perceptron.Model{first, second}, test.Weights)
+		for key, expected := range test.Expected {
+			if value := m.Mat[0].Value(key.Transition, key.Feature); value != expected {
+				t.Errorf("%v: mixed weight of %v is %v, expected %v", test.Name, key, value, expected)
+			}
+		}
+	}
+}
+
+// with steps of 1 a perceptron update is rounded away by uniform mixing,
+// or doubled; see LEARNER_SCALE
+func TestParameterMixerScale(t *testing.T) {
+	for _, step := range []int64{1, LEARNER_SCALE} {
+		m := NewAvgMatrixSparse(1, nil, false)
+		mixer := &ParameterMixer{}
+		first, second := mixer.Local(m).(*AvgMatrixSparse), mixer.Local(m).(*AvgMatrixSparse)
+		first.addWeights(map[featureKey]int64{{0, 1, "x"}: step, {0, 2, "x"}: -step})
+		second.addWeights(map[featureKey]int64{{0, 3, "x"}: 2 * step})
+		mixer.Mix(m, []perceptron.Model{first, second}, []float64{0.5, 0.5})
+		expected := map[int]float64{1: 0.5, 2: -0.5, 3: 1}
+		for transition, weight := range expected {
+			value := m.Mat[0].Value(transition, "x")
+			exact := float64(value) == weight*float64(step)
+			if step == LEARNER_SCALE && !exact {
+				t.Errorf("weight of %v is %v, expected %v", transition, value, weight*float64(step))
+			}
+			if step == 1 && transition != 3 && exact {
+				t.Errorf("weight of %v is %v, expected to be rounded", transition, value)
+			}
+		}
+	}
+}
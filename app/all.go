@@ -31,6 +31,8 @@ var AppCommands = []*commander.Command{
 	//MALearnCmd(),
 	MACmd(),
 	HebMACmd(),
+	TokenizeCmd(),
+	ModelCmd(),
 	// ValidateMAGoldCmd(),
 	// GenLemmasCmd(),
 	// GenUnAmbLemmasCmd(),
@@ -45,6 +47,10 @@ func AllCommands() *commander.Command {
 		Flag:        *flag.NewFlagSet("app", flag.ExitOnError),
 	}
 	for _, app := range cmd.Subcommands {
+		if app.Run == nil {
+			// command groups (model) only dispatch to their subcommands
+			continue
+		}
 		app.Run = NewAppWrapCommand(app.Run)
 		app.Flag.IntVar(&CPUs, NUM_CPUS_FLAG, 0, "Max CPUS to use (runtime.GOMAXPROCS); 0 = all")
 		app.Flag.StringVar(&CPUProfile, "cpuprofile", "", "write cpu profile to file")
@@ -0,0 +1,228 @@
+package app
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	"yap/alg/transition/model"
+	"yap/util"
+)
+
+var (
+	// training checkpoints, see Train
+	CheckpointFile  string
+	CheckpointEvery int
+	Resume          bool
+
+	// TrainEval is the state of the evaluation of training iterations (see
+	// the Make*EvalStopCondition functions)
+	TrainEval = &EvalState{}
+)
+
+// EvalState is the state kept between evaluations of training iterations
+type EvalState struct {
+	EqualIterations, ContinuousDecreases int
+	PrevResult, BestResult               float64
+	BestIteration                        int
+	BestModelFile                        string
+}
+
+// Checkpoint is the state of a training run, from which it can be resumed
+// exactly where it stopped
+type Checkpoint struct {
+	Version int
+	Config  *ModelConfig
+	// number of training instances
+	Instances int
+	// training update strategy (see UpdateStrategy) and learner
+	Update  string
+	Learner string
+	// seed of the shuffling of training instances (see Seed)
+	Seed int64
+	// parallel training (see TrainWorkers)
+	TrainWorkers int
+	Mixing       string
+
+	// position of training, see perceptron.LinearPerceptron
+	TrainI, TrainJ, Generations, FailedInstances int
+	// updates of the model.AveragedModelStrategy
+	Updates int
+
+	Model *model.AvgMatrixSparseState
+	Eval  EvalState
+	// learner state, if it has any
+	AdaGrad []model.AdaGradSum
+
+	EWord, EPOS, EWPOS, EMHost, EMSuffix *util.EnumSet
+	EMorphProp, ETrans, ETokens          *util.EnumSet
+}
+
+func checkpointConfigOut() {
+	if len(CheckpointFile) == 0 {
+		log.Printf("Checkpoint:\t\tnone")
+		return
+	}
+	log.Printf("Checkpoint:\t\t%s (every %d instances)", CheckpointFile, CheckpointEvery)
+	log.Printf("Resume:\t\t%v", Resume)
+}
+
+// WriteCheckpoint writes the state of training to file, replacing the
+// previous checkpoint only once it is complete
+func WriteCheckpoint(file string, trainer *perceptron.LinearPerceptron, updater *model.AveragedModelStrategy, instances int) error {
+	checkpoint := &Checkpoint{
+		Version:         MODEL_VERSION,
+		Config:          TrainConfig,
+		Instances:       instances,
+		Update:          UpdateStrategy,
+		Learner:         LearnerName,
+		Seed:            Seed,
+		TrainWorkers:    TrainWorkers,
+		Mixing:          Mixing,
+		TrainI:          trainer.TrainI,
+		TrainJ:          trainer.TrainJ,
+		Generations:     trainer.Generations,
+		FailedInstances: trainer.FailedInstances,
+		Updates:         updater.N,
+		Model:           trainer.Model.(*model.AvgMatrixSparse).State(),
+		Eval:            *TrainEval,
+		EWord:           EWord,
+		EPOS:            EPOS,
+		EWPOS:           EWPOS,
+		EMHost:          EMHost,
+		EMSuffix:        EMSuffix,
+		EMorphProp:      EMorphProp,
+		ETrans:          ETrans,
+		ETokens:         ETokens,
+	}
+	if adaGrad, ok := trainer.Learner.(*model.AdaGrad); ok {
+		checkpoint.AdaGrad = adaGrad.State()
+	}
+	tempFile := file + ".tmp"
+	fObj, err := os.Create(tempFile)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(fObj).Encode(checkpoint); err != nil {
+		fObj.Close()
+		return err
+	}
+	if err := fObj.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, file)
+}
+
+// checkpointer returns the perceptron.LinearPerceptron Checkpoint function
+// writing checkpoints to file
+func checkpointer(file string, updater *model.AveragedModelStrategy, instances int) func(*perceptron.LinearPerceptron) {
+	return func(trainer *perceptron.LinearPerceptron) {
+		if err := WriteCheckpoint(file, trainer, updater, instances); err != nil {
+			log.Println("Failed writing checkpoint", file, err)
+			return
+		}
+		log.Println("Wrote checkpoint", file, "at iteration", trainer.TrainI, "instance", trainer.TrainJ)
+	}
+}
+
+// ReadCheckpoint reads a checkpoint written by WriteCheckpoint
+func ReadCheckpoint(file string) (*Checkpoint, error) {
+	fObj, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fObj.Close()
+	checkpoint := &Checkpoint{}
+	if err := gob.NewDecoder(fObj).Decode(checkpoint); err != nil {
+		return nil, fmt.Errorf("%v: not a checkpoint file or truncated: %v", file, err)
+	}
+	if checkpoint.Version > MODEL_VERSION {
+		return nil, fmt.Errorf("%v: checkpoint format version %v is newer than supported (%v)", file, checkpoint.Version, MODEL_VERSION)
+	}
+	return checkpoint, nil
+}
+
+// ResumeCheckpoint restores the state of training from a checkpoint; the
+// training data and configuration must be the ones it was written with
+func ResumeCheckpoint(checkpoint *Checkpoint, trainer *perceptron.LinearPerceptron, updater *model.AveragedModelStrategy, instances int) error {
+	if !reflect.DeepEqual(checkpoint.Config, TrainConfig) {
+		return fmt.Errorf("checkpoint was written with another training configuration")
+	}
+	if checkpoint.Instances != instances {
+		return fmt.Errorf("checkpoint was written with %v training instances, got %v", checkpoint.Instances, instances)
+	}
+	// checkpoints written before the update strategy and learner were
+	// selectable used the defaults
+	if len(checkpoint.Update) == 0 {
+		checkpoint.Update = search.UPDATE_EARLY
+	}
+	if len(checkpoint.Learner) == 0 {
+		checkpoint.Learner = model.LEARNER_PERCEPTRON
+	}
+	if checkpoint.Update != UpdateStrategy {
+		return fmt.Errorf("checkpoint was written with the %v update strategy, got %v", checkpoint.Update, UpdateStrategy)
+	}
+	if checkpoint.Learner != LearnerName {
+		return fmt.Errorf("checkpoint was written with the %v learner, got %v", checkpoint.Learner, LearnerName)
+	}
+	if checkpoint.Seed != Seed {
+		return fmt.Errorf("checkpoint was written with -seed %v, got %v", checkpoint.Seed, Seed)
+	}
+	if checkpoint.TrainWorkers == 0 {
+		checkpoint.TrainWorkers = 1
+	}
+	if checkpoint.TrainWorkers != TrainWorkers || (TrainWorkers > 1 && checkpoint.Mixing != Mixing) {
+		return fmt.Errorf("checkpoint was written with -train-workers %v (%v mixing), got %v (%v mixing)", checkpoint.TrainWorkers, checkpoint.Mixing, TrainWorkers, Mixing)
+	}
+	for _, enumSet := range []struct {
+		name           string
+		current, saved *util.EnumSet
+	}{
+		{"word", EWord, checkpoint.EWord}, {"POS", EPOS, checkpoint.EPOS}, {"word+POS", EWPOS, checkpoint.EWPOS},
+		{"morph host", EMHost, checkpoint.EMHost}, {"morph suffix", EMSuffix, checkpoint.EMSuffix},
+		{"morph properties", EMorphProp, checkpoint.EMorphProp}, {"transition", ETrans, checkpoint.ETrans},
+		{"token", ETokens, checkpoint.ETokens},
+	} {
+		if err := resumeEnumSet(enumSet.current, enumSet.saved); err != nil {
+			return fmt.Errorf("%v enum set: %v", enumSet.name, err)
+		}
+	}
+	if err := trainer.Model.(*model.AvgMatrixSparse).SetState(checkpoint.Model); err != nil {
+		return err
+	}
+	updater.N = checkpoint.Updates
+	if adaGrad, ok := trainer.Learner.(*model.AdaGrad); ok {
+		adaGrad.SetState(checkpoint.AdaGrad)
+	}
+	*TrainEval = checkpoint.Eval
+	trainer.Resume(checkpoint.TrainI, checkpoint.TrainJ, checkpoint.Generations, checkpoint.FailedInstances)
+	return nil
+}
+
+// resumeEnumSet adds to an enum set, enumerated from the training data, the
+// values enumerated after it during training; the values it has must be
+// the ones the saved enum set starts with
+func resumeEnumSet(current, saved *util.EnumSet) error {
+	if current == nil || saved == nil {
+		if current != saved && (current == nil || current.Len() > 0) && (saved == nil || saved.Len() > 0) {
+			return fmt.Errorf("missing in the checkpoint or the training setup")
+		}
+		return nil
+	}
+	if current.Len() > saved.Len() {
+		return fmt.Errorf("training data differs from the checkpoint's")
+	}
+	for i, value := range current.Index {
+		if !reflect.DeepEqual(value, saved.Index[i]) {
+			return fmt.Errorf("training data differs from the checkpoint's")
+		}
+	}
+	for _, value := range saved.Index[current.Len():] {
+		current.Add(value)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/alg/transition/model"
+	"yap/util"
+)
+
+// testSentence is a sequence of words labeled by a greedy testLabeler
+type testSentence struct {
+	Words  []string
+	Labels []int
+}
+
+func (s *testSentence) Equal(other util.Equaler) bool {
+	return reflect.DeepEqual(s, other.(*testSentence))
+}
+
+// testLabeler labels each word by its weights with the word and the
+// previous label, as features of a transition system would
+type testLabeler struct {
+	Labels int
+}
+
+func testFeatures(s *testSentence) *transition.FeaturesList {
+	list := &transition.FeaturesList{[]Feature{s.Words[0], 0}, transition.ConstTransition(0), nil}
+	for k, label := range s.Labels {
+		var features []Feature
+		if k+1 < len(s.Words) {
+			features = []Feature{s.Words[k+1], label}
+		}
+		list = &transition.FeaturesList{features, transition.ConstTransition(label), list}
+	}
+	return list
+}
+
+func (d *testLabeler) label(words []string, m *model.AvgMatrixSparse) *testSentence {
+	labeled := &testSentence{words, make([]int, len(words))}
+	prev := 0
+	for k, word := range words {
+		var best int64
+		for label := 0; label < d.Labels; label++ {
+			score := m.Mat[0].Value(label, word) + m.Mat[1].Value(label, prev)
+			if label == 0 || score > best {
+				labeled.Labels[k], best = label, score
+			}
+		}
+		prev = labeled.Labels[k]
+	}
+	return labeled
+}
+
+func (d *testLabeler) DecodeGold(i perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	return i, nil
+}
+
+func (d *testLabeler) DecodeEarlyUpdate(i perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
+	gold := i.Decoded().(*testSentence)
+	decoded := d.label(gold.Words, m.(*model.AvgMatrixSparse))
+	return &perceptron.Decoded{gold, decoded}, testFeatures(decoded), testFeatures(gold), -1, len(gold.Words), 0
+}
+
+func (d *testLabeler) Decode(i perceptron.Instance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	sent := i.(*testSentence)
+	decoded := d.label(sent.Words, m.(*model.AvgMatrixSparse))
+	return &perceptron.Decoded{sent, decoded}, testFeatures(decoded)
+}
+
+func testSentences() []perceptron.DecodedInstance {
+	var instances []perceptron.DecodedInstance
+	for _, sent := range []*testSentence{
+		{[]string{"the", "dog", "runs"}, []int{1, 2, 0}},
+		{[]string{"a", "run", "ends"}, []int{1, 2, 0}},
+		{[]string{"dogs", "run"}, []int{2, 0}},
+		{[]string{"the", "run"}, []int{1, 2}},
+		{[]string{"it", "ends", "the", "run"}, []int{2, 0, 1, 2}},
+		{[]string{"a", "dog", "ends", "a", "run"}, []int{1, 2, 0, 1, 2}},
+	} {
+		instances = append(instances, &perceptron.Decoded{sent, sent})
+	}
+	return instances
+}
+
+// errCrash stops training after a checkpoint is written
+type errCrash struct{}
+
+func TestCheckpointResume(t *testing.T) {
+	defer func(update, learner string, rate float64, seed int64, workers int, mixing string, eval EvalState) {
+		UpdateStrategy, LearnerName, LearnerRate, Seed, TrainWorkers, Mixing, *TrainEval = update, learner, rate, seed, workers, mixing, eval
+	}(UpdateStrategy, LearnerName, LearnerRate, Seed, TrainWorkers, Mixing, *TrainEval)
+	defer log.SetPrefix(log.Prefix())
+	UpdateStrategy, LearnerName, LearnerRate, Seed, TrainWorkers, Mixing = search.UPDATE_EARLY, model.LEARNER_ADAGRAD, 1, 7, 1, perceptron.MIX_UNIFORM
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "train.checkpoint")
+
+	instances := testSentences()
+	newTrainer := func() (*perceptron.LinearPerceptron, *model.AveragedModelStrategy) {
+		learner, err := newLearner()
+		if err != nil {
+			t.Fatal(err)
+		}
+		updater := &model.AveragedModelStrategy{}
+		trainer := &perceptron.LinearPerceptron{
+			Decoder:     &testLabeler{3},
+			GoldDecoder: &testLabeler{3},
+			Updater:     updater,
+			Iterations:  4,
+			Seed:        Seed,
+			Learner:     learner,
+			// stands in for the evaluation of iterations, kept in TrainEval
+			Continue: func(i, iterations, generations int, m perceptron.Model) bool {
+				TrainEval.EqualIterations++
+				TrainEval.PrevResult += float64(generations)
+				return i < iterations
+			},
+		}
+		trainer.Init(model.NewAvgMatrixSparse(2, nil, false))
+		return trainer, updater
+	}
+
+	*TrainEval = EvalState{}
+	straight, straightUpdater := newTrainer()
+	straight.Train(instances)
+	straightEval := *TrainEval
+
+	// crash in the middle of the second iteration, after a checkpoint
+	*TrainEval = EvalState{}
+	interrupted, updater := newTrainer()
+	interrupted.CheckpointEvery = 2
+	interrupted.Checkpoint = func(trainer *perceptron.LinearPerceptron) {
+		if err := WriteCheckpoint(file, trainer, updater, len(instances)); err != nil {
+			t.Fatal(err)
+		}
+		if trainer.TrainI == 1 && trainer.TrainJ == 3 {
+			panic(errCrash{})
+		}
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, crashed := r.(errCrash); !crashed {
+					panic(r)
+				}
+			}
+		}()
+		interrupted.Train(instances)
+		t.Fatal("training was not interrupted")
+	}()
+
+	*TrainEval = EvalState{}
+	checkpoint, err := ReadCheckpoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.TrainI != 1 || checkpoint.TrainJ != 3 {
+		t.Fatalf("checkpoint at iteration %v instance %v, expected 1 3", checkpoint.TrainI, checkpoint.TrainJ)
+	}
+	resumed, resumedUpdater := newTrainer()
+	if err := ResumeCheckpoint(checkpoint, resumed, resumedUpdater, len(instances)); err != nil {
+		t.Fatal(err)
+	}
+	resumed.Train(instances)
+
+	if len(straight.Learner.(*model.AdaGrad).Sums) == 0 {
+		t.Fatal("no updates were made")
+	}
+	if !reflect.DeepEqual(resumed.Model.(*model.AvgMatrixSparse).State(), straight.Model.(*model.AvgMatrixSparse).State()) {
+		t.Error("resumed training weights differ from those of training straight through")
+	}
+	if !reflect.DeepEqual(resumed.Learner.(*model.AdaGrad).Sums, straight.Learner.(*model.AdaGrad).Sums) {
+		t.Error("resumed AdaGrad sums differ from those of training straight through")
+	}
+	if resumedUpdater.N != straightUpdater.N || resumed.Generations != straight.Generations {
+		t.Errorf("resumed %v updates %v generations, expected %v %v", resumedUpdater.N, resumed.Generations, straightUpdater.N, straight.Generations)
+	}
+	if *TrainEval != straightEval {
+		t.Errorf("resumed evaluation state %v, expected %v", *TrainEval, straightEval)
+	}
+}
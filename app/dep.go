@@ -3,6 +3,7 @@ package app
 import (
 	// "yap/alg/featurevector"
 	"fmt"
+	"io"
 	"yap/alg/perceptron"
 	"yap/alg/search"
 	"yap/alg/transition"
@@ -13,7 +14,6 @@ import (
 	. "yap/nlp/parser/dependency/transition"
 	nlp "yap/nlp/types"
 	"yap/util"
-	"yap/util/conf"
 
 	"log"
 	"os"
@@ -61,20 +61,32 @@ func DepConfigOut(outModelFile string, b search.Interface, t transition.Transiti
 	log.Printf("Beam:             \t%s", b.Name())
 	log.Printf("Transition System:\t%s", t.Name())
 	log.Printf("Iterations:\t\t%d", Iterations)
+	log.Printf("Update:\t\t\t%s", UpdateStrategy)
+	orderConfigOut()
+	learnerConfigOut()
+	parallelConfigOut()
+	checkpointConfigOut()
 	log.Printf("Beam Size:\t\t%d", BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
+	log.Printf("Decode Workers:\t%v", DecodeWorkers)
+	kBestConfigOut()
 	log.Printf("Model file:\t\t%s", outModelFile)
 	log.Printf("Use Lemmas:\t\t%v", !lattice.IGNORE_LEMMA)
 	log.Printf("Word Type:\t\t%v", conll.WORD_TYPE)
 
 	log.Println()
-	log.Printf("Features File:\t%s", DepFeaturesFile)
-	if !VerifyExists(DepFeaturesFile) {
-		os.Exit(1)
-	}
-	log.Printf("Labels File:\t\t%s", DepLabelsFile)
-	if !VerifyExists(DepLabelsFile) {
-		os.Exit(1)
+	if config := LoadedConfigs[MODEL_DEP]; config != nil {
+		log.Printf("Features File:\t%s (saved in model)", config.FeaturesFile)
+		log.Printf("Labels:\t\t%d (saved in model)", len(config.Labels))
+	} else {
+		log.Printf("Features File:\t%s", DepFeaturesFile)
+		if !VerifyExists(DepFeaturesFile) {
+			os.Exit(1)
+		}
+		log.Printf("Labels File:\t\t%s", DepLabelsFile)
+		if !VerifyExists(DepLabelsFile) {
+			os.Exit(1)
+		}
 	}
 	log.Println()
 	log.Println("Data")
@@ -103,27 +115,6 @@ func DepConfigOut(outModelFile string, b search.Interface, t transition.Transiti
 }
 
 func DepTrainAndParse(cmd *commander.Command, args []string) error {
-	// instantiate the arc system for config output only
-	// it will be reinstantiated later on with struct values
-
-	var (
-		arcSystem     transition.TransitionSystem
-		terminalStack int
-	)
-	switch DepArcSystemStr {
-	case "standard":
-		arcSystem = &ArcStandard{}
-		terminalStack = 1
-	case "eager":
-		arcSystem = &ArcEager{}
-		terminalStack = 0
-	default:
-		panic("Unknown arc system")
-	}
-
-	arcSystem.AddDefaultOracle()
-
-	transitionSystem := transition.TransitionSystem(arcSystem)
 	REQUIRED_FLAGS := []string{"oc"}
 
 	featuresLocation, found := util.LocateFile(DepFeaturesFile, DEFAULT_CONF_DIRS)
@@ -144,6 +135,11 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "in")
 	}
 
+	verifyKBest()
+	verifyUpdateStrategy()
+	verifyLearner()
+	verifyParallel()
+
 	// RegisterTypes()
 	var (
 		outModelFile string                           = fmt.Sprintf("%s.b%d", DepModelFile, BeamSize)
@@ -159,16 +155,47 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Pre-trained model not found in default directories, looking for", outModelFile)
 		modelExists = VerifyExists(outModelFile)
 	}
+	var serialization *Serialization
 	if !modelExists {
 		log.Println("No model found, training")
 		REQUIRED_FLAGS = []string{"it", "tc"}
 		VerifyFlags(cmd, REQUIRED_FLAGS)
+	} else {
+		// the model's configuration sets the arc system, features and labels
+		var err error
+		serialization, err = LoadModel(outModelFile, MODEL_DEP)
+		if err != nil {
+			log.Println("Failed loading model:", outModelFile)
+			log.Fatalln(err)
+		}
+		ConfigureFromModel(cmd, MODEL_DEP, serialization.Config, DepModelFlags)
+	}
+
+	// instantiate the arc system for config output only
+	// it will be reinstantiated later on with struct values
+	var (
+		arcSystem     transition.TransitionSystem
+		terminalStack int
+	)
+	switch DepArcSystemStr {
+	case "standard":
+		arcSystem = &ArcStandard{}
+		terminalStack = 1
+	case "eager":
+		arcSystem = &ArcEager{}
+		terminalStack = 0
+	default:
+		panic("Unknown arc system")
 	}
+
+	arcSystem.AddDefaultOracle()
+
+	transitionSystem := transition.TransitionSystem(arcSystem)
 	if allOut && !parseOut {
 		DepConfigOut(outModelFile, &search.Beam{}, transitionSystem)
 	}
 	// modelExists := false
-	relations, err := conf.ReadFile(DepLabelsFile)
+	relations, err := LoadedConfigs[MODEL_DEP].Relations(DepLabelsFile)
 	if err != nil {
 		log.Println("Failed reading dependency labels configuration file:", DepLabelsFile)
 		log.Fatalln(err)
@@ -178,7 +205,7 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		// start processing - setup enumerations
 		log.Println("Setup enumerations")
 	}
-	SetupDepEnum(relations.Values)
+	SetupDepEnum(relations)
 
 	// after calling SetupDepEnum, enums are instantiated and set according to the relations
 	// therefore we re-instantiate the arc system with the right parameters
@@ -221,11 +248,17 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Failed reading feature configuration file:", DepFeaturesFile)
 		log.Fatalln(err)
 	}
-	featureSetup, err := transition.LoadFeatureConfFile(DepFeaturesFile)
+	featureSetup, err := LoadedConfigs[MODEL_DEP].FeatureSetup(DepFeaturesFile)
 	if err != nil {
 		log.Println("Failed reading feature configuration file:", DepFeaturesFile)
 		log.Fatalln(err)
 	}
+	if serialization != nil {
+		if err := serialization.VerifyFeatures(featureSetup); err != nil {
+			log.Println("Model does not match feature configuration file:", DepFeaturesFile)
+			log.Fatalln(err)
+		}
+	}
 	extractor := SetupExtractor(featureSetup, []byte("A"))
 	// extractor.Log = true
 	group, _ := extractor.TransTypeGroups['A']
@@ -320,6 +353,10 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 		// goldGraphs = goldGraphs[:NUM_SENTS]
 		goldSequences := TrainingSequences(goldGraphs, GetAsTaggedSentence, GetAsLabeledDepGraph)
+		TrainConfig = NewModelConfig(MODEL_DEP, DepFeaturesFile)
+		TrainConfig.Labels = relations
+		TrainConfig.ArcSystem = DepArcSystemStr
+		TrainConfig.IgnoreLemma = lattice.IGNORE_LEMMA
 		if allOut {
 			log.Println("Generated", len(goldSequences), "training sequences")
 			log.Println()
@@ -359,6 +396,7 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			ConcurrentExec:       ConcurrentBeam,
 			EstimatedTransitions: EstimatedBeamTransitions(),
 			ScoredStoreDense:     true,
+			Update:               UpdateStrategy,
 		}
 
 		var evaluator perceptron.StopCondition
@@ -445,10 +483,7 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			log.Println()
 			log.Println("Writing model to", outModelFile)
 		}
-		serialization := &Serialization{
-			model.Serialize(-1),
-			EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
-		}
+		serialization := NewSerialization(model.Serialize(-1))
 		WriteModel(outModelFile, serialization)
 		if allOut {
 			log.Println("Done writing model")
@@ -457,8 +492,10 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		if allOut && !parseOut {
 			log.Println("Found model file", outModelFile, " ... loading model")
 		}
-		serialization := ReadModel(outModelFile)
-		model.Deserialize(serialization.WeightModel)
+		if err := serialization.LoadWeights(model); err != nil {
+			log.Println("Failed loading model weights:", outModelFile)
+			log.Fatalln(err)
+		}
 		EWord, EPOS, EWPOS, EMHost, EMSuffix = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix
 		if allOut && !parseOut {
 			log.Println("Loaded model")
@@ -577,12 +614,36 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		EstimatedTransitions: EstimatedBeamTransitions(),
 		ScoredStoreDense:     true,
 	}
+	if KBest > 1 {
+		log.Println("Parsing the", KBest, "best analyses of each sentence")
+		kBest := ParseKBestParallel(sents, beam, DecodeWorkers, KBest)
+		var (
+			err    error
+			format string = "conll"
+		)
+		if useConllU {
+			format = "conllu"
+			err = WriteKBest(outConll, kBest, func(writer io.Writer, i int, parsed []interface{}) {
+				graphAsConll := conllu.Graph2ConllUCorpus(parsed, EMHost, EMSuffix)
+				conllu.Write(writer, conllu.MergeGraphAndMorphCorpus(graphAsConll, asMorphGraphs[i:i+1]))
+			})
+		} else {
+			err = WriteKBest(outConll, kBest, func(writer io.Writer, i int, parsed []interface{}) {
+				conll.Write(writer, conll.Graph2ConllCorpus(parsed, EMHost, EMSuffix))
+			})
+		}
+		if err != nil {
+			log.Fatalln("Failed writing", outConll, err)
+		}
+		kBestWritten(kBest, format, outConll)
+		return nil
+	}
 	if Stream {
 		parsedStream := make(chan interface{}, 2)
 		if allOut {
 			log.Println("Starting parser")
 		}
-		go ParseStream(sentsStream, parsedStream, beam)
+		go ParseStreamParallel(sentsStream, parsedStream, beam, DecodeWorkers)
 		log.Println("Streaming conversion to conll")
 		graphAsConllStream := conll.Graph2ConllStream(parsedStream, EMHost, EMSuffix)
 		if allOut {
@@ -600,7 +661,7 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			log.Print("Parsing")
 		}
 
-		parsedGraphs := Parse(sents, beam)
+		parsedGraphs := ParseParallel(sents, beam, DecodeWorkers)
 		if !parseOut {
 			log.Println("Converting to conll")
 		}
@@ -649,6 +710,15 @@ runs dependency training/parsing
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations")
+	cmd.Flag.StringVar(&UpdateStrategy, "update", search.UPDATE_EARLY, "Perceptron update strategy (early, max-violation or latest)")
+	cmd.Flag.Int64Var(&Seed, "seed", 0, "Shuffle training instances every iteration with this seed (0 for file order)")
+	cmd.Flag.StringVar(&LearnerName, "learner", transitionmodel.LEARNER_PERCEPTRON, "Online learner (perceptron, pa or adagrad)")
+	cmd.Flag.Float64Var(&LearnerRate, "rate", 1.0, "Learner aggressiveness (pa) or learning rate (adagrad)")
+	cmd.Flag.IntVar(&TrainWorkers, "train-workers", 1, "Number of shards to train in parallel with iterative parameter mixing")
+	cmd.Flag.StringVar(&Mixing, "mixing", perceptron.MIX_UNIFORM, "Mixing weights of parallel training (uniform or errors)")
+	cmd.Flag.StringVar(&CheckpointFile, "checkpoint", "", "Training checkpoint file (none if empty)")
+	cmd.Flag.IntVar(&CheckpointEvery, "checkpoint_every", 0, "Checkpoint every n training instances (0 for only after each iteration)")
+	cmd.Flag.BoolVar(&Resume, "resume", false, "Resume training from the checkpoint file")
 	cmd.Flag.IntVar(&BeamSize, "b", 64, "Dependency Beam Size")
 	cmd.Flag.StringVar(&DepModelFile, "m", "model", "Prefix for model file ({m}.b{b}.i{it}.model)")
 	cmd.Flag.StringVar(&DepModelName, "mn", "dep.b64", "Modelfile")
@@ -670,5 +740,7 @@ runs dependency training/parsing
 	cmd.Flag.BoolVar(&search.AllOut, "showbeam", false, "Show candidates in beam")
 	cmd.Flag.BoolVar(&useConllU, "conllu", false, "use CoNLL-U-format input file (for disamb lattices)")
 	cmd.Flag.BoolVar(&Stream, "stream", false, "Stream data from input through parser to output")
+	cmd.Flag.IntVar(&DecodeWorkers, "workers", 1, "Number of sentences decoded in parallel, each with its own beam sharing the model")
+	cmd.Flag.IntVar(&KBest, "kbest", 1, "Number of best analyses to output for each sentence, ranked with their scores (at most the beam size)")
 	return cmd
 }
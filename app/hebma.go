@@ -9,6 +9,7 @@ import (
 
 	"yap/nlp/parser/ma"
 	"yap/nlp/parser/xliter8"
+	"yap/nlp/tokenizer"
 	nlp "yap/nlp/types"
 	// "yap/util"
 
@@ -25,6 +26,7 @@ var (
 	HebMaXliter8out, HebMaAlwaysnnp   bool
 	HebMaNnpnofeats              bool
 	HebMaShowoov                 bool
+	HebMaTokenize                bool
 	outJSON                 bool
 	HEB_MA_DEFAULT_DATA_DIRS       = []string{".", "data/bgulex"}
 )
@@ -43,6 +45,7 @@ func HebMAConfigOut() {
 	} else {
 		if len(inRawFile) > 0 {
 			log.Printf("Raw Input:\t\t%s", inRawFile)
+			log.Printf("Tokenize Input:\t%v", HebMaTokenize)
 		}
 	}
 	if len(outLatticeFile) > 0 {
@@ -96,6 +99,8 @@ func HebMA(cmd *commander.Command, args []string) error {
 		sentComments [][]string
 		sentsStream  chan nlp.BasicSentence
 		err          error
+
+		tokenizedSents []tokenizer.Sentence
 	)
 	if Stream {
 		if useConllU {
@@ -135,6 +140,15 @@ func HebMA(cmd *commander.Command, args []string) error {
 				sentComments[i] = sent.Comments
 				sents[i] = newSent
 			}
+		} else if HebMaTokenize {
+			tokenizedSents, err = ReadTokenizedFile(inRawFile, limit)
+			if err != nil {
+				panic(fmt.Sprintf("Failed reading raw file - %v", err))
+			}
+			sents = make([]nlp.BasicSentence, len(tokenizedSents))
+			for i, sent := range tokenizedSents {
+				sents[i] = sent.BasicSentence()
+			}
 		} else {
 			sents, err = raw.ReadFile(inRawFile, limit)
 			if err != nil {
@@ -183,6 +197,11 @@ func HebMA(cmd *commander.Command, args []string) error {
 		for i, sent := range sents {
 			log.SetPrefix(fmt.Sprintf("%v graph# %v ", prefix, i))
 			lattices[i], oovInd[i] = maData.Analyze(sent.Tokens())
+			if tokenizedSents != nil {
+				for j, token := range tokenizedSents[i] {
+					lattices[i][j].SetOffsets(token.Start, token.End)
+				}
+			}
 		}
 		var hebrew xliter8.Interface
 		if HebMaXliter8out {
@@ -200,7 +219,11 @@ func HebMA(cmd *commander.Command, args []string) error {
 				lattice.WriteUDFile(outLatticeFile, output, sentComments, oovAsBasicArray)
 			}
 		} else if outFormat == "spmrl" {
-			lattice.WriteFile(outLatticeFile, output)
+			if writeOffsets {
+				lattice.WriteOffsetsFile(outLatticeFile, output)
+			} else {
+				lattice.WriteFile(outLatticeFile, output)
+			}
 		} else {
 			panic(fmt.Sprintf("Unknown lattice output format - %v", outFormat))
 		}
@@ -230,6 +253,8 @@ run lexicon-based morphological analyzer on raw input
 	cmd.Flag.StringVar(&HebMaPrefixFile, "prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer")
 	cmd.Flag.StringVar(&HebMaLexiconFile, "lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer")
 	cmd.Flag.StringVar(&inRawFile, "raw", "", "Input raw (tokenized) file")
+	cmd.Flag.BoolVar(&HebMaTokenize, "tokenize", false, "Input raw file is untokenized text, split it into sentences and tokens")
+	cmd.Flag.BoolVar(&writeOffsets, "offsets", false, "Add the character offsets of each morpheme in the input to the output lattice (with -tokenize, spmrl format)")
 	cmd.Flag.StringVar(&conlluFile, "conllu", "", "CoNLL-U-format input file")
 	cmd.Flag.StringVar(&outLatticeFile, "out", "", "Output lattice file")
 	cmd.Flag.BoolVar(&HebMaXliter8out, "xliter8out", false, "Transliterate output lattice file")
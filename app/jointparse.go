@@ -16,9 +16,10 @@ import (
 	"yap/nlp/parser/joint"
 	nlp "yap/nlp/types"
 	"yap/util"
-	"yap/util/conf"
 
+	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -49,7 +50,7 @@ func SetupEnum(relations []string) {
 
 func CombineJointCorpus(graphs, goldLats, ambLats []interface{}) ([]interface{}, int) {
 	if len(graphs) != len(goldLats) || len(graphs) != len(ambLats) {
-		panic(fmt.Sprintf("Got mismatched training slice inputs (graphs, gold lattices, ambiguous lattices):", len(graphs), len(goldLats), len(ambLats)))
+		panic(fmt.Sprintf("Got mismatched training slice inputs (graphs, gold lattices, ambiguous lattices): %v %v %v", len(graphs), len(goldLats), len(ambLats)))
 	}
 	morphGraphs := make([]interface{}, len(graphs))
 	var (
@@ -71,7 +72,7 @@ func CombineJointCorpus(graphs, goldLats, ambLats []interface{}) ([]interface{},
 
 func CombineToGoldMorphs(goldLats, ambLats []interface{}) ([]interface{}, int) {
 	if len(goldLats) != len(ambLats) {
-		panic(fmt.Sprintf("Got mismatched training slice inputs (gold lattices, ambiguous lattices):", len(goldLats), len(ambLats)))
+		panic(fmt.Sprintf("Got mismatched training slice inputs (gold lattices, ambiguous lattices): %v %v", len(goldLats), len(ambLats)))
 	}
 	morphGraphs := make([]interface{}, len(goldLats))
 	var (
@@ -98,8 +99,15 @@ func JointConfigOut(outModelFile string, b search.Interface, t transition.Transi
 	log.Printf("Transition System:\t%s", t.Name())
 	log.Printf("Transition Oracle:\t%s", t.Oracle().Name())
 	log.Printf("Iterations:\t\t%d", Iterations)
+	log.Printf("Update:\t\t\t%s", UpdateStrategy)
+	orderConfigOut()
+	learnerConfigOut()
+	parallelConfigOut()
+	checkpointConfigOut()
 	log.Printf("Beam Size:\t\t%d", BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
+	log.Printf("Decode Workers:\t%v", DecodeWorkers)
+	kBestConfigOut()
 	log.Printf("Parameter Func:\t%v", MdParamFuncName)
 	log.Printf("Use Lemmas:\t\t%v", !lattice.IGNORE_LEMMA)
 	log.Printf("Use POP:\t\t%v", UsePOP)
@@ -109,26 +117,31 @@ func JointConfigOut(outModelFile string, b search.Interface, t transition.Transi
 	// log.Printf("Model file:\t\t%s", outModelFile)
 
 	log.Println()
-	log.Printf("Features File:\t%s", JointFeaturesFile)
-	outFeaturesFile := JointFeaturesFile
-	featuresExists := VerifyExists(outFeaturesFile)
-	if !featuresExists {
-		outFeaturesFile, featuresExists = util.LocateFile(outFeaturesFile, DEFAULT_CONF_DIRS)
-	}
-	if !featuresExists {
-		os.Exit(1)
-	}
-	JointFeaturesFile = outFeaturesFile
-	log.Printf("Labels File:\t\t%s", DepLabelsFile)
-	outLabelsFile := DepLabelsFile
-	labelsExists := VerifyExists(outLabelsFile)
-	if !labelsExists {
-		outLabelsFile, labelsExists = util.LocateFile(outLabelsFile, DEFAULT_CONF_DIRS)
-	}
-	if !labelsExists {
-		os.Exit(1)
-	}
-	DepLabelsFile = outLabelsFile
+	if config := LoadedConfigs[MODEL_JOINT]; config != nil {
+		log.Printf("Features File:\t%s (saved in model)", config.FeaturesFile)
+		log.Printf("Labels:\t\t%d (saved in model)", len(config.Labels))
+	} else {
+		log.Printf("Features File:\t%s", JointFeaturesFile)
+		outFeaturesFile := JointFeaturesFile
+		featuresExists := VerifyExists(outFeaturesFile)
+		if !featuresExists {
+			outFeaturesFile, featuresExists = util.LocateFile(outFeaturesFile, DEFAULT_CONF_DIRS)
+		}
+		if !featuresExists {
+			os.Exit(1)
+		}
+		JointFeaturesFile = outFeaturesFile
+		log.Printf("Labels File:\t\t%s", DepLabelsFile)
+		outLabelsFile := DepLabelsFile
+		labelsExists := VerifyExists(outLabelsFile)
+		if !labelsExists {
+			outLabelsFile, labelsExists = util.LocateFile(outLabelsFile, DEFAULT_CONF_DIRS)
+		}
+		if !labelsExists {
+			os.Exit(1)
+		}
+		DepLabelsFile = outLabelsFile
+	}
 	log.Println()
 	log.Println("Data")
 	if len(tConll) > 0 {
@@ -177,6 +190,37 @@ func JointConfigOut(outModelFile string, b search.Interface, t transition.Transi
 
 func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	// *** SETUP ***
+	outModelFile := JointModelFile
+	modelExists := VerifyExists(outModelFile)
+	if !modelExists {
+		outModelFile, modelExists = util.LocateFile(outModelFile, DEFAULT_MODEL_DIRS)
+	}
+	REQUIRED_FLAGS := []string{"in", "oc", "om", "os"}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	verifyKBest()
+	verifyUpdateStrategy()
+	verifyLearner()
+	verifyParallel()
+	if Stream && (useConllU || len(inputGold) > 0) {
+		return fmt.Errorf("Streaming is not supported with -conllu or -ing")
+	}
+
+	var serialization *Serialization
+	if !modelExists {
+		REQUIRED_FLAGS = []string{"it", "tc", "td", "tl", "in", "oc", "om", "os", "ots", "f", "l", "jointstr", "oraclestr"}
+		VerifyFlags(cmd, REQUIRED_FLAGS)
+	} else {
+		// the model's configuration sets the features, labels, arc system,
+		// strategies and transition options
+		var err error
+		serialization, err = LoadModel(outModelFile, MODEL_JOINT)
+		if err != nil {
+			log.Println("Failed loading model:", outModelFile)
+			log.Fatalln(err)
+		}
+		ConfigureFromModel(cmd, MODEL_JOINT, serialization.Config, JointModelFlags)
+	}
+
 	paramFunc, exists := nlp.MDParams[MdParamFuncName]
 	if !exists {
 		log.Fatalln("Param Func", MdParamFuncName, "does not exist")
@@ -217,19 +261,6 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = OracleStrategy
 	transitionSystem := transition.TransitionSystem(jointTrans)
 
-	outModelFile := JointModelFile
-	modelExists := VerifyExists(outModelFile)
-	if !modelExists {
-		outModelFile, modelExists = util.LocateFile(outModelFile, DEFAULT_MODEL_DIRS)
-	}
-	REQUIRED_FLAGS := []string{"in", "oc", "om", "os"}
-	VerifyFlags(cmd, REQUIRED_FLAGS)
-
-	if !modelExists {
-		REQUIRED_FLAGS = []string{"it", "tc", "td", "tl", "in", "oc", "om", "os", "ots", "f", "l", "jointstr", "oraclestr"}
-		VerifyFlags(cmd, REQUIRED_FLAGS)
-	}
-
 	// RegisterTypes()
 
 	confBeam := &search.Beam{}
@@ -240,7 +271,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 
 	JointConfigOut(outModelFile, confBeam, transitionSystem)
 
-	relations, err := conf.ReadFile(DepLabelsFile)
+	relations, err := LoadedConfigs[MODEL_JOINT].Relations(DepLabelsFile)
 	if err != nil {
 		log.Println("Failed reading dependency labels configuration file:", DepLabelsFile)
 		log.Fatalln(err)
@@ -250,7 +281,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		// start processing - setup enumerations
 		log.Println("Setup enumerations")
 	}
-	SetupEnum(relations.Values)
+	SetupEnum(relations)
 
 	// after calling SetupEnum, enums are instantiated and set according to the relations
 	// therefore we re-instantiate the arc system with the right parameters
@@ -285,9 +316,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	mdTrans.Transitions = ETrans
 	mdTrans.UsePOP = UsePOP
 	mdTrans.POP = POP
-	disambig.UsePOP = UsePOP
-	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
-	disambig.LEMMAS = !lattice.IGNORE_LEMMA
+	mdTrans.IgnoreLemmas = lattice.IGNORE_LEMMA
 	mdTrans.AddDefaultOracle()
 	jointTrans.MDTransition = MD
 	jointTrans.JointStrategy = JointStrategy
@@ -301,11 +330,17 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Loading features")
 	}
 
-	featureSetup, err := transition.LoadFeatureConfFile(JointFeaturesFile)
+	featureSetup, err := LoadedConfigs[MODEL_JOINT].FeatureSetup(JointFeaturesFile)
 	if err != nil {
 		log.Println("Failed reading feature configuration file:", JointFeaturesFile)
 		log.Fatalln(err)
 	}
+	if serialization != nil {
+		if err := serialization.VerifyFeatures(featureSetup); err != nil {
+			log.Println("Model does not match feature configuration file:", JointFeaturesFile)
+			log.Fatalln(err)
+		}
+	}
 	// M - MD
 	// P - POP
 	// L - Lemma (not in use right now)
@@ -423,6 +458,14 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		// const NUM_SENTS = 20
 		// combined = combined[:NUM_SENTS]
 		goldSequences := TrainingSequences(combined, GetMorphGraphAsLattices, GetMorphGraph)
+		TrainConfig = NewModelConfig(MODEL_JOINT, JointFeaturesFile)
+		TrainConfig.Labels = relations
+		TrainConfig.ArcSystem = DepArcSystemStr
+		TrainConfig.ParamFunc = MdParamFuncName
+		TrainConfig.UsePOP = UsePOP
+		TrainConfig.JointStrategy = JointStrategy
+		TrainConfig.OracleStrategy = OracleStrategy
+		TrainConfig.IgnoreLemma = lattice.IGNORE_LEMMA
 		if allOut {
 			log.Println("Generated", len(goldSequences), "training sequences")
 			log.Println()
@@ -459,10 +502,12 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 				TerminalQueue: 0,
 			},
 			MDConfig: disambig.MDConfig{
-				ETokens:     ETokens,
-				POP:         POP,
-				Transitions: ETrans,
-				ParamFunc:   paramFunc,
+				ETokens:         ETokens,
+				POP:             POP,
+				Transitions:     ETrans,
+				ParamFunc:       paramFunc,
+				UsePOP:          UsePOP,
+				SwitchFormLemma: !lattice.IGNORE_LEMMA,
 			},
 			MDTrans: MD,
 		}
@@ -476,6 +521,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			Transitions:          ETrans,
 			EstimatedTransitions: 1000,
 			NoRecover:            false,
+			Update:               UpdateStrategy,
 		}
 
 		if !alignAverageParseOnly {
@@ -632,10 +678,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			log.Println("Done Training")
 			// util.LogMemory()
 			// log.Println()
-			// serialization := &Serialization{
-			// 	model.Serialize(-1),
-			// 	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
-			// }
+			// serialization := NewSerialization(model.Serialize(-1))
 			// log.Println("Writing final model to", outModelFile)
 			// WriteModel(outModelFile, serialization)
 			// if allOut {
@@ -647,8 +690,10 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		if allOut && !parseOut {
 			log.Println("Found model file", outModelFile, " ... loading model")
 		}
-		serialization := ReadModel(outModelFile)
-		model.Deserialize(serialization.WeightModel)
+		if err := serialization.LoadWeights(model); err != nil {
+			log.Println("Failed loading model weights:", outModelFile)
+			log.Fatalln(err)
+		}
 		EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
 		if allOut && !parseOut {
 			log.Println("Loaded model")
@@ -683,9 +728,7 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		mdTrans.Transitions = ETrans
 		mdTrans.UsePOP = UsePOP
 		mdTrans.POP = POP
-		disambig.UsePOP = UsePOP
-		disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
-		disambig.LEMMAS = !lattice.IGNORE_LEMMA
+		mdTrans.IgnoreLemmas = lattice.IGNORE_LEMMA
 		mdTrans.AddDefaultOracle()
 		jointTrans.MDTransition = MD
 		jointTrans.JointStrategy = JointStrategy
@@ -698,6 +741,44 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	log.Println("*** PARSING ***")
 	log.Print("Parsing test")
 
+	conf := &joint.JointConfig{
+		SimpleConfiguration: SimpleConfiguration{
+			EWord:         EWord,
+			EPOS:          EPOS,
+			EWPOS:         EWPOS,
+			EMHost:        EMHost,
+			EMSuffix:      EMSuffix,
+			ERel:          ERel,
+			ETrans:        ETrans,
+			TerminalStack: terminalStack,
+			TerminalQueue: 0,
+		},
+		MDConfig: disambig.MDConfig{
+			ETokens:         ETokens,
+			POP:             POP,
+			Transitions:     ETrans,
+			ParamFunc:       paramFunc,
+			UsePOP:          UsePOP,
+			SwitchFormLemma: !lattice.IGNORE_LEMMA,
+		},
+		MDTrans: MD,
+	}
+	beam := &search.Beam{
+		TransFunc:            transitionSystem,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Size:                 BeamSize,
+		ConcurrentExec:       ConcurrentBeam,
+		Transitions:          ETrans,
+		EstimatedTransitions: 1000, // chosen by random dice roll
+	}
+	beam.Model = model
+	beam.ShortTempAgenda = true
+
+	if Stream {
+		return JointParseStream(beam)
+	}
+
 	log.Println("Reading ambiguous lattices from", input)
 
 	var (
@@ -754,38 +835,16 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			log.Println()
 		}
 	}
-	conf := &joint.JointConfig{
-		SimpleConfiguration: SimpleConfiguration{
-			EWord:         EWord,
-			EPOS:          EPOS,
-			EWPOS:         EWPOS,
-			EMHost:        EMHost,
-			EMSuffix:      EMSuffix,
-			ERel:          ERel,
-			ETrans:        ETrans,
-			TerminalStack: terminalStack,
-			TerminalQueue: 0,
-		},
-		MDConfig: disambig.MDConfig{
-			ETokens:     ETokens,
-			POP:         POP,
-			Transitions: ETrans,
-			ParamFunc:   paramFunc,
-		},
-		MDTrans: MD,
-	}
-	beam := &search.Beam{
-		TransFunc:            transitionSystem,
-		FeatExtractor:        extractor,
-		Base:                 conf,
-		Size:                 BeamSize,
-		ConcurrentExec:       ConcurrentBeam,
-		Transitions:          ETrans,
-		EstimatedTransitions: 1000, // chosen by random dice roll
+	if KBest > 1 {
+		return JointParseKBest(predAmbLat, beam)
 	}
-	beam.Model = model
-	beam.ShortTempAgenda = true
-	parsedGraphs := Parse(predAmbLat, beam)
+	return JointParse(predAmbLat, beam)
+}
+
+// JointParse parses the ambiguous lattices, writing the parsed sentences to
+// the conll, segmentation and mapping outputs
+func JointParse(predAmbLat []interface{}, beam *search.Beam) error {
+	parsedGraphs := ParseParallel(predAmbLat, beam, DecodeWorkers)
 
 	if allOut {
 		log.Println("Converting", len(parsedGraphs), "to conll")
@@ -799,7 +858,11 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		conllu.WriteFile(outConll, graphAsConll)
 	} else {
 		graphAsConll = conll.MorphGraph2ConllCorpus(parsedGraphs)
-		conll.WriteFile(outConll, graphAsConll)
+		if writeOffsets {
+			conll.WriteOffsetsFile(outConll, graphAsConll)
+		} else {
+			conll.WriteFile(outConll, graphAsConll)
+		}
 	}
 	if allOut {
 		log.Println("Wrote", len(graphAsConll), "in conll format to", outConll)
@@ -812,7 +875,11 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 
 		log.Println("Writing to mapping file")
 	}
-	mapping.WriteFile(outMap, GetInstances(parsedGraphs, GetJointMDConfig))
+	if writeOffsets {
+		mapping.WriteOffsetsFile(outMap, GetInstances(parsedGraphs, GetJointMDConfig))
+	} else {
+		mapping.WriteFile(outMap, GetInstances(parsedGraphs, GetJointMDConfig))
+	}
 	if allOut {
 		log.Println("Wrote", len(parsedGraphs), "in mapping format to", outMap)
 
@@ -821,6 +888,101 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	return nil
 }
 
+// JointParseKBest parses the KBest best analyses of each sentence, writing
+// them to the conll, segmentation and mapping outputs (see WriteKBest)
+func JointParseKBest(predAmbLat []interface{}, beam *search.Beam) error {
+	log.Println("Parsing the", KBest, "best analyses of each sentence")
+	kBest := ParseKBestParallel(predAmbLat, beam, DecodeWorkers, KBest)
+	outputs := []struct {
+		filename, format string
+		write            func(writer io.Writer, i int, parsed []interface{})
+	}{
+		{outConll, "conll", func(writer io.Writer, i int, parsed []interface{}) {
+			if useConllU {
+				conllu.Write(writer, conllu.MorphGraph2ConllCorpus(parsed))
+			} else if writeOffsets {
+				conll.WriteOffsets(writer, conll.MorphGraph2ConllCorpus(parsed))
+			} else {
+				conll.Write(writer, conll.MorphGraph2ConllCorpus(parsed))
+			}
+		}},
+		{outSeg, "segmentation", func(writer io.Writer, i int, parsed []interface{}) {
+			segmentation.Write(writer, parsed)
+		}},
+		{outMap, "mapping", func(writer io.Writer, i int, parsed []interface{}) {
+			if writeOffsets {
+				mapping.WriteOffsets(writer, GetInstances(parsed, GetJointMDConfig))
+			} else {
+				mapping.Write(writer, GetInstances(parsed, GetJointMDConfig))
+			}
+		}},
+	}
+	for _, output := range outputs {
+		if err := WriteKBest(output.filename, kBest, output.write); err != nil {
+			return err
+		}
+		kBestWritten(kBest, output.format, output.filename)
+	}
+	return nil
+}
+
+// JointParseStream parses the ambiguous lattices of the input as they are
+// read, writing each parsed sentence to the conll, segmentation and mapping
+// outputs once it is parsed, so that only the sentences in flight are
+// held in memory
+func JointParseStream(beam *search.Beam) error {
+	log.Println("Streaming ambiguous lattices from", input)
+	lAmb, lAmbE := lattice.StreamFile(input, limit)
+	if lAmbE != nil {
+		log.Println(lAmbE)
+		return lAmbE
+	}
+	predAmbLatStream := lattice.Lattice2SentenceStream(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+	parsedStream := make(chan interface{}, 2)
+	go ParseStreamParallel(predAmbLatStream, parsedStream, beam, DecodeWorkers)
+	if allOut {
+		log.Println("Creating writer streams to", outConll, outSeg, outMap)
+	}
+	return WriteJointStream(parsedStream, outConll, outSeg, outMap)
+}
+
+// WriteJointStream writes parsed joint configurations to conll, segmentation
+// and mapping files as they arrive, flushing each sentence
+func WriteJointStream(parsed chan interface{}, conllFile, segFile, mapFile string) error {
+	var writers [3]*bufio.Writer
+	for i, filename := range []string{conllFile, segFile, mapFile} {
+		file, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writers[i] = bufio.NewWriter(file)
+	}
+	conllWriter, segWriter, mapWriter := writers[0], writers[1], writers[2]
+	var written int
+	for graph := range parsed {
+		graphs := []interface{}{graph}
+		if writeOffsets {
+			conll.WriteOffsets(conllWriter, conll.MorphGraph2ConllCorpus(graphs))
+			mapping.WriteOffsets(mapWriter, GetInstances(graphs, GetJointMDConfig))
+		} else {
+			conll.Write(conllWriter, conll.MorphGraph2ConllCorpus(graphs))
+			mapping.Write(mapWriter, GetInstances(graphs, GetJointMDConfig))
+		}
+		segmentation.Write(segWriter, graphs)
+		for _, writer := range writers {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		}
+		written++
+	}
+	if allOut {
+		log.Println("Wrote", written, "sentences to", conllFile, segFile, mapFile)
+	}
+	return nil
+}
+
 func JointCmd() *commander.Command {
 	cmd := &commander.Command{
 		Run:       JointTrainAndParse,
@@ -836,6 +998,15 @@ runs morpho-syntactic training and parsing
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations")
+	cmd.Flag.StringVar(&UpdateStrategy, "update", search.UPDATE_EARLY, "Perceptron update strategy (early, max-violation or latest)")
+	cmd.Flag.Int64Var(&Seed, "seed", 0, "Shuffle training instances every iteration with this seed (0 for file order)")
+	cmd.Flag.StringVar(&LearnerName, "learner", transitionmodel.LEARNER_PERCEPTRON, "Online learner (perceptron, pa or adagrad)")
+	cmd.Flag.Float64Var(&LearnerRate, "rate", 1.0, "Learner aggressiveness (pa) or learning rate (adagrad)")
+	cmd.Flag.IntVar(&TrainWorkers, "train-workers", 1, "Number of shards to train in parallel with iterative parameter mixing")
+	cmd.Flag.StringVar(&Mixing, "mixing", perceptron.MIX_UNIFORM, "Mixing weights of parallel training (uniform or errors)")
+	cmd.Flag.StringVar(&CheckpointFile, "checkpoint", "", "Training checkpoint file (none if empty)")
+	cmd.Flag.IntVar(&CheckpointEvery, "checkpoint_every", 0, "Checkpoint every n training instances (0 for only after each iteration)")
+	cmd.Flag.BoolVar(&Resume, "resume", false, "Resume training from the checkpoint file")
 	cmd.Flag.IntVar(&BeamSize, "b", 64, "Beam Size")
 	cmd.Flag.StringVar(&JointModelFile, "m", "joint_arc_zeager_model_temp_i33.b64", "Joint model name")
 	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System [standard, eager]")
@@ -850,6 +1021,10 @@ runs morpho-syntactic training and parsing
 	cmd.Flag.StringVar(&outConll, "oc", "", "Output Conll File")
 	cmd.Flag.StringVar(&outSeg, "os", "", "Output Segmentation File")
 	cmd.Flag.StringVar(&outMap, "om", "", "Output Mapping File")
+	cmd.Flag.BoolVar(&writeOffsets, "offsets", false, "Add the character offsets of each morpheme (from the input lattice) to the output mapping and conll")
+	cmd.Flag.BoolVar(&Stream, "stream", false, "Stream data from input through parser to output")
+	cmd.Flag.IntVar(&DecodeWorkers, "workers", 1, "Number of sentences decoded in parallel, each with its own beam sharing the model")
+	cmd.Flag.IntVar(&KBest, "kbest", 1, "Number of best analyses to output for each sentence, ranked with their scores (at most the beam size)")
 	cmd.Flag.StringVar(&tSeg, "ots", "", "Output Training Segmentation File")
 	cmd.Flag.StringVar(&JointFeaturesFile, "f", "jointzeager.yaml", "Features Configuration File")
 	cmd.Flag.StringVar(&DepLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File")
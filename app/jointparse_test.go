@@ -0,0 +1,166 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"yap/alg/search"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	"yap/nlp/format/lattice"
+	. "yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+	"yap/util/conf"
+)
+
+const testAmbLattices = `0	1	ה	_	DEF	DEF	_	1
+1	2	גנן	_	NN	NN	gen=M|num=S	1
+0	2	הגנן	_	NN	NN	gen=M|num=S	1
+2	3	גידל	_	VB	VB	gen=M|num=S|per=3|tense=PAST	2
+3	4	דגן	_	NN	NN	gen=M|num=S	3
+4	5	.	_	yyDOT	yyDOT	_	4
+
+0	1	דגן	_	NN	NN	gen=M|num=S	1
+1	2	גדל	_	VB	VB	gen=M|num=S|per=3|tense=PAST	2
+2	3	ב	_	PREPOSITION	PREPOSITION	_	3
+3	4	ה	_	DEF	DEF	_	3
+4	5	גן	_	NN	NN	gen=M|num=S	3
+2	5	בגן	_	NN	NN	gen=M|num=S	3
+3	5	גן	_	NN	NN	gen=M|num=S	3
+5	6	.	_	yyDOT	yyDOT	_	4
+
+0	1	גנן	_	NN	NN	gen=M|num=S	1
+1	2	אכל	_	VB	VB	gen=M|num=S|per=3|tense=PAST	2
+1	2	אכל	_	NN	NN	gen=M|num=S	2
+2	3	.	_	yyDOT	yyDOT	_	3
+
+`
+
+// testJointBeam sets up an (untrained) joint beam as the joint command does
+// for parsing
+func testJointBeam(t *testing.T) *search.Beam {
+	relations, err := conf.ReadFile("../conf/hebtb.labels.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetupEnum(relations.Values)
+	paramFunc, _ := nlp.MDParams["Funcs_Main_POS_Both_Prop"]
+	arcSystem := &ArcEager{
+		ArcStandard: ArcStandard{
+			SHIFT:       SH.Value(),
+			LEFT:        LA.Value(),
+			RIGHT:       RA.Value(),
+			Relations:   ERel,
+			Transitions: ETrans,
+		},
+		REDUCE:  RE.Value(),
+		POPROOT: PR.Value(),
+	}
+	arcSystem.AddDefaultOracle()
+	mdTrans := &disambig.MDTrans{
+		ParamFunc:    paramFunc,
+		UsePOP:       true,
+		POP:          POP,
+		Transitions:  ETrans,
+		IgnoreLemmas: true,
+	}
+	mdTrans.AddDefaultOracle()
+	jointTrans := &joint.JointTrans{
+		MDTrans:       mdTrans,
+		ArcSys:        arcSystem,
+		Transitions:   ETrans,
+		MDTransition:  MD,
+		JointStrategy: "ArcGreedy",
+	}
+	jointTrans.AddDefaultOracle()
+	featureSetup, err := transition.LoadFeatureConfFile("../conf/jointzeager.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extractor := SetupExtractor(featureSetup, []byte("MPLA"))
+	nlp.InitOpenParamFamily("HEBTB")
+	conf := &joint.JointConfig{
+		SimpleConfiguration: SimpleConfiguration{
+			EWord:    EWord,
+			EPOS:     EPOS,
+			EWPOS:    EWPOS,
+			EMHost:   EMHost,
+			EMSuffix: EMSuffix,
+			ERel:     ERel,
+			ETrans:   ETrans,
+		},
+		MDConfig: disambig.MDConfig{
+			ETokens:     ETokens,
+			POP:         POP,
+			Transitions: ETrans,
+			ParamFunc:   paramFunc,
+			UsePOP:      true,
+		},
+		MDTrans: MD,
+	}
+	beam := &search.Beam{
+		TransFunc:            jointTrans,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Size:                 4,
+		Transitions:          ETrans,
+		EstimatedTransitions: 1000,
+	}
+	beam.Model = transitionmodel.NewAvgMatrixSparse(NumFeatures, nil, false)
+	beam.ShortTempAgenda = true
+	return beam
+}
+
+func TestJointParseStream(t *testing.T) {
+	defer func(in, conll, seg, mapping string, workers int) {
+		input, outConll, outSeg, outMap, DecodeWorkers = in, conll, seg, mapping, workers
+	}(input, outConll, outSeg, outMap, DecodeWorkers)
+	dir, err := ioutil.TempDir("", "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input = filepath.Join(dir, "input.lattices")
+	if err := ioutil.WriteFile(input, []byte(testAmbLattices), 0644); err != nil {
+		t.Fatal(err)
+	}
+	DecodeWorkers = 2
+	beam := testJointBeam(t)
+
+	output := func(name string) {
+		outConll, outSeg, outMap = filepath.Join(dir, name+".conll"), filepath.Join(dir, name+".seg"), filepath.Join(dir, name+".map")
+	}
+	output("parsed")
+	lAmb, err := lattice.ReadFile(input, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := JointParse(lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix), beam); err != nil {
+		t.Fatal(err)
+	}
+	output("streamed")
+	if err := JointParseStream(beam); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ext := range []string{".conll", ".seg", ".map"} {
+		parsed, err := ioutil.ReadFile(filepath.Join(dir, "parsed"+ext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed, err := ioutil.ReadFile(filepath.Join(dir, "streamed"+ext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parsed) == 0 {
+			t.Errorf("parsed no %v output", ext)
+		}
+		if string(streamed) != string(parsed) {
+			t.Errorf("streamed %v output\n%s\nexpected\n%s", ext, streamed, parsed)
+		}
+	}
+}
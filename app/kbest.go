@@ -0,0 +1,125 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"yap/alg/search"
+)
+
+// KBest is the number of analyses to output for each sentence (see
+// search.Beam.ParseKBest); 1 for only the best
+var KBest int
+
+func kBestConfigOut() {
+	log.Printf("K-Best:\t\t%d", KBest)
+}
+
+// verifyKBest exits if k-best output was asked for with options it does
+// not support
+func verifyKBest() {
+	if KBest < 1 {
+		log.Fatalln("-kbest must be at least 1, got", KBest)
+	}
+	if KBest > BeamSize {
+		log.Println("Warning: -kbest", KBest, "is larger than the beam size, at most", BeamSize, "analyses are written")
+	}
+	if KBest > 1 && Stream {
+		log.Fatalln("-kbest is not supported with -stream")
+	}
+}
+
+// ParseKBestParallel returns the k best parses of each instance, with
+// workers decoding instances in parallel as ParseParallel
+func ParseKBestParallel(instances []interface{}, beam *search.Beam, workers, k int) [][]search.ScoredParse {
+	startTime := time.Now()
+	if workers < 1 {
+		workers = 1
+	}
+	parsed := make([][]search.ScoredParse, len(instances))
+	indices := make(chan int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		// beams are copied, sharing their model, see parserCopies
+		workerBeam := *beam
+		wg.Add(1)
+		go func(workerBeam *search.Beam) {
+			defer wg.Done()
+			for i := range indices {
+				log.Println("Parsing instance", i)
+				parsed[i] = workerBeam.ParseKBest(instances[i], k)
+			}
+		}(&workerBeam)
+	}
+	for i := range instances {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	if allOut {
+		parseTime := time.Since(startTime)
+		log.Println("PARSE Total Time:", parseTime, "with", workers, "workers")
+	}
+	return parsed
+}
+
+// ParseKBestContext returns the k best parses of each instance, stopping
+// as ParseContext
+func ParseKBestContext(ctx context.Context, instances []interface{}, beam *search.Beam, k int) ([][]search.ScoredParse, error) {
+	startTime := time.Now()
+	parsed := make([][]search.ScoredParse, len(instances))
+	for i, instance := range instances {
+		log.Println("Parsing instance", i)
+		parses, err := beam.ParseKBestContext(ctx, instance, k)
+		if err != nil {
+			log.Println("Failed parsing instance", i, "-", err)
+			return nil, err
+		}
+		parsed[i] = parses
+	}
+	if allOut {
+		parseTime := time.Since(startTime)
+		log.Println("PARSE Total Time:", parseTime)
+	}
+	return parsed, nil
+}
+
+// KBestHeader is the comment line preceding each of the k best analyses of
+// a sentence in output files
+func KBestHeader(sentence, rank int, score float64) string {
+	return fmt.Sprintf("# sent = %d rank = %d score = %v\n", sentence, rank, score)
+}
+
+// WriteKBest writes the k best analyses of each sentence to a file, best
+// first, each preceded by a KBestHeader (sentences and ranks are numbered
+// from 1); write writes an analysis of the i'th sentence in the output format
+func WriteKBest(filename string, kBest [][]search.ScoredParse, write func(writer io.Writer, i int, parsed []interface{})) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	for i, parses := range kBest {
+		for rank, parse := range parses {
+			writer.WriteString(KBestHeader(i+1, rank+1, parse.Score))
+			write(writer, i, []interface{}{parse.C})
+		}
+	}
+	return writer.Flush()
+}
+
+// kBestWritten logs the number of analyses written to a file
+func kBestWritten(kBest [][]search.ScoredParse, format, filename string) {
+	var analyses int
+	for _, parses := range kBest {
+		analyses += len(parses)
+	}
+	log.Println("Wrote", analyses, "analyses of", len(kBest), "sentences in", format, "format to", filename)
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"log"
+	"strings"
+
+	"yap/alg/perceptron"
+	"yap/alg/transition/model"
+)
+
+var (
+	// online learner of training (see model.NewLearner), and its
+	// aggressiveness (pa) or learning rate (adagrad)
+	LearnerName string
+	LearnerRate float64
+)
+
+func learnerConfigOut() {
+	switch LearnerName {
+	case model.LEARNER_PERCEPTRON:
+		log.Printf("Learner:\t\t%s", LearnerName)
+	default:
+		log.Printf("Learner:\t\t%s (rate %v)", LearnerName, LearnerRate)
+	}
+}
+
+// verifyLearner exits if the learner is unknown
+func verifyLearner() {
+	if _, err := newLearner(); err != nil {
+		log.Fatalln(err, "- expected one of", strings.Join(model.Learners, ", "))
+	}
+	if LearnerRate <= 0 {
+		log.Fatalln("-rate must be positive, got", LearnerRate)
+	}
+}
+
+func newLearner() (perceptron.Learner, error) {
+	learner, err := model.NewLearner(LearnerName, LearnerRate)
+	if perceptronLearner, ok := learner.(*perceptron.PerceptronLearner); ok && TrainWorkers > 1 {
+		// mixing averages the weights, steps of 1 would be rounded away
+		perceptronLearner.Step = model.LEARNER_SCALE
+	}
+	return learner, err
+}
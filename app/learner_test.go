@@ -0,0 +1,45 @@
+package app
+
+import (
+	"testing"
+
+	"yap/alg/perceptron"
+	"yap/alg/transition/model"
+)
+
+func TestNewLearnerStep(t *testing.T) {
+	defer func(name string, rate float64, workers int) {
+		LearnerName, LearnerRate, TrainWorkers = name, rate, workers
+	}(LearnerName, LearnerRate, TrainWorkers)
+	LearnerName, LearnerRate = model.LEARNER_PERCEPTRON, 1
+	// mixed perceptron weights are scaled as those of the real valued learners
+	for workers, step := range map[int]int64{1: 0, 2: model.LEARNER_SCALE} {
+		TrainWorkers = workers
+		learner, err := newLearner()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if learner.(*perceptron.PerceptronLearner).Step != step {
+			t.Errorf("%v workers: step %v, expected %v", workers, learner.(*perceptron.PerceptronLearner).Step, step)
+		}
+	}
+}
+
+func TestTrainCutGold(t *testing.T) {
+	defer func(name string, rate float64, workers int) {
+		LearnerName, LearnerRate, TrainWorkers = name, rate, workers
+	}(LearnerName, LearnerRate, TrainWorkers)
+	LearnerRate, TrainWorkers = 1, 1
+	// only the real valued learners align the gold features of updates with
+	// the decoded ones, the perceptron update is left as is
+	for name, cut := range map[string]bool{
+		model.LEARNER_PERCEPTRON: false,
+		model.LEARNER_PA:         true,
+		model.LEARNER_ADAGRAD:    true,
+	} {
+		LearnerName = name
+		if beam, _ := testDepBeam(t); beam.CutGold != cut {
+			t.Errorf("%v: beam cuts gold features: %v, expected %v", name, beam.CutGold, cut)
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"yap/util"
 
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -180,8 +181,15 @@ func MDConfigOut(outModelFile string, b search.Interface, t transition.Transitio
 	log.Printf("Beam:\t\t%s", b.Name())
 	log.Printf("Transition System:\t%s", t.Name())
 	log.Printf("Iterations:\t\t%d", Iterations)
+	log.Printf("Update:\t\t\t%s", UpdateStrategy)
+	orderConfigOut()
+	learnerConfigOut()
+	parallelConfigOut()
+	checkpointConfigOut()
 	log.Printf("Beam Size:\t\t%d", BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
+	log.Printf("Decode Workers:\t%v", DecodeWorkers)
+	kBestConfigOut()
 	log.Printf("Parameter Func:\t%v", MdParamFuncName)
 	log.Printf("Use POP:\t\t%v", UsePOP)
 	log.Printf("Infuse Gold Dev:\t%v", MdCombineGold)
@@ -194,9 +202,13 @@ func MDConfigOut(outModelFile string, b search.Interface, t transition.Transitio
 	}
 
 	log.Println()
-	log.Printf("Features File:\t%s", MdFeaturesFile)
-	if !VerifyExists(MdFeaturesFile) {
-		os.Exit(1)
+	if config := LoadedConfigs[MODEL_MD]; config != nil {
+		log.Printf("Features File:\t%s (saved in model)", config.FeaturesFile)
+	} else {
+		log.Printf("Features File:\t%s", MdFeaturesFile)
+		if !VerifyExists(MdFeaturesFile) {
+			os.Exit(1)
+		}
 	}
 	log.Println()
 	log.Println("Data")
@@ -231,30 +243,6 @@ func MDConfigOut(outModelFile string, b search.Interface, t transition.Transitio
 
 func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	//BeamSize = MdBeamSize
-	paramFunc, exists := nlp.MDParams[MdParamFuncName]
-	if !exists {
-		log.Fatalln("Param Func", MdParamFuncName, "does not exist")
-	}
-	var (
-		mdTrans transition.TransitionSystem
-		model   *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
-	)
-	if MdUseWB {
-		mdTrans = &disambig.MDWBTrans{
-			ParamFunc: paramFunc,
-			UsePOP:    UsePOP,
-		}
-	} else {
-		mdTrans = &disambig.MDTrans{
-			ParamFunc: paramFunc,
-			UsePOP:    UsePOP,
-		}
-	}
-	disambig.UsePOP = UsePOP
-
-	// arcSystem := &morph.Idle{morphArcSystem, IDLE}
-	transitionSystem := transition.TransitionSystem(mdTrans)
-
 	REQUIRED_FLAGS := []string{"in", "om"}
 
 	featuresLocation, found := util.LocateFile(MdFeaturesFile, DEFAULT_CONF_DIRS)
@@ -264,6 +252,10 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "f")
 	}
 	VerifyFlags(cmd, REQUIRED_FLAGS)
+	verifyKBest()
+	verifyUpdateStrategy()
+	verifyLearner()
+	verifyParallel()
 
 	var (
 		outModelFile string = fmt.Sprintf("%s.b%d", MdModelFile, BeamSize)
@@ -279,12 +271,45 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		modelExists = VerifyExists(outModelFile)
 	}
 
+	var serialization *Serialization
 	if !modelExists {
 		log.Println("No model found, training")
 		REQUIRED_FLAGS = []string{"it", "td", "tl"}
 		VerifyFlags(cmd, REQUIRED_FLAGS)
+	} else {
+		// the model's configuration sets the features and transition options
+		var err error
+		serialization, err = LoadModel(outModelFile, MODEL_MD)
+		if err != nil {
+			log.Println("Failed loading model:", outModelFile)
+			log.Fatalln(err)
+		}
+		ConfigureFromModel(cmd, MODEL_MD, serialization.Config, MDModelFlags)
 	}
 
+	paramFunc, exists := nlp.MDParams[MdParamFuncName]
+	if !exists {
+		log.Fatalln("Param Func", MdParamFuncName, "does not exist")
+	}
+	var (
+		mdTrans transition.TransitionSystem
+		model   *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
+	)
+	if MdUseWB {
+		mdTrans = &disambig.MDWBTrans{
+			ParamFunc: paramFunc,
+			UsePOP:    UsePOP,
+		}
+	} else {
+		mdTrans = &disambig.MDTrans{
+			ParamFunc: paramFunc,
+			UsePOP:    UsePOP,
+		}
+	}
+
+	// arcSystem := &morph.Idle{morphArcSystem, IDLE}
+	transitionSystem := transition.TransitionSystem(mdTrans)
+
 	// RegisterTypes()
 
 	confBeam := &search.Beam{}
@@ -295,7 +320,6 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 
 	MDConfigOut(outModelFile, confBeam, transitionSystem)
 
-	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
 	if allOut {
 		log.Println()
 		// start processing - setup enumerations
@@ -314,11 +338,17 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println()
 		log.Println("Loading features")
 	}
-	featureSetup, err := transition.LoadFeatureConfFile(MdFeaturesFile)
+	featureSetup, err := LoadedConfigs[MODEL_MD].FeatureSetup(MdFeaturesFile)
 	if err != nil {
 		log.Println("Failed reading feature configuration file:", MdFeaturesFile)
 		log.Fatalln(err)
 	}
+	if serialization != nil {
+		if err := serialization.VerifyFeatures(featureSetup); err != nil {
+			log.Println("Model does not match feature configuration file:", MdFeaturesFile)
+			log.Fatalln(err)
+		}
+	}
 	extractor := SetupExtractor(featureSetup, []byte("MPL"))
 
 	log.Println()
@@ -429,6 +459,11 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 		// combined = combined[:NUM_SENTS]
 		goldSequences := TrainingSequences(combined, GetMDConfigAsLattices, GetMDConfigAsMappings)
+		TrainConfig = NewModelConfig(MODEL_MD, MdFeaturesFile)
+		TrainConfig.ParamFunc = MdParamFuncName
+		TrainConfig.UsePOP = UsePOP
+		TrainConfig.WordBased = MdUseWB
+		TrainConfig.IgnoreLemma = lattice.IGNORE_LEMMA
 		if allOut {
 			log.Println("Generated", len(goldSequences), "training sequences")
 			log.Println()
@@ -443,10 +478,12 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		model = transitionmodel.NewAvgMatrixSparse(NumFeatures, formatters, false)
 
 		conf := &disambig.MDConfig{
-			ETokens:     ETokens,
-			POP:         POP,
-			Transitions: ETrans,
-			ParamFunc:   paramFunc,
+			ETokens:         ETokens,
+			POP:             POP,
+			Transitions:     ETrans,
+			ParamFunc:       paramFunc,
+			UsePOP:          UsePOP,
+			SwitchFormLemma: !lattice.IGNORE_LEMMA,
 		}
 
 		beam := &search.Beam{
@@ -457,6 +494,7 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 			ConcurrentExec:       ConcurrentBeam,
 			Transitions:          ETrans,
 			EstimatedTransitions: 1000, // chosen by random dice roll
+			Update:               UpdateStrategy,
 		}
 
 		// old research stuff
@@ -658,10 +696,7 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 			// util.LogMemory()
 			log.Println()
 			log.Println("Writing final model to", outModelFile)
-			serialization := &Serialization{
-				model.Serialize(-1),
-				EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
-			}
+			serialization := NewSerialization(model.Serialize(-1))
 			WriteModel(outModelFile, serialization)
 			log.Println("Done")
 			// log.Print("Parsing test")
@@ -671,8 +706,10 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	if allOut {
 		log.Println("Found model file", outModelFile, " ... loading model")
 	}
-	serialization := ReadModel(outModelFile)
-	model.Deserialize(serialization.WeightModel)
+	if err := serialization.LoadWeights(model); err != nil {
+		log.Println("Failed loading model weights:", outModelFile)
+		log.Fatalln(err)
+	}
 	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
 
 	if MdUseWB {
@@ -696,10 +733,12 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 
 	// setup configuration and beam
 	conf := &disambig.MDConfig{
-		ETokens:     ETokens,
-		POP:         POP,
-		Transitions: ETrans,
-		ParamFunc:   paramFunc,
+		ETokens:         ETokens,
+		POP:             POP,
+		Transitions:     ETrans,
+		ParamFunc:       paramFunc,
+		UsePOP:          UsePOP,
+		SwitchFormLemma: !lattice.IGNORE_LEMMA,
 	}
 
 	beam := &search.Beam{
@@ -731,7 +770,7 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		if allOut {
 			log.Println("Starting parser")
 		}
-		go ParseStream(predAmbLatStream, mappings, beam)
+		go ParseStreamParallel(predAmbLatStream, mappings, beam, DecodeWorkers)
 		if allOut {
 			log.Println("Creating writer stream to", outMap)
 		}
@@ -827,7 +866,26 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	beam.ShortTempAgenda = true
 	beam.Model = model
 
-	mappings := Parse(predAmbLat, beam)
+	if KBest > 1 {
+		log.Println("Parsing the", KBest, "best analyses of each sentence")
+		kBest := ParseKBestParallel(predAmbLat, beam, DecodeWorkers, KBest)
+		err := WriteKBest(outMap, kBest, func(writer io.Writer, i int, parsed []interface{}) {
+			if useConllU {
+				mapping.UDWrite(writer, parsed, clAmb[i:i+1])
+			} else if writeOffsets {
+				mapping.WriteOffsets(writer, parsed)
+			} else {
+				mapping.Write(writer, parsed)
+			}
+		})
+		if err != nil {
+			log.Fatalln("Failed writing", outMap, err)
+		}
+		kBestWritten(kBest, "mapping", outMap)
+		return nil
+	}
+
+	mappings := ParseParallel(predAmbLat, beam, DecodeWorkers)
 
 	/*	if allOut {
 			log.Println("Converting", len(parsedGraphs), "to conll")
@@ -855,6 +913,8 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	}
 	if useConllU {
 		mapping.UDWriteFile(outMap, mappings, clAmb)
+	} else if writeOffsets {
+		mapping.WriteOffsetsFile(outMap, mappings)
 	} else {
 		mapping.WriteFile(outMap, mappings)
 	}
@@ -880,6 +940,15 @@ runs standalone morphological disambiguation training and parsing
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Minimum Number of Perceptron Iterations")
+	cmd.Flag.StringVar(&UpdateStrategy, "update", search.UPDATE_EARLY, "Perceptron update strategy (early, max-violation or latest)")
+	cmd.Flag.Int64Var(&Seed, "seed", 0, "Shuffle training instances every iteration with this seed (0 for file order)")
+	cmd.Flag.StringVar(&LearnerName, "learner", transitionmodel.LEARNER_PERCEPTRON, "Online learner (perceptron, pa or adagrad)")
+	cmd.Flag.Float64Var(&LearnerRate, "rate", 1.0, "Learner aggressiveness (pa) or learning rate (adagrad)")
+	cmd.Flag.IntVar(&TrainWorkers, "train-workers", 1, "Number of shards to train in parallel with iterative parameter mixing")
+	cmd.Flag.StringVar(&Mixing, "mixing", perceptron.MIX_UNIFORM, "Mixing weights of parallel training (uniform or errors)")
+	cmd.Flag.StringVar(&CheckpointFile, "checkpoint", "", "Training checkpoint file (none if empty)")
+	cmd.Flag.IntVar(&CheckpointEvery, "checkpoint_every", 0, "Checkpoint every n training instances (0 for only after each iteration)")
+	cmd.Flag.BoolVar(&Resume, "resume", false, "Resume training from the checkpoint file")
 	cmd.Flag.IntVar(&BeamSize, "b", 32, "Beam Size")
 	cmd.Flag.StringVar(&MdModelFile, "m", "model", "Prefix for model file ({m}.b{b}.model)")
 	cmd.Flag.StringVar(&MdModelName, "mn", "hebmd.b32", "Modelfile")
@@ -891,6 +960,7 @@ runs standalone morphological disambiguation training and parsing
 	cmd.Flag.StringVar(&test, "test", "", "Test Ambiguous Lattices File")
 	cmd.Flag.StringVar(&testGold, "testgold", "", "Optional - Gold Test Lattices File (for infusion into test ambiguous)")
 	cmd.Flag.StringVar(&outMap, "om", "", "Output Mapping File")
+	cmd.Flag.BoolVar(&writeOffsets, "offsets", false, "Add the character offsets of each morpheme (from the input lattice) to the output mapping")
 	cmd.Flag.StringVar(&MdFeaturesFile, "f", "standalone.md.yaml", "Features Configuration File")
 	cmd.Flag.StringVar(&MdParamFuncName, "p", "Funcs_Main_POS_Both_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"]")
 	cmd.Flag.BoolVar(&AlignBeam, "align", false, "Use Beam Alignment")
@@ -908,5 +978,7 @@ runs standalone morphological disambiguation training and parsing
 	cmd.Flag.IntVar(&limit, "limit", 0, "limit training set")
 	cmd.Flag.BoolVar(&MdNoconverge, "noconverge", false, "don't test convergence (run -it number of iterations)")
 	cmd.Flag.BoolVar(&Stream, "stream", false, "Stream data from input through parser to output")
+	cmd.Flag.IntVar(&DecodeWorkers, "workers", 1, "Number of sentences decoded in parallel, each with its own beam sharing the model")
+	cmd.Flag.IntVar(&KBest, "kbest", 1, "Number of best analyses to output for each sentence, ranked with their scores (at most the beam size)")
 	return cmd
 }
@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package app
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// readModelFile returns the contents of a model file; memory mapping is
+// not supported on this platform
+func readModelFile(file *os.File, mmap bool) ([]byte, func(), error) {
+	contents, err := ioutil.ReadAll(file)
+	return contents, func() {}, err
+}
@@ -0,0 +1,31 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+)
+
+// readModelFile returns the contents of a model file, memory mapped if
+// mmap, and a function releasing them
+func readModelFile(file *os.File, mmap bool) ([]byte, func(), error) {
+	if !mmap {
+		contents, err := ioutil.ReadAll(file)
+		return contents, func() {}, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() == 0 {
+		return nil, func() {}, nil
+	}
+	contents, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return contents, func() { syscall.Munmap(contents) }, nil
+}
@@ -0,0 +1,418 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+
+	"yap/alg/transition"
+	"yap/util"
+	"yap/util/conf"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+// MODEL_VERSION is the version of the model files written by WriteModel;
+// a model file is its Serialization followed by a modelSeal with the
+// version and a checksum of the serialization
+const MODEL_VERSION = 1
+
+// model components, as recorded in a ModelConfig
+const (
+	MODEL_DEP   = "dep"
+	MODEL_MD    = "md"
+	MODEL_JOINT = "joint"
+)
+
+// ModelConfig is the training configuration saved with a model, from which
+// loaders configure themselves instead of flags
+type ModelConfig struct {
+	Component string
+	// contents (and name, for reference) of the features configuration file
+	Features     []byte
+	FeaturesFile string
+	// dependency labels (dep, joint)
+	Labels []string
+	// arc system (dep, joint)
+	ArcSystem string
+	// MD param func, transition options (md, joint)
+	ParamFunc string
+	UsePOP    bool
+	WordBased bool
+	// joint and oracle strategies (joint)
+	JointStrategy, OracleStrategy string
+	IgnoreLemma                   bool
+}
+
+// TrainConfig is the configuration saved with models written during
+// training (see serialize)
+var TrainConfig *ModelConfig
+
+type modelSeal struct {
+	Version  int
+	Checksum []byte
+}
+
+// NewModelConfig returns the configuration of a component being trained
+// with the given features file
+func NewModelConfig(component, featuresFile string) *ModelConfig {
+	features, err := ioutil.ReadFile(featuresFile)
+	if err != nil {
+		log.Fatalln("Failed reading feature configuration file:", featuresFile, err)
+	}
+	return &ModelConfig{
+		Component:    component,
+		Features:     features,
+		FeaturesFile: featuresFile,
+	}
+}
+
+// FeatureSetup returns the model's feature setup, or for models without
+// a configuration the one in featuresFile
+func (c *ModelConfig) FeatureSetup(featuresFile string) (*transition.FeatureSetup, error) {
+	if c == nil {
+		return transition.LoadFeatureConfFile(featuresFile)
+	}
+	return transition.LoadFeatureConf(c.Features), nil
+}
+
+// Relations returns the model's dependency labels, or for models without a
+// configuration the ones in labelsFile
+func (c *ModelConfig) Relations(labelsFile string) ([]string, error) {
+	if c == nil {
+		relations, err := conf.ReadFile(labelsFile)
+		if err != nil {
+			return nil, err
+		}
+		return relations.Values, nil
+	}
+	return c.Labels, nil
+}
+
+// Verify returns an error if the model is not of the given component
+func (c *ModelConfig) Verify(component string) error {
+	if c == nil || c.Component == component {
+		return nil
+	}
+	return fmt.Errorf("model is a %v model, expected a %v model", c.Component, component)
+}
+
+// VerifyArcSystem returns an error if the model was trained with another
+// arc system than the one a loader supports
+func (c *ModelConfig) VerifyArcSystem(arcSystem string) error {
+	if c == nil || c.ArcSystem == arcSystem {
+		return nil
+	}
+	return fmt.Errorf("model was trained with the %v arc system, only %v is supported", c.ArcSystem, arcSystem)
+}
+
+// ModelFlags names the flags of a command setting each part of a model's
+// configuration; parts the command has no flag for are left empty
+type ModelFlags struct {
+	Features, Labels, ArcSystem, ParamFunc, UsePOP, WordBased string
+	JointStrategy, OracleStrategy, IgnoreLemma                string
+}
+
+var (
+	DepModelFlags   = ModelFlags{Features: "f", Labels: "l", ArcSystem: "a", IgnoreLemma: "nolemma"}
+	MDModelFlags    = ModelFlags{Features: "f", ParamFunc: "p", UsePOP: "pop", WordBased: "wb", IgnoreLemma: "nolemma"}
+	JointModelFlags = ModelFlags{Features: "f", Labels: "l", ArcSystem: "a", ParamFunc: "p", UsePOP: "pop",
+		JointStrategy: "jointstr", OracleStrategy: "oraclestr", IgnoreLemma: "nolemma"}
+
+	// configurations of the models loaded by the command, by component
+	LoadedConfigs = make(map[string]*ModelConfig)
+
+	// values set from models, by flag, so models loaded in one process
+	// (e.g. by the api server) must agree on them
+	modelFlagValues = make(map[string]string)
+)
+
+// ConfigureFromModel sets the flags of cmd from the model's configuration,
+// panicking if the model is of another component or was trained with
+// another value of a flag set on the command line; models without a
+// configuration leave the flags as they are
+func ConfigureFromModel(cmd *commander.Command, component string, config *ModelConfig, flags ModelFlags) {
+	if config == nil {
+		log.Println("Model has no saved configuration, using flags")
+		return
+	}
+	if err := config.Verify(component); err != nil {
+		panic(err.Error())
+	}
+	setOnCommandLine := make(map[string]bool)
+	cmd.Flag.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+	for _, file := range []struct {
+		name     string
+		contents []byte
+		dirs     []string
+	}{
+		{flags.Features, config.Features, DEFAULT_CONF_DIRS},
+		{flags.Labels, labelsContents(config.Labels), DEFAULT_CONF_DIRS},
+	} {
+		if len(file.name) == 0 || !setOnCommandLine[file.name] {
+			continue
+		}
+		filename := cmd.Flag.Lookup(file.name).Value.String()
+		if !sameContents(filename, file.dirs, file.contents, file.name == flags.Labels) {
+			panic(fmt.Sprintf("Model was trained with a different -%v than %v", file.name, filename))
+		}
+	}
+	for _, value := range []struct{ name, value string }{
+		{flags.ArcSystem, config.ArcSystem},
+		{flags.ParamFunc, config.ParamFunc},
+		{flags.UsePOP, strconv.FormatBool(config.UsePOP)},
+		{flags.WordBased, strconv.FormatBool(config.WordBased)},
+		{flags.JointStrategy, config.JointStrategy},
+		{flags.OracleStrategy, config.OracleStrategy},
+		{flags.IgnoreLemma, strconv.FormatBool(config.IgnoreLemma)},
+	} {
+		if len(value.name) == 0 {
+			continue
+		}
+		f := cmd.Flag.Lookup(value.name)
+		if setOnCommandLine[value.name] && f.Value.String() != value.value {
+			panic(fmt.Sprintf("Model was trained with -%v=%v, got -%v=%v", value.name, value.value, value.name, f.Value.String()))
+		}
+		if prev, exists := modelFlagValues[value.name]; exists && prev != value.value {
+			panic(fmt.Sprintf("Models were trained with different -%v (%v and %v)", value.name, prev, value.value))
+		}
+		modelFlagValues[value.name] = value.value
+		f.Value.Set(value.value)
+	}
+	LoadedConfigs[component] = config
+	log.Println("Configured from model:", config.Component, "features", config.FeaturesFile)
+}
+
+func labelsContents(labels []string) []byte {
+	var buf bytes.Buffer
+	for _, label := range labels {
+		buf.WriteString(label)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func sameContents(filename string, dirs []string, contents []byte, isLabels bool) bool {
+	if location, found := util.LocateFile(filename, dirs); found {
+		filename = location
+	}
+	if isLabels {
+		relations, err := conf.ReadFile(filename)
+		if err != nil {
+			return false
+		}
+		return bytes.Equal(labelsContents(relations.Values), contents)
+	}
+	fileContents, err := ioutil.ReadFile(filename)
+	return err == nil && bytes.Equal(fileContents, contents)
+}
+
+// hashReader hashes what is read through it; as an io.ByteReader, gob
+// decoders read from it exactly the messages they decode
+type hashReader struct {
+	r    *bufio.Reader
+	hash hash.Hash
+}
+
+func (h *hashReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	return n, err
+}
+
+func (h *hashReader) ReadByte() (byte, error) {
+	b, err := h.r.ReadByte()
+	if err == nil {
+		h.hash.Write([]byte{b})
+	}
+	return b, err
+}
+
+// decodeGob decodes the next gob message of a model file into value;
+// decoding corrupt messages may panic (e.g. on map keys of unhashable
+// types), which is returned as an error
+func decodeGob(decoder *gob.Decoder, value interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("corrupt model file: %v", r)
+		}
+	}()
+	return decoder.Decode(value)
+}
+
+// EncodeModel writes a model: its serialization followed by the format
+// version and a checksum
+func EncodeModel(writer io.Writer, data *Serialization) error {
+	buffered := bufio.NewWriter(writer)
+	checksum := sha256.New()
+	if err := gob.NewEncoder(io.MultiWriter(buffered, checksum)).Encode(data); err != nil {
+		return err
+	}
+	seal := &modelSeal{MODEL_VERSION, checksum.Sum(nil)}
+	if err := gob.NewEncoder(buffered).Encode(seal); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// DecodeModel reads a model written by EncodeModel, verifying its version
+// and checksum; models written before versioning have neither
+func DecodeModel(reader io.Reader) (*Serialization, error) {
+	hashed := &hashReader{bufio.NewReader(reader), sha256.New()}
+	data := &Serialization{}
+	if err := decodeGob(gob.NewDecoder(hashed), data); err != nil {
+		switch err {
+		case io.EOF:
+			return nil, fmt.Errorf("empty model file")
+		case io.ErrUnexpectedEOF:
+			return nil, fmt.Errorf("truncated model file")
+		}
+		return nil, fmt.Errorf("not a model file: %v", err)
+	}
+	checksum := hashed.hash.Sum(nil)
+	seal := &modelSeal{}
+	if err := decodeGob(gob.NewDecoder(hashed), seal); err != nil {
+		if err == io.EOF && data.Config == nil {
+			return data, nil
+		}
+		return nil, fmt.Errorf("missing model checksum: %v", err)
+	}
+	if seal.Version < 1 {
+		return nil, fmt.Errorf("missing model format version, the file is corrupt")
+	}
+	if seal.Version > MODEL_VERSION {
+		return nil, fmt.Errorf("model format version %v is newer than supported (%v)", seal.Version, MODEL_VERSION)
+	}
+	if !bytes.Equal(seal.Checksum, checksum) {
+		return nil, fmt.Errorf("model checksum mismatch, the file is corrupt")
+	}
+	return data, nil
+}
+
+func WriteModel(file string, data *Serialization) {
+	fObj, err := os.Create(file)
+	if err != nil {
+		log.Fatalln("Failed creating model file", file, err)
+		return
+	}
+	defer func() {
+		fObj.Close()
+		if r := recover(); r != nil {
+			fmt.Println(r)
+		}
+	}()
+	err = EncodeModel(fObj, data)
+	if err != nil {
+		log.Fatalln("Failed writing model model to", file, err)
+		panic("Failed to write model")
+	}
+}
+
+// ReadModel reads a model file in either format, see DecodeModel and
+// DecodeBinaryModel
+func ReadModel(file string) (*Serialization, error) {
+	format, err := ModelFormat(file)
+	if err != nil {
+		return nil, err
+	}
+	fObj, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fObj.Close()
+	var data *Serialization
+	if format == MODEL_FORMAT_BINARY {
+		data, err = readBinaryModel(fObj)
+	} else {
+		data, err = DecodeModel(fObj)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return data, nil
+}
+
+// LoadModel reads a model file and validates it is a model of the given
+// component
+func LoadModel(file, component string) (*Serialization, error) {
+	data, err := ReadModel(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := data.Validate(component); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return data, nil
+}
+
+// Validate returns an error if the model is not a complete model of the
+// given component; the component of models without a configuration is
+// inferred from their transitions
+func (s *Serialization) Validate(component string) error {
+	if err := s.Config.Verify(component); err != nil {
+		return err
+	}
+	if s.Templates() == 0 {
+		return fmt.Errorf("model has no weights")
+	}
+	enumSets := []struct {
+		name string
+		set  *util.EnumSet
+	}{
+		{"word", s.EWord}, {"POS", s.EPOS}, {"word+POS", s.EWPOS},
+		{"morph host", s.EMHost}, {"morph suffix", s.EMSuffix}, {"transition", s.ETrans},
+	}
+	if component != MODEL_DEP {
+		enumSets = append(enumSets, struct {
+			name string
+			set  *util.EnumSet
+		}{"token", s.ETokens})
+	}
+	for _, enumSet := range enumSets {
+		if enumSet.set == nil {
+			return fmt.Errorf("model is missing the %v enum set", enumSet.name)
+		}
+	}
+	if s.Config == nil {
+		if inferred := inferComponent(s.ETrans); len(inferred) > 0 && inferred != component {
+			return fmt.Errorf("model has the transitions of the %v component, expected %v", inferred, component)
+		}
+	}
+	return nil
+}
+
+// VerifyFeatures returns an error if the model was trained with another
+// number of feature templates than the feature setup has
+func (s *Serialization) VerifyFeatures(setup *transition.FeatureSetup) error {
+	if modelFeatures, setupFeatures := s.Templates(), setup.NumFeatures(); modelFeatures != setupFeatures {
+		return fmt.Errorf("model has %v feature templates, the feature configuration has %v", modelFeatures, setupFeatures)
+	}
+	return nil
+}
+
+// inferComponent returns the component whose transitions eTrans has (see
+// SetupTransEnum, SetupMDEnum and SetupMorphTransEnum), or "" if unknown
+func inferComponent(eTrans *util.EnumSet) string {
+	_, shift := eTrans.IndexOf("SH")
+	_, pop := eTrans.IndexOf("POP")
+	switch {
+	case shift && pop:
+		return MODEL_JOINT
+	case shift:
+		return MODEL_DEP
+	case pop:
+		return MODEL_MD
+	}
+	return ""
+}
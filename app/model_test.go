@@ -0,0 +1,240 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yap/alg/transition"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func encodeModel(t *testing.T, s *Serialization) []byte {
+	var buf bytes.Buffer
+	if err := EncodeModel(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestModelDecode(t *testing.T) {
+	written := testSerialization(MODEL_JOINT)
+	read, err := DecodeModel(bytes.NewReader(encodeModel(t, written)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameModel(t, "gob", read, written)
+}
+
+func TestModelTruncated(t *testing.T) {
+	contents := encodeModel(t, testSerialization(MODEL_DEP))
+	for n := 0; n < len(contents); n++ {
+		if _, err := DecodeModel(bytes.NewReader(contents[:n])); err == nil {
+			t.Errorf("model truncated to %v of %v bytes decoded", n, len(contents))
+		}
+	}
+}
+
+func TestModelCorrupted(t *testing.T) {
+	written := testSerialization(MODEL_DEP)
+	contents := encodeModel(t, written)
+	var seal bytes.Buffer
+	if err := gob.NewEncoder(&seal).Encode(&modelSeal{MODEL_VERSION, make([]byte, sha256.Size)}); err != nil {
+		t.Fatal(err)
+	}
+	sealStart := len(contents) - seal.Len()
+	for i := range contents {
+		corrupted := append([]byte(nil), contents...)
+		corrupted[i] ^= 0x01
+		read, err := DecodeModel(bytes.NewReader(corrupted))
+		if err != nil {
+			continue
+		}
+		// gob ignores some bytes of the seal's encoding (e.g. its type
+		// name), those flips must still decode the model as written
+		if i < sealStart {
+			t.Errorf("model with byte %v flipped decoded", i)
+		} else {
+			sameModel(t, fmt.Sprintf("seal byte %v flipped", i), read, written)
+		}
+	}
+}
+
+func TestModelLegacy(t *testing.T) {
+	// models written before versioning have neither a configuration nor a seal
+	written := testSerialization(MODEL_MD)
+	written.Config = nil
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(written); err != nil {
+		t.Fatal(err)
+	}
+	read, err := DecodeModel(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameModel(t, "legacy", read, written)
+
+	// a configured model without its seal was cut short
+	written = testSerialization(MODEL_MD)
+	buf.Reset()
+	if err := gob.NewEncoder(&buf).Encode(written); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeModel(bytes.NewReader(buf.Bytes())); err == nil || !strings.HasPrefix(err.Error(), "missing model checksum") {
+		t.Errorf("configured model without a seal decoded with error %v", err)
+	}
+}
+
+func testModelCommand(args ...string) *commander.Command {
+	cmd := &commander.Command{Flag: *flag.NewFlagSet("test", flag.ContinueOnError)}
+	cmd.Flag.String("f", "", "Features configuration file")
+	cmd.Flag.String("a", "eager", "Arc system")
+	cmd.Flag.Bool("nolemma", false, "Ignore lemmas")
+	cmd.Flag.Parse(args)
+	return cmd
+}
+
+// configure runs ConfigureFromModel, returning its panic
+func configure(cmd *commander.Command, component string, config *ModelConfig) (message string) {
+	defer func() {
+		if r := recover(); r != nil {
+			message = fmt.Sprint(r)
+		}
+	}()
+	ConfigureFromModel(cmd, component, config, DepModelFlags)
+	return ""
+}
+
+func TestConfigureFromModel(t *testing.T) {
+	defer func(configs map[string]*ModelConfig, values map[string]string) {
+		LoadedConfigs, modelFlagValues = configs, values
+	}(LoadedConfigs, modelFlagValues)
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	features, otherFeatures := filepath.Join(dir, "features.yaml"), filepath.Join(dir, "other.yaml")
+	ioutil.WriteFile(features, []byte("features"), 0644)
+	ioutil.WriteFile(otherFeatures, []byte("other features"), 0644)
+
+	trained := func(component, arcSystem string, ignoreLemma bool) *ModelConfig {
+		config := testSerialization(component).Config
+		config.ArcSystem, config.IgnoreLemma = arcSystem, ignoreLemma
+		return config
+	}
+	for _, test := range []struct {
+		name      string
+		args      []string
+		loaded    *ModelConfig
+		config    *ModelConfig
+		component string
+		message   string
+		flags     map[string]string
+	}{
+		{"legacy model", []string{"-a", "standard"}, nil, nil, MODEL_DEP, "",
+			map[string]string{"a": "standard", "nolemma": "false"}},
+		{"configured", nil, nil, trained(MODEL_DEP, "standard", true), MODEL_DEP, "",
+			map[string]string{"a": "standard", "nolemma": "true"}},
+		{"same flags", []string{"-a", "standard", "-f", features}, nil, trained(MODEL_DEP, "standard", false), MODEL_DEP, "",
+			map[string]string{"a": "standard", "f": features}},
+		{"md model as dep", nil, nil, trained(MODEL_MD, "", false), MODEL_DEP,
+			"model is a md model, expected a dep model", nil},
+		{"other flag", []string{"-a", "eager"}, nil, trained(MODEL_DEP, "standard", false), MODEL_DEP,
+			"Model was trained with -a=standard, got -a=eager", nil},
+		{"other features", []string{"-f", otherFeatures}, nil, trained(MODEL_DEP, "standard", false), MODEL_DEP,
+			"Model was trained with a different -f than " + otherFeatures, nil},
+		{"models disagree", nil, trained(MODEL_DEP, "eager", false), trained(MODEL_DEP, "standard", false), MODEL_DEP,
+			"Models were trained with different -a (eager and standard)", nil},
+	} {
+		LoadedConfigs, modelFlagValues = make(map[string]*ModelConfig), make(map[string]string)
+		cmd := testModelCommand(test.args...)
+		if test.loaded != nil {
+			if message := configure(testModelCommand(), test.component, test.loaded); len(message) > 0 {
+				t.Fatalf("%v: %v", test.name, message)
+			}
+		}
+		if message := configure(cmd, test.component, test.config); message != test.message {
+			t.Errorf("%v: configured with %q, expected %q", test.name, message, test.message)
+		}
+		for name, value := range test.flags {
+			if set := cmd.Flag.Lookup(name).Value.String(); set != value {
+				t.Errorf("%v: -%v=%v, expected %v", test.name, name, set, value)
+			}
+		}
+		if len(test.message) == 0 && LoadedConfigs[test.component] != test.config {
+			t.Errorf("%v: loaded configuration %+v, expected %+v", test.name, LoadedConfigs[test.component], test.config)
+		}
+	}
+}
+
+func TestModelValidate(t *testing.T) {
+	legacy := func(component string) *Serialization {
+		s := testSerialization(component)
+		s.Config = nil
+		return s
+	}
+	for _, test := range []struct {
+		name      string
+		model     *Serialization
+		component string
+		message   string
+	}{
+		{"dep", testSerialization(MODEL_DEP), MODEL_DEP, ""},
+		{"joint", testSerialization(MODEL_JOINT), MODEL_JOINT, ""},
+		{"legacy md", legacy(MODEL_MD), MODEL_MD, ""},
+		{"md as joint", testSerialization(MODEL_MD), MODEL_JOINT, "model is a md model, expected a joint model"},
+		{"legacy md as joint", legacy(MODEL_MD), MODEL_JOINT, "model has the transitions of the md component, expected joint"},
+		{"legacy joint as dep", legacy(MODEL_JOINT), MODEL_DEP, "model has the transitions of the joint component, expected dep"},
+		{"no weights", func() *Serialization {
+			s := testSerialization(MODEL_DEP)
+			s.WeightModel = nil
+			return s
+		}(), MODEL_DEP, "model has no weights"},
+		{"missing word+POS", func() *Serialization {
+			s := testSerialization(MODEL_DEP)
+			s.EWPOS = nil
+			return s
+		}(), MODEL_DEP, "model is missing the word+POS enum set"},
+		{"md missing tokens", func() *Serialization {
+			s := testSerialization(MODEL_MD)
+			s.ETokens = nil
+			return s
+		}(), MODEL_MD, "model is missing the token enum set"},
+	} {
+		err := test.model.Validate(test.component)
+		if len(test.message) == 0 {
+			if err != nil {
+				t.Errorf("%v: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.message {
+			t.Errorf("%v: validated with error %v, expected %q", test.name, err, test.message)
+		}
+	}
+}
+
+func TestModelVerifyFeatures(t *testing.T) {
+	s := testSerialization(MODEL_DEP)
+	for templates, message := range map[int]string{
+		2: "",
+		3: "model has 2 feature templates, the feature configuration has 3",
+	} {
+		setup := &transition.FeatureSetup{FeatureGroups: []transition.FeatureGroup{
+			{Group: "test", Transition: "Arc", Features: []string{"S0|w,S0|w", "S0|w+N0|p,S0|w", "N0|w,N0|w"}[:templates]},
+		}}
+		err := s.VerifyFeatures(setup)
+		if len(message) == 0 && err != nil || len(message) > 0 && (err == nil || err.Error() != message) {
+			t.Errorf("%v templates: verified with error %v, expected %q", templates, err, message)
+		}
+	}
+}
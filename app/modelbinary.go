@@ -0,0 +1,181 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+
+	"yap/alg/transition/model"
+)
+
+// model file formats
+const (
+	MODEL_FORMAT_GOB    = "gob"
+	MODEL_FORMAT_BINARY = "binary"
+)
+
+// BINARY_MODEL_MAGIC starts model files in the binary format: the magic,
+// the format version, the length of the gob encoded Serialization without
+// its weights and the Serialization, the weights in the binary format of
+// model.AvgMatrixSparse, and a checksum of all of these
+var BINARY_MODEL_MAGIC = []byte("YAPMODEL")
+
+// MmapModels memory maps binary model files while reading them, instead of
+// reading them to memory
+var MmapModels bool
+
+// EncodeBinaryModel writes a model in the binary format
+func EncodeBinaryModel(writer io.Writer, data *Serialization) error {
+	if data.WeightModel == nil {
+		if err := data.serializeWeights(); err != nil {
+			return err
+		}
+	}
+	header := *data
+	header.WeightModel, header.weights, header.release = nil, nil, nil
+	var headerBuf bytes.Buffer
+	if err := gob.NewEncoder(&headerBuf).Encode(&header); err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(writer)
+	checksum := sha256.New()
+	out := io.MultiWriter(buffered, checksum)
+	var scratch [binary.MaxVarintLen64]byte
+	out.Write(BINARY_MODEL_MAGIC)
+	out.Write(scratch[:binary.PutUvarint(scratch[:], MODEL_VERSION)])
+	out.Write(scratch[:binary.PutUvarint(scratch[:], uint64(headerBuf.Len()))])
+	out.Write(headerBuf.Bytes())
+	if err := data.WeightModel.WriteBinary(out); err != nil {
+		return err
+	}
+	buffered.Write(checksum.Sum(nil))
+	return buffered.Flush()
+}
+
+// DecodeBinaryModel reads a model written by EncodeBinaryModel, verifying
+// its version and checksum; its weights are read by LoadWeights
+func DecodeBinaryModel(contents []byte) (*Serialization, error) {
+	if !bytes.HasPrefix(contents, BINARY_MODEL_MAGIC) {
+		return nil, fmt.Errorf("not a binary model file")
+	}
+	if len(contents) < len(BINARY_MODEL_MAGIC)+sha256.Size {
+		return nil, fmt.Errorf("truncated model file")
+	}
+	contents, sum := contents[:len(contents)-sha256.Size], contents[len(contents)-sha256.Size:]
+	if checksum := sha256.Sum256(contents); !bytes.Equal(checksum[:], sum) {
+		return nil, fmt.Errorf("model checksum mismatch, the file is corrupt or truncated")
+	}
+	pos := len(BINARY_MODEL_MAGIC)
+	version, n := binary.Uvarint(contents[pos:])
+	if n <= 0 {
+		return nil, fmt.Errorf("truncated model file")
+	}
+	pos += n
+	if version > MODEL_VERSION {
+		return nil, fmt.Errorf("model format version %v is newer than supported (%v)", version, MODEL_VERSION)
+	}
+	headerLen, n := binary.Uvarint(contents[pos:])
+	if n <= 0 || uint64(len(contents)-pos-n) < headerLen {
+		return nil, fmt.Errorf("truncated model file")
+	}
+	pos += n
+	data := &Serialization{}
+	if err := gob.NewDecoder(bytes.NewReader(contents[pos : pos+int(headerLen)])).Decode(data); err != nil {
+		return nil, fmt.Errorf("failed decoding model header: %v", err)
+	}
+	data.weights = contents[pos+int(headerLen):]
+	if !model.IsBinary(data.weights) {
+		return nil, fmt.Errorf("model has no weights")
+	}
+	return data, nil
+}
+
+// LoadWeights sets the model's weights to the serialization's, releasing
+// the memory mapping of binary model files
+func (s *Serialization) LoadWeights(m *model.AvgMatrixSparse) error {
+	if s.weights == nil {
+		m.Deserialize(s.WeightModel)
+		return nil
+	}
+	n, err := m.ReadBinary(s.weights)
+	length := len(s.weights)
+	s.releaseWeights()
+	if err != nil {
+		return err
+	}
+	if n != length {
+		return fmt.Errorf("unexpected data after the model weights")
+	}
+	return nil
+}
+
+// Templates returns the number of feature templates of the model's weights
+func (s *Serialization) Templates() int {
+	if s.weights != nil {
+		templates, err := model.BinaryTemplates(s.weights)
+		if err != nil {
+			return 0
+		}
+		return templates
+	}
+	if s.WeightModel == nil {
+		return 0
+	}
+	return len(s.WeightModel.Mat)
+}
+
+// serializeWeights sets the WeightModel of binary models, for writing them
+// in the gob format
+func (s *Serialization) serializeWeights() error {
+	if s.weights == nil {
+		return fmt.Errorf("model has no weights")
+	}
+	weights, err := model.ReadBinarySerialized(s.weights)
+	if err != nil {
+		return err
+	}
+	s.WeightModel = weights
+	s.releaseWeights()
+	return nil
+}
+
+func (s *Serialization) releaseWeights() {
+	if s.release != nil {
+		s.release()
+	}
+	s.weights, s.release = nil, nil
+}
+
+// readBinaryModel reads a binary model file, memory mapped if MmapModels
+func readBinaryModel(file *os.File) (*Serialization, error) {
+	contents, release, err := readModelFile(file, MmapModels)
+	if err != nil {
+		return nil, err
+	}
+	data, err := DecodeBinaryModel(contents)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	data.release = release
+	return data, nil
+}
+
+// ModelFormat returns the format of a model file
+func ModelFormat(file string) (string, error) {
+	fObj, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer fObj.Close()
+	magic := make([]byte, len(BINARY_MODEL_MAGIC))
+	if _, err := io.ReadFull(fObj, magic); err == nil && bytes.Equal(magic, BINARY_MODEL_MAGIC) {
+		return MODEL_FORMAT_BINARY, nil
+	}
+	return MODEL_FORMAT_GOB, nil
+}
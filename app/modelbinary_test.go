@@ -0,0 +1,154 @@
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"yap/alg/transition/model"
+	"yap/util"
+)
+
+func testEnumSet(values ...interface{}) *util.EnumSet {
+	e := util.NewEnumSet(len(values))
+	for _, value := range values {
+		e.Add(value)
+	}
+	return e
+}
+
+// testSerialization returns a small model of a component, with the
+// transitions of the component and weights of the feature key types
+// templates generate
+func testSerialization(component string) *Serialization {
+	var transitions []interface{}
+	switch component {
+	case MODEL_DEP:
+		transitions = []interface{}{"IDLE", "SH", "LA-subj"}
+	case MODEL_MD:
+		transitions = []interface{}{"IDLE", "MD-a", "POP"}
+	case MODEL_JOINT:
+		transitions = []interface{}{"IDLE", "SH", "LA-subj", "MD-a", "POP"}
+	}
+	s := &Serialization{
+		WeightModel: &model.AvgMatrixSparseSerialized{
+			Generation: 3,
+			Features:   []string{"s0|w", "s0|w+n0|p"},
+			Mat: []interface{}{
+				map[interface{}]map[int]int64{"dog": {1: 2, 3: -4}, 7: {0: 1}},
+				map[interface{}]map[int]int64{[2]interface{}{"dog", "NN"}: {2: 5}, [2]int{1, 2}: {1: -6}},
+			},
+		},
+		EWord:      testEnumSet("dog", "runs"),
+		EPOS:       testEnumSet("NN", "VB"),
+		EWPOS:      testEnumSet([2]string{"dog", "NN"}),
+		EMHost:     testEnumSet("dog"),
+		EMSuffix:   testEnumSet("s"),
+		EMorphProp: testEnumSet(),
+		ETrans:     testEnumSet(transitions...),
+		Config: &ModelConfig{
+			Component:    component,
+			Features:     []byte("features"),
+			FeaturesFile: "features.yaml",
+		},
+	}
+	if component != MODEL_DEP {
+		s.ETokens = testEnumSet("dog")
+	}
+	return s
+}
+
+func encodeBinaryModel(t *testing.T, s *Serialization) []byte {
+	var buf bytes.Buffer
+	if err := EncodeBinaryModel(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// sameModel checks a model read from a file is the one written to it
+func sameModel(t *testing.T, name string, read, written *Serialization) {
+	if read.WeightModel == nil {
+		m := &model.AvgMatrixSparse{}
+		if err := read.LoadWeights(m); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		read.WeightModel = m.Serialize(-1)
+		read.WeightModel.Features = written.WeightModel.Features
+	}
+	for _, field := range []struct {
+		name          string
+		read, written interface{}
+	}{
+		{"weights", read.WeightModel, written.WeightModel},
+		{"config", read.Config, written.Config},
+		{"word enum set", read.EWord, written.EWord},
+		{"word+POS enum set", read.EWPOS, written.EWPOS},
+		{"transition enum set", read.ETrans, written.ETrans},
+		{"token enum set", read.ETokens, written.ETokens},
+	} {
+		if !reflect.DeepEqual(field.read, field.written) {
+			t.Errorf("%v: read %v %v, expected %v", name, field.name, field.read, field.written)
+		}
+	}
+}
+
+func TestBinaryModelConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(mmap bool) { MmapModels = mmap }(MmapModels)
+	gobFile, binaryFile, convertedFile := filepath.Join(dir, "gob"), filepath.Join(dir, "binary"), filepath.Join(dir, "converted")
+
+	written := testSerialization(MODEL_JOINT)
+	WriteModel(gobFile, written)
+	if err := ConvertModel(gobFile, binaryFile, MODEL_FORMAT_BINARY); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConvertModel(binaryFile, convertedFile, MODEL_FORMAT_GOB); err != nil {
+		t.Fatal(err)
+	}
+	for file, format := range map[string]string{gobFile: MODEL_FORMAT_GOB, binaryFile: MODEL_FORMAT_BINARY, convertedFile: MODEL_FORMAT_GOB} {
+		if detected, err := ModelFormat(file); err != nil || detected != format {
+			t.Errorf("%v: detected format %v (%v), expected %v", filepath.Base(file), detected, err, format)
+		}
+	}
+	for _, mmap := range []bool{false, true} {
+		MmapModels = mmap
+		for _, file := range []string{gobFile, binaryFile, convertedFile} {
+			read, err := LoadModel(file, MODEL_JOINT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameModel(t, filepath.Base(file), read, written)
+		}
+	}
+}
+
+func TestBinaryModelTruncated(t *testing.T) {
+	contents := encodeBinaryModel(t, testSerialization(MODEL_DEP))
+	if _, err := DecodeBinaryModel(contents); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(contents); n++ {
+		if _, err := DecodeBinaryModel(contents[:n]); err == nil {
+			t.Errorf("model truncated to %v of %v bytes decoded", n, len(contents))
+		}
+	}
+}
+
+func TestBinaryModelCorrupted(t *testing.T) {
+	contents := encodeBinaryModel(t, testSerialization(MODEL_DEP))
+	for i := range contents {
+		corrupted := append([]byte(nil), contents...)
+		corrupted[i] ^= 0x01
+		if _, err := DecodeBinaryModel(corrupted); err == nil {
+			t.Errorf("model with byte %v flipped decoded", i)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	convertIn, convertOut, convertFormat string
+)
+
+// ConvertModel converts a model file (in either format) to the given format
+func ConvertModel(in, out, format string) error {
+	data, err := ReadModel(in)
+	if err != nil {
+		return err
+	}
+	fObj, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer fObj.Close()
+	switch format {
+	case MODEL_FORMAT_BINARY:
+		err = EncodeBinaryModel(fObj, data)
+	case MODEL_FORMAT_GOB:
+		if data.WeightModel == nil {
+			if err := data.serializeWeights(); err != nil {
+				return err
+			}
+		}
+		err = EncodeModel(fObj, data)
+	default:
+		return fmt.Errorf("unknown model format %v, must be one of [%v, %v]", format, MODEL_FORMAT_GOB, MODEL_FORMAT_BINARY)
+	}
+	if err != nil {
+		return err
+	}
+	return fObj.Close()
+}
+
+func ModelConvert(cmd *commander.Command, args []string) error {
+	REQUIRED_FLAGS := []string{"in", "out"}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	inFormat, err := ModelFormat(convertIn)
+	if err != nil {
+		log.Fatalln("Failed reading model", convertIn, err)
+	}
+	log.Println("Configuration")
+	log.Printf("Input Model:\t\t%s (%s)", convertIn, inFormat)
+	log.Printf("Output Model:\t\t%s (%s)", convertOut, convertFormat)
+	log.Println()
+	if err := ConvertModel(convertIn, convertOut, convertFormat); err != nil {
+		log.Fatalln("Failed converting model:", err)
+	}
+	log.Println("Wrote", convertFormat, "model to", convertOut)
+	return nil
+}
+
+func ModelConvertCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       ModelConvert,
+		UsageLine: "convert <file options> [arguments]",
+		Short:     "convert a model file between the gob and binary formats",
+		Long: `
+convert a model file between the gob format written by training and the
+binary format, which loads faster and with less memory; all commands
+loading models detect their format
+
+	$ ./yap model convert -in <model file> -out <model file> [-format binary|gob]
+
+`,
+		Flag: *flag.NewFlagSet("convert", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&convertIn, "in", "", "Input model file")
+	cmd.Flag.StringVar(&convertOut, "out", "", "Output model file")
+	cmd.Flag.StringVar(&convertFormat, "format", MODEL_FORMAT_BINARY, "Output model format ["+MODEL_FORMAT_GOB+", "+MODEL_FORMAT_BINARY+"]")
+	return cmd
+}
+
+func ModelCmd() *commander.Command {
+	return &commander.Command{
+		UsageLine:   "model <command> [arguments]",
+		Short:       "manage model files",
+		Subcommands: []*commander.Command{ModelConvertCmd()},
+		Flag:        *flag.NewFlagSet("model", flag.ExitOnError),
+	}
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"log"
+	"strings"
+
+	"yap/alg/perceptron"
+	"yap/alg/search"
+)
+
+var (
+	// number of shards trained in parallel with iterative parameter mixing
+	// (see perceptron.LinearPerceptron), and the weights of their mixing
+	TrainWorkers int
+	Mixing       string
+)
+
+func parallelConfigOut() {
+	if TrainWorkers <= 1 {
+		log.Printf("Train Workers:\t1")
+		return
+	}
+	log.Printf("Train Workers:\t%d (%s mixing)", TrainWorkers, Mixing)
+}
+
+// verifyParallel exits if parallel training was asked for with options it
+// does not support
+func verifyParallel() {
+	if TrainWorkers < 1 {
+		log.Fatalln("-train-workers must be at least 1, got", TrainWorkers)
+	}
+	for _, mixing := range perceptron.MixingWeights {
+		if Mixing == mixing {
+			if TrainWorkers > 1 && CheckpointEvery > 0 {
+				log.Println("Warning: with -train-workers checkpoints are written only after each iteration")
+			}
+			return
+		}
+	}
+	log.Fatalln("Unknown -mixing", Mixing, "expected one of", strings.Join(perceptron.MixingWeights, ", "))
+}
+
+// workerDecoder returns a function returning copies of a training beam for
+// the workers of parallel training, sharing its model and transition system
+// as the beams of ParseParallel
+func workerDecoder(decoder perceptron.EarlyUpdateInstanceDecoder) func() perceptron.EarlyUpdateInstanceDecoder {
+	beam, ok := decoder.(*search.Beam)
+	if !ok {
+		log.Fatalln("Parallel training requires a beam decoder")
+	}
+	return func() perceptron.EarlyUpdateInstanceDecoder {
+		workerBeam := *beam
+		return &workerBeam
+	}
+}
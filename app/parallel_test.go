@@ -0,0 +1,153 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"yap/alg/search"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	"yap/nlp/format/conll"
+	. "yap/nlp/parser/dependency/transition"
+	"yap/util"
+	"yap/util/conf"
+)
+
+const testTreebank = `1	גנן	גנן	NN	NN	gen=M|num=S	2	subj
+2	גידל	גידל	VB	VB	gen=M|num=S|per=3|tense=PAST	0	ROOT
+3	דגן	דגן	NN	NN	gen=M|num=S	2	obj
+4	ב	ב	PREPOSITION	PREPOSITION		3	prepmod
+5	ה	ה	DEF	DEF		6	def
+6	גן	גן	NN	NN	gen=M|num=S	4	pobj
+7	.		yyDOT	yyDOT		2	punct
+
+1	ה	ה	DEF	DEF		2	def
+2	גנן	גנן	NN	NN	gen=M|num=S	3	subj
+3	גידל	גידל	VB	VB	gen=M|num=S|per=3|tense=PAST	0	ROOT
+4	דגן	דגן	NN	NN	gen=M|num=S	3	obj
+5	.		yyDOT	yyDOT		3	punct
+
+1	דגן	דגן	NN	NN	gen=M|num=S	2	subj
+2	גדל	גדל	VB	VB	gen=M|num=S|per=3|tense=PAST	0	ROOT
+3	ב	ב	PREPOSITION	PREPOSITION		2	prepmod
+4	גן	גן	NN	NN	gen=M|num=S	3	pobj
+5	.		yyDOT	yyDOT		2	punct
+
+1	גנן	גנן	NN	NN	gen=M|num=S	2	subj
+2	אכל	אכל	VB	VB	gen=M|num=S|per=3|tense=PAST	0	ROOT
+3	דגן	דגן	NN	NN	gen=M|num=S	2	obj
+4	.		yyDOT	yyDOT		2	punct
+
+`
+
+// testDepBeam trains a dependency beam on the test treebank as the dep
+// command does, returning it with the treebank's tagged sentences
+func testDepBeam(t *testing.T) (*search.Beam, []interface{}) {
+	relations, err := conf.ReadFile("../conf/hebtb.labels.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetupDepEnum(relations.Values)
+	arcSystem := &ArcEager{
+		ArcStandard: ArcStandard{
+			SHIFT:       SH.Value(),
+			LEFT:        LA.Value(),
+			RIGHT:       RA.Value(),
+			Relations:   ERel,
+			Transitions: ETrans,
+		},
+		REDUCE:  RE.Value(),
+		POPROOT: PR.Value(),
+	}
+	arcSystem.AddDefaultOracle()
+	featureSetup, err := transition.LoadFeatureConfFile("../conf/zhangnivre2011.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extractor := SetupExtractor(featureSetup, []byte("A"))
+	group, _ := extractor.TransTypeGroups['A']
+	formatters := make([]util.Format, len(group.FeatureTemplates))
+	for i, formatter := range group.FeatureTemplates {
+		formatters[i] = formatter
+	}
+
+	treebank, err := conll.Read(strings.NewReader(testTreebank), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphs := conll.Conll2GraphCorpus(treebank, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+	sents := make([]interface{}, len(graphs))
+	for i, graph := range graphs {
+		sents[i] = GetAsTaggedSentence(graph)
+	}
+	model := transitionmodel.NewAvgMatrixSparse(featureSetup.NumFeatures(), formatters, true)
+	conf := &SimpleConfiguration{
+		EWord:    EWord,
+		EPOS:     EPOS,
+		EWPOS:    EWPOS,
+		EMHost:   EMHost,
+		EMSuffix: EMSuffix,
+		ERel:     ERel,
+		ETrans:   ETrans,
+	}
+	deterministic := &search.Deterministic{
+		TransFunc:        arcSystem,
+		FeatExtractor:    extractor,
+		ReturnSequence:   true,
+		Base:             conf,
+		DefaultTransType: 'A',
+	}
+	beam := &search.Beam{
+		TransFunc:            arcSystem,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Size:                 4,
+		EstimatedTransitions: EstimatedBeamTransitions(),
+		ScoredStoreDense:     true,
+	}
+	Train(TrainingSequences(graphs, GetAsTaggedSentence, GetAsLabeledDepGraph), 2, "", model, beam, deterministic, nil)
+	beam.Model = model
+	beam.ShortTempAgenda = true
+	return beam, sents
+}
+
+func parsedAsConll(parsed []interface{}) string {
+	var buf bytes.Buffer
+	conll.Write(&buf, conll.Graph2ConllCorpus(parsed, EMHost, EMSuffix))
+	return buf.String()
+}
+
+func TestParseParallel(t *testing.T) {
+	defer func(name string, rate float64, workers int) {
+		LearnerName, LearnerRate, TrainWorkers = name, rate, workers
+	}(LearnerName, LearnerRate, TrainWorkers)
+	LearnerName, LearnerRate, TrainWorkers = transitionmodel.LEARNER_PERCEPTRON, 1, 1
+	beam, sents := testDepBeam(t)
+	// repeated so workers decode sentences of different lengths at once
+	var instances []interface{}
+	for i := 0; i < 5; i++ {
+		instances = append(instances, sents...)
+	}
+	expected := parsedAsConll(Parse(instances, beam))
+
+	if parsed := parsedAsConll(ParseParallel(instances, beam, 4)); parsed != expected {
+		t.Errorf("parsed in parallel as\n%v\nexpected\n%v", parsed, expected)
+	}
+
+	stream, parsedStream := make(chan interface{}), make(chan interface{}, 2)
+	go func() {
+		for _, instance := range instances {
+			stream <- instance
+		}
+		close(stream)
+	}()
+	go ParseStreamParallel(stream, parsedStream, beam, 4)
+	var streamed []interface{}
+	for parsed := range parsedStream {
+		streamed = append(streamed, parsed)
+	}
+	if parsed := parsedAsConll(streamed); parsed != expected {
+		t.Errorf("streamed in parallel as\n%v\nexpected\n%v", parsed, expected)
+	}
+}
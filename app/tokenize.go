@@ -0,0 +1,79 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"yap/nlp/format/raw"
+	"yap/nlp/tokenizer"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var outRawFile string
+
+func TokenizeConfigOut() {
+	log.Println("Configuration")
+	log.Printf("Input Text:\t\t%s", input)
+	log.Printf("Output Raw:\t\t%s", outRawFile)
+	log.Println()
+}
+
+// ReadTokenizedFile reads untokenized text, splitting it into sentences and tokens
+func ReadTokenizedFile(filename string, limit int) ([]tokenizer.Sentence, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sents, err := tokenizer.TokenizeReader(file)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(sents) > limit {
+		sents = sents[:limit]
+	}
+	return sents, nil
+}
+
+func Tokenize(cmd *commander.Command, args []string) error {
+	REQUIRED_FLAGS := []string{"in", "out"}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	TokenizeConfigOut()
+	sents, err := ReadTokenizedFile(input, limit)
+	if err != nil {
+		panic(fmt.Sprintf("Failed reading text file - %v", err))
+	}
+	output := make([]interface{}, len(sents))
+	var tokens int
+	for i, sent := range sents {
+		output[i] = sent.BasicSentence()
+		tokens += len(sent)
+	}
+	if err := raw.WriteFile(outRawFile, output); err != nil {
+		panic(fmt.Sprintf("Failed writing raw file - %v", err))
+	}
+	log.Println("Wrote", len(sents), "sentences of", tokens, "tokens to", outRawFile)
+	return nil
+}
+
+func TokenizeCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       Tokenize,
+		UsageLine: "tokenize <file options> [arguments]",
+		Short:     "split raw Hebrew text into sentences and tokens",
+		Long: `
+split raw Hebrew text into sentences and tokens, writing the raw (tokenized)
+input format of hebma: a token per line, sentences separated by an empty line
+
+	$ ./yap tokenize -in <text file> -out <raw file> [options]
+
+`,
+		Flag: *flag.NewFlagSet("tokenize", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&input, "in", "", "Input (untokenized) text file")
+	cmd.Flag.StringVar(&outRawFile, "out", "", "Output raw (tokenized) file")
+	cmd.Flag.IntVar(&limit, "limit", 0, "Limit input set")
+	return cmd
+}
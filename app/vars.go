@@ -20,11 +20,14 @@ import (
 	dep "yap/nlp/parser/dependency/transition"
 	"yap/nlp/parser/dependency/transition/morph"
 
+	"context"
 	"encoding/gob"
 	"fmt"
 	"log"
 	"os"
 	// "runtime"
+	"strings"
+	"sync"
 	"time"
 	// "strings"
 
@@ -51,6 +54,12 @@ var (
 	UsePOP         bool
 	limit          int
 	Stream         bool
+	// number of sentences decoded in parallel (see ParseParallel)
+	DecodeWorkers int
+	// training update strategy, see search.SearchUpdate
+	UpdateStrategy string
+	// seed of the shuffling of training instances, 0 for file order
+	Seed int64
 
 	// global enumerations
 	ERel, ETrans, EWord, EPOS, EWPOS, EMHost, EMSuffix *util.EnumSet
@@ -79,6 +88,8 @@ var (
 	outLat, outSeg   string
 	outMap           string
 	outConll         string
+	// write character offsets with output lattices, mappings and conll
+	writeOffsets bool
 	//modelFile        string
 	//modelName        string
 	//featuresFile     string
@@ -95,6 +106,24 @@ var (
 	DEFAULT_CONF_DIRS  = []string{".", "conf"}
 )
 
+// Default files and settings of the pretrained Hebrew models, shared by
+// the api and pipeline commands
+const (
+	DEFAULT_HEB_MA_PREFIX_FILE  = "bgupreflex_withdef.utf8.hr"
+	DEFAULT_HEB_MA_LEXICON_FILE = "bgulex.utf8.hr"
+	DEFAULT_MD_MODEL_FILE       = "md_model_temp_i9.b64"
+	DEFAULT_MD_FEATURES_FILE    = "standalone.md.yaml"
+	DEFAULT_MD_PARAM_FUNC       = "Funcs_Main_POS_Both_Prop"
+	DEFAULT_DEP_MODEL_FILE      = "dep_zeager_model_temp_i18.b64"
+	DEFAULT_DEP_FEATURES_FILE   = "zhangnivre2011.yaml"
+	DEFAULT_JOINT_MODEL_FILE    = "joint_arc_zeager_model_temp_i33.b64"
+	DEFAULT_JOINT_FEATURES_FILE = "jointzeager.yaml"
+	DEFAULT_JOINT_STRATEGY      = "ArcGreedy"
+	DEFAULT_ORACLE_STRATEGY     = "ArcGreedy"
+	DEFAULT_LABELS_FILE         = "hebtb.labels.conf"
+	DEFAULT_BEAM_SIZE           = 64
+)
+
 // An approximation of the number of different MD-X:Y:Z transitions
 // Pre-allocating the enumeration saves frequent reallocation during training and parsing
 const (
@@ -110,40 +139,30 @@ type Serialization struct {
 	EMorphProp                           *util.EnumSet
 	ETrans                               *util.EnumSet
 	ETokens                              *util.EnumSet
-}
+	// training configuration, nil in models saved before it was recorded
+	Config *ModelConfig
 
-func WriteModel(file string, data *Serialization) {
-	fObj, err := os.Create(file)
-	if err != nil {
-		log.Fatalln("Failed creating model file", file, err)
-		return
-	}
-	defer func() {
-		fObj.Close()
-		if r := recover(); r != nil {
-			fmt.Println(r)
-		}
-	}()
-	//defer fObj.Close()
-	writer := gob.NewEncoder(fObj)
-	err = writer.Encode(data)
-	if err != nil {
-		log.Fatalln("Failed writing model model to", file, err)
-		panic("Failed to write model")
-	}
+	// weights of models read in the binary format, instead of WeightModel
+	// (see LoadWeights), and the release of their memory mapping
+	weights []byte
+	release func()
 }
 
-func ReadModel(file string) *Serialization {
-	data := &Serialization{}
-	fObj, err := os.Open(file)
-	if err != nil {
-		log.Fatalln("Failed reading model from", file, err)
-		return nil
+// NewSerialization returns a model of the weights and the current enum sets
+// and training configuration
+func NewSerialization(weights *model.AvgMatrixSparseSerialized) *Serialization {
+	return &Serialization{
+		WeightModel: weights,
+		EWord:       EWord,
+		EPOS:        EPOS,
+		EWPOS:       EWPOS,
+		EMHost:      EMHost,
+		EMSuffix:    EMSuffix,
+		EMorphProp:  EMorphProp,
+		ETrans:      ETrans,
+		ETokens:     ETokens,
+		Config:      TrainConfig,
 	}
-	defer fObj.Close()
-	reader := gob.NewDecoder(fObj)
-	reader.Decode(data)
-	return data
 }
 
 func SetupRelationEnum(labels []string) {
@@ -450,19 +469,69 @@ func MorphEval(test, gold interface{}, metric string) *eval.Result {
 	return retval
 }
 
+func orderConfigOut() {
+	if Seed == 0 {
+		log.Printf("Training Order:\tfile")
+		return
+	}
+	log.Printf("Training Order:\tshuffled every iteration (seed %d)", Seed)
+}
+
+// verifyUpdateStrategy exits if the training update strategy is unknown
+func verifyUpdateStrategy() {
+	for _, update := range search.UpdateStrategies {
+		if UpdateStrategy == update {
+			return
+		}
+	}
+	log.Fatalln("Unknown -update strategy", UpdateStrategy, "expected one of", strings.Join(search.UpdateStrategies, ", "))
+}
+
 func Train(trainingSet []perceptron.DecodedInstance, Iterations int, filename string, paramModel perceptron.Model, decoder perceptron.EarlyUpdateInstanceDecoder, goldDecoder perceptron.InstanceDecoder, converge perceptron.StopCondition) *perceptron.LinearPerceptron {
 	updater := new(model.AveragedModelStrategy)
+	learner, err := newLearner()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if beam, ok := decoder.(*search.Beam); ok {
+		beam.CutGold = LearnerName != model.LEARNER_PERCEPTRON
+	}
 
 	perceptron := &perceptron.LinearPerceptron{
 		Decoder:     decoder,
 		GoldDecoder: goldDecoder,
 		Updater:     updater,
+		Learner:     learner,
+		Seed:        Seed,
 		Continue:    converge,
 		Tempfile:    filename,
 		TempLines:   500}
 
 	perceptron.Iterations = Iterations
+	if TrainWorkers > 1 {
+		perceptron.Workers = TrainWorkers
+		perceptron.NewDecoder = workerDecoder(decoder)
+		perceptron.Mixer = &model.ParameterMixer{}
+		perceptron.Mixing = Mixing
+	}
 	perceptron.Init(paramModel)
+	if len(CheckpointFile) > 0 {
+		perceptron.CheckpointEvery = CheckpointEvery
+		perceptron.Checkpoint = checkpointer(CheckpointFile, updater, len(trainingSet))
+	}
+	if Resume {
+		if len(CheckpointFile) == 0 {
+			log.Fatalln("Resuming training requires a checkpoint file")
+		}
+		checkpoint, err := ReadCheckpoint(CheckpointFile)
+		if err != nil {
+			log.Fatalln("Failed reading checkpoint", CheckpointFile, err)
+		}
+		if err := ResumeCheckpoint(checkpoint, perceptron, updater, len(trainingSet)); err != nil {
+			log.Fatalln("Failed resuming from checkpoint", CheckpointFile, err)
+		}
+		log.Println("Resuming training at iteration", perceptron.TrainI, "after instance", perceptron.TrainJ)
+	}
 	// perceptron.TempLoad("model.b64.i1")
 	perceptron.Log = true
 	// beam.Log = true
@@ -506,6 +575,54 @@ func ParseStream(instances chan interface{}, writeStream chan interface{}, parse
 	close(writeStream)
 }
 
+// ContextParser is a Parser that can be cancelled or run out of time (see search.Beam)
+type ContextParser interface {
+	ParseContext(context.Context, search.Problem) (transition.Configuration, interface{}, error)
+}
+
+// ParseStreamContext is ParseStream, writing the parse error in place
+// of the result of each instance that failed (e.g. ran out of time)
+func ParseStreamContext(ctx context.Context, instances chan interface{}, writeStream chan interface{}, parser ContextParser) {
+	startTime := time.Now()
+	var i int
+	for instance := range instances {
+		log.Println("Parsing instance", i)
+		result, _, err := parser.ParseContext(ctx, instance)
+		if err != nil {
+			log.Println("Failed parsing instance", i, "-", err)
+			writeStream <- err
+		} else {
+			writeStream <- result
+		}
+		i++
+	}
+	if allOut {
+		parseTime := time.Since(startTime)
+		log.Println("PARSE Total Time:", parseTime)
+	}
+	close(writeStream)
+}
+
+// ParseContext is Parse, stopping at the first instance that fails
+func ParseContext(ctx context.Context, instances []interface{}, parser ContextParser) ([]interface{}, error) {
+	startTime := time.Now()
+	parsed := make([]interface{}, len(instances))
+	for i, instance := range instances {
+		log.Println("Parsing instance", i)
+		result, _, err := parser.ParseContext(ctx, instance)
+		if err != nil {
+			log.Println("Failed parsing instance", i, "-", err)
+			return nil, err
+		}
+		parsed[i] = result
+	}
+	if allOut {
+		parseTime := time.Since(startTime)
+		log.Println("PARSE Total Time:", parseTime)
+	}
+	return parsed, nil
+}
+
 func Parse(instances []interface{}, parser Parser) []interface{} {
 	// runtime.GOMAXPROCS(1)
 	// Search.AllOut = true
@@ -532,6 +649,111 @@ func Parse(instances []interface{}, parser Parser) []interface{} {
 	return parsed
 }
 
+// parserCopies returns a parser per worker; beams are copied, sharing
+// their model, extractor and transition system, other parsers can't be
+// copied and are used by a single worker
+func parserCopies(parser Parser, workers int) []Parser {
+	beam, isBeam := parser.(*search.Beam)
+	if !isBeam || workers < 2 {
+		return []Parser{parser}
+	}
+	parsers := make([]Parser, workers)
+	for i := range parsers {
+		beamCopy := *beam
+		parsers[i] = &beamCopy
+	}
+	return parsers
+}
+
+// ParseParallel is Parse with workers decoding sentences in parallel,
+// each with its own copy of the parser; results are in input order
+func ParseParallel(instances []interface{}, parser Parser, workers int) []interface{} {
+	parsers := parserCopies(parser, workers)
+	if len(parsers) < 2 {
+		return Parse(instances, parser)
+	}
+	return parseParallel(instances, parsers)
+}
+
+func parseParallel(instances []interface{}, parsers []Parser) []interface{} {
+	startTime := time.Now()
+	parsed := make([]interface{}, len(instances))
+	indices := make(chan int, len(parsers))
+	var wg sync.WaitGroup
+	for _, workerParser := range parsers {
+		wg.Add(1)
+		go func(workerParser Parser) {
+			defer wg.Done()
+			for i := range indices {
+				log.Println("Parsing instance", i)
+				parsed[i], _ = workerParser.Parse(instances[i])
+			}
+		}(workerParser)
+	}
+	for i := range instances {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	if allOut {
+		parseTime := time.Since(startTime)
+		log.Println("PARSE Total Time:", parseTime, "with", len(parsers), "workers")
+	}
+	return parsed
+}
+
+// ParseStreamParallel is ParseStream with workers decoding sentences in
+// parallel, each with its own copy of the parser; results are written
+// in input order, and at most a few sentences per worker are in flight
+func ParseStreamParallel(instances chan interface{}, writeStream chan interface{}, parser Parser, workers int) {
+	parsers := parserCopies(parser, workers)
+	if len(parsers) < 2 {
+		ParseStream(instances, writeStream, parser)
+		return
+	}
+	parseStreamParallel(instances, writeStream, parsers)
+}
+
+func parseStreamParallel(instances chan interface{}, writeStream chan interface{}, parsers []Parser) {
+	type job struct {
+		i        int
+		instance interface{}
+		result   chan interface{}
+	}
+	startTime := time.Now()
+	jobs := make(chan job, len(parsers))
+	// results in input order, bounding the sentences in flight
+	ordered := make(chan chan interface{}, 2*len(parsers))
+	go func() {
+		var i int
+		for instance := range instances {
+			result := make(chan interface{}, 1)
+			ordered <- result
+			jobs <- job{i, instance, result}
+			i++
+		}
+		close(jobs)
+		close(ordered)
+	}()
+	for _, workerParser := range parsers {
+		go func(workerParser Parser) {
+			for j := range jobs {
+				log.Println("Parsing instance", j.i)
+				result, _ := workerParser.Parse(j.instance)
+				j.result <- result
+			}
+		}(workerParser)
+	}
+	for result := range ordered {
+		writeStream <- <-result
+	}
+	if allOut {
+		parseTime := time.Since(startTime)
+		log.Println("PARSE Total Time:", parseTime, "with", len(parsers), "workers")
+	}
+	close(writeStream)
+}
+
 func GetMDConfigAsLattices(instance interface{}) util.Equaler {
 	return instance.(*disambig.MDConfig).Lattices
 }
@@ -577,10 +799,8 @@ func GetInstances(instances []interface{}, getFunc InstanceFunc) []interface{} {
 }
 
 func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interface{}, testInstances []interface{}, testGoldInstances []interface{}, parser Parser, goldDecoder perceptron.InstanceDecoder, beamSize int) perceptron.StopCondition {
-	var (
-		equalIterations int
-		prevResult      float64
-	)
+	// the evaluation state is saved in training checkpoints
+	state := TrainEval
 	return func(curIteration, iterations, generations int, model perceptron.Model) bool {
 		// first write current model
 		serialize(model, curIteration, generations)
@@ -620,10 +840,10 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 		curResult = total.F1()
 		curPosResult = posonlytotal.F1()
 		// Break out of edge case where result remains the same
-		if curResult == prevResult {
-			equalIterations += 1
+		if curResult == state.PrevResult {
+			state.EqualIterations += 1
 		}
-		retval := (curIteration >= iterations) && (curResult < prevResult || equalIterations > 2)
+		retval := (curIteration >= iterations) && (curResult < state.PrevResult || state.EqualIterations > 2)
 		// retval := curIteration >= iterations
 		log.Println("Result (F1): ", curResult, "Exact:", total.Exact, "TruePos:", total.TP, "in", total.Population, "POS F1:", curPosResult)
 		if retval {
@@ -631,7 +851,7 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 		} else {
 			log.Println("Continuing")
 		}
-		prevResult = curResult
+		state.PrevResult = curResult
 		log.Println("Writing interm results to", fmt.Sprintf("interm.i%v.b%v.%v", curIteration, beamSize, outMap))
 		mapping.WriteFile(fmt.Sprintf("interm.i%v.b%v.%v", curIteration, beamSize, outMap), parsed)
 		if testInstances != nil {
@@ -675,11 +895,8 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 }
 
 func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface{}, testInstances []interface{}, morphInstances []interface{}, goldMorphInstances []interface{}, testMorphInstances []interface{}, parser Parser, goldDecoder perceptron.InstanceDecoder, beamSize int) perceptron.StopCondition {
-	var (
-		equalIterations     int
-		prevResult          float64
-		continuousDecreases int
-	)
+	// the evaluation state is saved in training checkpoints
+	state := TrainEval
 	return func(curIteration, iterations, generations int, model perceptron.Model) bool {
 		// first write current model
 		serialize(model, curIteration, generations)
@@ -719,10 +936,10 @@ func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface
 		}
 		curResult = total.Precision()
 		// Break out of edge case where result remains the same
-		if curResult == prevResult {
-			equalIterations += 1
+		if curResult == state.PrevResult {
+			state.EqualIterations += 1
 		}
-		retval := (Iterations < curIteration) && ((continuousDecreases > 1 && curResult < prevResult) || equalIterations > 3)
+		retval := (Iterations < curIteration) && ((state.ContinuousDecreases > 1 && curResult < state.PrevResult) || state.EqualIterations > 3)
 		// retval := curIteration >= iterations
 		log.Println("Result (UAS, LAS, UEM #, UEM %): ", utotal.Precision(), total.Precision(), utotal.Exact, float64(utotal.Exact)/float64(total.Population), "TruePos:", total.TP, "in", total.Population)
 		if retval {
@@ -730,12 +947,12 @@ func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface
 		} else {
 			log.Println("Continuing")
 		}
-		if curResult < prevResult {
-			continuousDecreases += 1
+		if curResult < state.PrevResult {
+			state.ContinuousDecreases += 1
 		} else {
-			continuousDecreases = 0
+			state.ContinuousDecreases = 0
 		}
-		prevResult = curResult
+		state.PrevResult = curResult
 		if useConllU {
 			graphs := conllu.Graph2ConllUCorpus(parsed, EMHost, EMSuffix)
 			morphGraphs := conllu.MergeGraphAndMorphCorpus(graphs, morphInstances)
@@ -762,13 +979,9 @@ func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface
 }
 
 func MakeJointEvalStopCondition(instances []interface{}, goldInstances []interface{}, testInstances []interface{}, testGoldInstances []interface{}, parser Parser, goldDecoder perceptron.InstanceDecoder, beamSize int) perceptron.StopCondition {
-	var (
-		equalIterations             int
-		prevResult, bestResult      float64
-		continuousDecreases         int
-		bestIteration               int
-		bestModelFile, curModelFile string
-	)
+	// the evaluation state is saved in training checkpoints
+	state := TrainEval
+	var curModelFile string
 	return func(curIteration, iterations, generations int, model perceptron.Model) bool {
 		// log.Println("Eval starting for iteration", curIteration)
 		var total = &eval.Total{
@@ -807,39 +1020,39 @@ func MakeJointEvalStopCondition(instances []interface{}, goldInstances []interfa
 		curResult = total.F1()
 		curPosResult = posonlytotal.F1()
 		// Break out of edge case where result remains the same
-		if curResult == prevResult {
-			equalIterations += 1
+		if curResult == state.PrevResult {
+			state.EqualIterations += 1
 		}
-		if curResult < prevResult {
-			continuousDecreases += 1
+		if curResult < state.PrevResult {
+			state.ContinuousDecreases += 1
 		} else {
-			continuousDecreases = 0
+			state.ContinuousDecreases = 0
 		}
-		if bestResult < curResult {
-			bestResult = curResult
-			bestIteration = curIteration
-			bestModelFile = curModelFile
+		if state.BestResult < curResult {
+			state.BestResult = curResult
+			state.BestIteration = curIteration
+			state.BestModelFile = curModelFile
 		}
-		retval := (Iterations < curIteration) && ((continuousDecreases > 1 && curResult < prevResult) || equalIterations > 3)
-		log.Println("It", Iterations, "CurIt", curIteration, "Continuous", continuousDecreases, "CurResult", curResult, "PrevResult", prevResult, "Comp", curResult < prevResult, "Retval", retval)
+		retval := (Iterations < curIteration) && ((state.ContinuousDecreases > 1 && curResult < state.PrevResult) || state.EqualIterations > 3)
+		log.Println("It", Iterations, "CurIt", curIteration, "Continuous", state.ContinuousDecreases, "CurResult", curResult, "PrevResult", state.PrevResult, "Comp", curResult < state.PrevResult, "Retval", retval)
 		// retval := curIteration >= iterations
 		log.Println("Result (F1): ", curResult, "Exact:", total.Exact, "TruePos:", total.TP, "in", total.Population, "POS F1:", curPosResult)
 		if retval {
 			log.Println("Stopping")
-			log.Println("Best iteration was", bestIteration)
-			log.Println("Best model file", bestModelFile)
+			log.Println("Best iteration was", state.BestIteration)
+			log.Println("Best model file", state.BestModelFile)
 
 			file, err := os.Create("bestmodelname")
 			defer file.Close()
 			if err != nil {
 				log.Println("Failed to write name of best model:", err)
 			} else {
-				file.Write([]byte(bestModelFile))
+				file.Write([]byte(state.BestModelFile))
 			}
 		} else {
 			log.Println("Continuing")
 		}
-		prevResult = curResult
+		state.PrevResult = curResult
 		graphs := conll.MorphGraph2ConllCorpus(parsedGraphs)
 		log.Println("Writing interm results to conll:", fmt.Sprintf("interm.i%v.b%v.%v", curIteration, beamSize, outConll))
 		conll.WriteFile(fmt.Sprintf("interm.i%v.b%v.%v", curIteration, beamSize, outConll), graphs)
@@ -886,10 +1099,7 @@ func MakeJointEvalStopCondition(instances []interface{}, goldInstances []interfa
 }
 
 func serialize(perceptronModel perceptron.Model, iteration, generations int) string {
-	serialization := &Serialization{
-		perceptronModel.(*model.AvgMatrixSparse).Serialize(generations),
-		EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
-	}
+	serialization := NewSerialization(perceptronModel.(*model.AvgMatrixSparse).Serialize(generations))
 	modelFile := fmt.Sprintf("model.temp.i%d", iteration)
 	WriteModel(modelFile, serialization)
 	return modelFile
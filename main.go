@@ -9,16 +9,18 @@ import (
 	"fmt"
 	"os"
 	"yap/app"
+	"yap/pipeline"
 	"yap/webapi"
 )
 
 var cmd = &commander.Command{
-	UsageLine: os.Args[0] + " app|api",
+	UsageLine: os.Args[0] + " app|api|pipeline",
 	Short:     "invoke yap as a standalone app or as an api server",
 }
 
 func init() {
 	cmd.Subcommands = append(app.AllCommands().Subcommands, webapi.AllCommands().Subcommands...)
+	cmd.Subcommands = append(cmd.Subcommands, pipeline.AllCommands().Subcommands...)
 	//cmd.Subcommands = app.AllCommands().Subcommands
 }
 
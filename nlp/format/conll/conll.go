@@ -93,6 +93,9 @@ type Row struct {
 	// PHead int
 	// PDepRel string
 
+	// character offsets in the input text, in the optional
+	// offsets field (start:end) following PDEPREL
+	CharStart, CharEnd int
 }
 
 func (r Row) String() string {
@@ -110,6 +113,11 @@ func (r Row) String() string {
 	return strings.Join(fields, "\t")
 }
 
+// OffsetsString returns the row with its offsets field
+func (r Row) OffsetsString() string {
+	return r.String() + "\t" + nlp.FormatOffsets(r.CharStart, r.CharEnd)
+}
+
 // A Sentence is a map of Rows using their ids
 type Sentence map[int]Row
 
@@ -223,6 +231,14 @@ func ParseRow(record []string) (Row, error) {
 	}
 	row.Feats = features
 	row.FeatStr = ParseString(record[5])
+
+	if len(record) > 10 {
+		charStart, charEnd, err := nlp.ParseOffsets(record[10])
+		if err != nil {
+			return row, errors.New(fmt.Sprintf("Error parsing OFFSETS field (%s): %s", record[10], err.Error()))
+		}
+		row.CharStart, row.CharEnd = charStart, charEnd
+	}
 	return row, nil
 }
 
@@ -357,6 +373,18 @@ func Write(writer io.Writer, sents []interface{}) {
 	}
 }
 
+// WriteOffsets writes the sentences with the offsets field of each row
+func WriteOffsets(writer io.Writer, sents []interface{}) {
+	for _, genericsent := range sents {
+		sent := genericsent.(Sentence)
+		for i := 1; i <= len(sent); i++ {
+			row := sent[i]
+			writer.Write(append([]byte(row.OffsetsString()), '\n'))
+		}
+		writer.Write([]byte{'\n'})
+	}
+}
+
 func WriteStream(writer io.Writer, sents chan interface{}) {
 	for genericsent := range sents {
 		sent := genericsent.(Sentence)
@@ -378,6 +406,16 @@ func WriteFile(filename string, sents []interface{}) error {
 	return nil
 }
 
+func WriteOffsetsFile(filename string, sents []interface{}) error {
+	file, err := os.Create(filename)
+	defer file.Close()
+	if err != nil {
+		return err
+	}
+	WriteOffsets(file, sents)
+	return nil
+}
+
 func WriteStreamToFile(filename string, sents chan interface{}) error {
 	file, err := os.Create(filename)
 	defer file.Close()
@@ -602,6 +640,9 @@ func MorphGraph2Conll(graph nlp.MorphDependencyGraph) Sentence {
 			FeatStr: node.FeatureStr,
 			Head:    headID + 1,
 			DepRel:  depRel,
+
+			CharStart: node.CharStart,
+			CharEnd:   node.CharEnd,
 		}
 		sent[row.ID] = row
 	}
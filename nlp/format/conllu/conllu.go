@@ -717,6 +717,8 @@ func ConllU2MorphGraph(sent *Sentence, eWord, ePOS, eWPOS, eRel, eMFeat, eMHost,
 			eFeat,
 			node.MHost,
 			node.MSuffix,
+			0,
+			0,
 		})
 
 		curLatNode++
@@ -114,6 +114,9 @@ type Edge struct {
 	Token    int
 	Id       int
 	TokenStr string
+	// character offsets in the input text, in the optional
+	// offsets field (start:end) following TOKEN
+	CharStart, CharEnd int
 }
 
 type EdgeSlice []Edge
@@ -158,6 +161,11 @@ func (e Edge) String() string {
 	return strings.Join(fields, "\t")
 }
 
+// OffsetsString returns the edge with its offsets field
+func (e Edge) OffsetsString() string {
+	return e.String() + "\t" + nlp.FormatOffsets(e.CharStart, e.CharEnd)
+}
+
 func (e Edge) UDString() string {
 	var xpostag string
 	if OVERRIDE_XPOS_WITH_UPOS {
@@ -191,6 +199,18 @@ func (e *Edge) Copy() *Edge {
 
 type Lattice map[int][]Edge
 
+// ParseError is returned by Read for a malformed lattice line
+type ParseError struct {
+	Record    int // 0-based line number in the input
+	Statement int // 0-based lattice (sentence) number
+	Line      string
+	Err       error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Error processing record %d at statement %d: %s", e.Record, e.Statement, e.Err.Error())
+}
+
 func (l Lattice) MaxKey() (retval int) {
 	for k, _ := range l {
 		if k > retval {
@@ -379,6 +399,9 @@ func ParseUDEdge(record []string) (*Edge, error) {
 
 func ParseEdge(record []string) (*Edge, error) {
 	row := &Edge{}
+	if len(record) < 8 {
+		return row, errors.New(fmt.Sprintf("Wrong number of fields, expected 8 but found %d", len(record)))
+	}
 	start, err := ParseInt(record[0])
 	if err != nil {
 		return row, errors.New(fmt.Sprintf("Error parsing START field (%s): %s", record[0], err.Error()))
@@ -432,6 +455,14 @@ func ParseEdge(record []string) (*Edge, error) {
 	}
 	row.Feats = features
 	row.FeatStr = ParseString(record[6])
+
+	if len(record) > 8 {
+		charStart, charEnd, err := nlp.ParseOffsets(record[8])
+		if err != nil {
+			return row, errors.New(fmt.Sprintf("Error parsing OFFSETS field (%s): %s", record[8], err.Error()))
+		}
+		row.CharStart, row.CharEnd = charStart, charEnd
+	}
 	return row, nil
 }
 
@@ -543,13 +574,13 @@ func Read(r io.Reader, limit int) ([]Lattice, error) {
 		record := strings.Split(buf.String(), "\t")
 
 		edge, err := ParseEdge(record)
+		if err != nil {
+			return nil, &ParseError{i, len(sentences), buf.String(), err}
+		}
 		if edge.Start == edge.End {
 			log.Println("At sent:", len(sentences), "Warning: found circular edge", edge, ", optimistically incrementing end")
 			edge.End += 1
 		}
-		if err != nil {
-			return nil, errors.New(fmt.Sprintf("Error processing record %d at statement %d: %s", i, len(sentences), err.Error()))
-		}
 		edge.Id = currentEdge
 		edges, exists := currentLatt[edge.Start]
 		if exists {
@@ -994,6 +1025,15 @@ func WriteStream(writer io.Writer, lattices chan Lattice) error {
 }
 
 func Write(writer io.Writer, lattices []Lattice) error {
+	return write(writer, lattices, false)
+}
+
+// WriteOffsets writes the lattices with the offsets field of each edge
+func WriteOffsets(writer io.Writer, lattices []Lattice) error {
+	return write(writer, lattices, true)
+}
+
+func write(writer io.Writer, lattices []Lattice, offsets bool) error {
 	for _, lattice := range lattices {
 		var max int
 		for k, _ := range lattice {
@@ -1004,7 +1044,11 @@ func Write(writer io.Writer, lattices []Lattice) error {
 		for i := 0; i <= max; i++ {
 			if row, exists := lattice[i]; exists {
 				for _, edge := range row {
-					writer.Write(append([]byte(edge.String()), '\n'))
+					if offsets {
+						writer.Write(append([]byte(edge.OffsetsString()), '\n'))
+					} else {
+						writer.Write(append([]byte(edge.String()), '\n'))
+					}
 				}
 			}
 		}
@@ -1080,6 +1124,16 @@ func WriteFile(filename string, sents []Lattice) error {
 	return nil
 }
 
+func WriteOffsetsFile(filename string, sents []Lattice) error {
+	file, err := os.Create(filename)
+	defer file.Close()
+	if err != nil {
+		return err
+	}
+	WriteOffsets(file, sents)
+	return nil
+}
+
 func WriteUDFile(filename string, sents []Lattice, comments [][]string, oov interface{}) error {
 	file, err := os.Create(filename)
 	defer file.Close()
@@ -1250,6 +1304,8 @@ func Lattice2Sentence(lattice Lattice, eWord, ePOS, eWPOS, eMorphFeat, eMHost, e
 					edge.Token,
 					edge.FeatStr,
 				},
+				CharStart: edge.CharStart,
+				CharEnd:   edge.CharEnd,
 			}
 			switch WORD_TYPE {
 			case "form":
@@ -1285,6 +1341,14 @@ func Lattice2Sentence(lattice Lattice, eWord, ePOS, eWPOS, eMorphFeat, eMHost, e
 		lat.SortMorphemes()
 		lat.GenSpellouts()
 		lat.Token = nlp.Token(tokens[i])
+		for j, m := range lat.Morphemes {
+			if j == 0 || m.CharStart < lat.CharStart {
+				lat.CharStart = m.CharStart
+			}
+			if m.CharEnd > lat.CharEnd {
+				lat.CharEnd = m.CharEnd
+			}
+		}
 		sent[i] = lat
 	}
 	return sent
@@ -1345,6 +1409,8 @@ func Sentence2Lattice(lattice nlp.LatticeSentence, xliter8or xliter8.Interface)
 				m.TokenID,
 				m.ID(),
 				string(sentlat.Token),
+				m.CharStart,
+				m.CharEnd,
 			}
 			if len(m.FeatureStr) == 0 {
 				e.FeatStr = "_"
@@ -1,6 +1,7 @@
 package lattice
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 )
@@ -41,3 +42,51 @@ func TestParseEdgeWithRepeatingParams(t *testing.T) {
 		t.Error("Failure concatenating multiple features: should be F,M got " + parsed.Feats["suf_gen"])
 	}
 }
+
+func TestParseEdgeWrongNumberOfFields(t *testing.T) {
+	row := strings.Split("0	1	EFRWT	_	CDT",
+		string(FIELD_SEPARATOR))
+
+	_, err := ParseEdge(row)
+	if err == nil {
+		t.Error("Expected error for edge with missing fields")
+	}
+}
+
+func TestReadReportsBadRecord(t *testing.T) {
+	input := "0	1	EFRWT	_	CDT	CDT	gen=F|num=P	1\n1	X	TAILND	_	NNP	NNP	_	2\n\n"
+	_, err := Read(strings.NewReader(input), 0)
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Expected *ParseError, got %v", err)
+	}
+	if parseErr.Record != 1 || parseErr.Statement != 0 {
+		t.Errorf("Expected error at record 1 statement 0, got record %d statement %d", parseErr.Record, parseErr.Statement)
+	}
+}
+
+func TestOffsetsRoundTrip(t *testing.T) {
+	input := "0	1	B	_	PREPOSITION	PREPOSITION	gen=M	1	4:5\n1	2	BIT	_	NN	NN	gen=M|num=S	1	5:8\n\n"
+	lats, err := Read(strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edge := lats[0][1][0]; edge.CharStart != 5 || edge.CharEnd != 8 {
+		t.Errorf("Expected offsets 5:8, got %d:%d", edge.CharStart, edge.CharEnd)
+	}
+	buf := new(bytes.Buffer)
+	WriteOffsets(buf, lats)
+	if buf.String() != input {
+		t.Errorf("Expected\n%s\ngot\n%s", input, buf.String())
+	}
+}
+
+func TestParseEdgeBadOffsets(t *testing.T) {
+	row := strings.Split("0	1	EFRWT	_	CDT	CDT	gen=F|num=P	1	5",
+		string(FIELD_SEPARATOR))
+
+	_, err := ParseEdge(row)
+	if err == nil {
+		t.Error("Expected error for malformed offsets")
+	}
+}
@@ -35,6 +35,19 @@ func UDWriteMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph int) {
 }
 
 func WriteMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph, curToken int) {
+	writeMorphFields(writer, morph, curMorph, curToken)
+	writer.Write([]byte{'\n'})
+}
+
+// WriteMorphOffsets writes the morpheme with its offsets field
+func WriteMorphOffsets(writer io.Writer, morph *nlp.EMorpheme, curMorph, curToken int) {
+	writeMorphFields(writer, morph, curMorph, curToken)
+	writer.Write([]byte{'\t'})
+	writer.Write([]byte(nlp.FormatOffsets(morph.CharStart, morph.CharEnd)))
+	writer.Write([]byte{'\n'})
+}
+
+func writeMorphFields(writer io.Writer, morph *nlp.EMorpheme, curMorph, curToken int) {
 	writer.Write([]byte(fmt.Sprintf("%d\t%d\t", curMorph, curMorph+1)))
 	writer.Write([]byte(morph.Form))
 	writer.Write([]byte{'\t'})
@@ -54,7 +67,7 @@ func WriteMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph, curToken int)
 		writer.Write([]byte(morph.FeatureStr))
 	}
 	writer.Write([]byte{'\t'})
-	writer.Write([]byte(fmt.Sprintf("%d\n", curToken+1)))
+	writer.Write([]byte(fmt.Sprintf("%d", curToken+1)))
 }
 
 func UDWrite(writer io.Writer, mappedSents []interface{}, conllul []conllul.ConlluLattice) {
@@ -88,6 +101,15 @@ func UDWrite(writer io.Writer, mappedSents []interface{}, conllul []conllul.Conl
 }
 
 func Write(writer io.Writer, mappedSents []interface{}) {
+	write(writer, mappedSents, false)
+}
+
+// WriteOffsets writes the mappings with the offsets field of each morpheme
+func WriteOffsets(writer io.Writer, mappedSents []interface{}) {
+	write(writer, mappedSents, true)
+}
+
+func write(writer io.Writer, mappedSents []interface{}, offsets bool) {
 	var curMorph int
 	for _, mappedSent := range mappedSents {
 		curMorph = 0
@@ -106,7 +128,11 @@ func Write(writer io.Writer, mappedSents []interface{}) {
 					// log.Println("\t", "Morph is nil, continuing")
 					continue
 				}
-				WriteMorph(writer, morph, curMorph, i)
+				if offsets {
+					WriteMorphOffsets(writer, morph, curMorph, i)
+				} else {
+					WriteMorph(writer, morph, curMorph, i)
+				}
 				// log.Println("\t", "At morph", j, morph.Form)
 				curMorph++
 			}
@@ -166,6 +192,16 @@ func WriteFile(filename string, mappedSents []interface{}) error {
 	return nil
 }
 
+func WriteOffsetsFile(filename string, mappedSents []interface{}) error {
+	file, err := os.Create(filename)
+	defer file.Close()
+	if err != nil {
+		return err
+	}
+	WriteOffsets(file, mappedSents)
+	return nil
+}
+
 func WriteStreamToFile(filename string, mappedSents chan interface{}) error {
 	file, err := os.Create(filename)
 	if err != nil {
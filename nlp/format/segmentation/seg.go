@@ -9,21 +9,26 @@ import (
 
 func Write(writer io.Writer, graphs []interface{}) {
 	for _, graph := range graphs {
-		for _, mapping := range graph.(nlp.MorphDependencyGraph).GetMappings() {
-			if mapping.Token == nlp.ROOT_TOKEN {
-				continue
-			}
-			writer.Write([]byte(mapping.Token))
-			writer.Write([]byte{'\t'})
-			morphForms := make([]string, len(mapping.Spellout))
-			for i, morph := range mapping.Spellout {
-				morphForms[i] = morph.Form
-			}
-			writer.Write([]byte(strings.Join(morphForms, ":")))
-			writer.Write([]byte{'\n'})
+		WriteMappings(writer, graph.(nlp.MorphDependencyGraph).GetMappings())
+	}
+}
+
+// WriteMappings writes the segmentation of a single sentence
+func WriteMappings(writer io.Writer, mappings nlp.Mappings) {
+	for _, mapping := range mappings {
+		if mapping.Token == nlp.ROOT_TOKEN {
+			continue
+		}
+		writer.Write([]byte(mapping.Token))
+		writer.Write([]byte{'\t'})
+		morphForms := make([]string, len(mapping.Spellout))
+		for i, morph := range mapping.Spellout {
+			morphForms[i] = morph.Form
 		}
+		writer.Write([]byte(strings.Join(morphForms, ":")))
 		writer.Write([]byte{'\n'})
 	}
+	writer.Write([]byte{'\n'})
 }
 
 func WriteFile(filename string, graphs []interface{}) error {
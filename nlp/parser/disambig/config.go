@@ -16,8 +16,6 @@ import (
 )
 
 var (
-	UsePOP           bool
-	SwitchFormLemma  bool
 	POP_ONLY_VAR_LEN bool = true
 	AFFIX_SIZE       int  = 10
 )
@@ -40,6 +38,12 @@ type MDConfig struct {
 	Transitions *util.EnumSet
 	ParamFunc   nlp.MDParam
 	popped      int
+
+	// UsePOP and SwitchFormLemma are settings of the model, carried by
+	// each configuration so models of different settings can be loaded
+	// side by side
+	UsePOP          bool
+	SwitchFormLemma bool
 }
 
 var _ Configuration = &MDConfig{}
@@ -76,7 +80,7 @@ func (c *MDConfig) Init(abstractLattice interface{}) {
 
 func (c *MDConfig) Terminal() bool {
 	// return c.Last == Transition(0) && c.Alignment() == 1
-	if UsePOP {
+	if c.UsePOP {
 		return c.LatticeQueue.Size() == 0 && c.popped == len(c.Mappings)
 	} else {
 		return c.LatticeQueue.Size() == 0
@@ -130,6 +134,8 @@ func (c *MDConfig) CopyTo(target Configuration) {
 	newConf.POP = c.POP
 	newConf.Transitions = c.Transitions
 	newConf.ParamFunc = c.ParamFunc
+	newConf.UsePOP = c.UsePOP
+	newConf.SwitchFormLemma = c.SwitchFormLemma
 }
 
 func (c *MDConfig) GetSequence() ConfigurationSequence {
@@ -163,7 +169,7 @@ func (c *MDConfig) State() byte {
 		return 'L'
 	}
 	qTop, qExists := c.LatticeQueue.Peek()
-	if UsePOP && ((!qExists && len(c.Mappings) != c.popped) ||
+	if c.UsePOP && ((!qExists && len(c.Mappings) != c.popped) ||
 		(qExists && qTop != c.popped)) {
 		// can pop
 		return 'P'
@@ -304,7 +310,7 @@ func (c *MDConfig) AddSpellout(spellout string, paramFunc nlp.MDParam) bool {
 	// log.Println("\tAdding spellout")
 	if curLatticeId, exists := c.LatticeQueue.Pop(); exists {
 		curLattice := c.Lattices[curLatticeId]
-		if UsePOP && POP_ONLY_VAR_LEN {
+		if c.UsePOP && POP_ONLY_VAR_LEN {
 			poppedLat := c.Lattices[curLatticeId]
 			// only need to pop variable length
 			if !poppedLat.IsVarLen() {
@@ -381,7 +387,7 @@ func (c *MDConfig) AddMapping(m *nlp.EMorpheme) {
 	if currentLat := c.Lattices[currentLatIdx]; c.CurrentLatNode == currentLat.Top() {
 		// log.Println("\tPopping lattice queue")
 		poppedIndex, _ := c.LatticeQueue.Pop()
-		if UsePOP && POP_ONLY_VAR_LEN {
+		if c.UsePOP && POP_ONLY_VAR_LEN {
 			poppedLat := c.Lattices[poppedIndex]
 			// only need to pop variable length
 			if !poppedLat.IsVarLen() {
@@ -469,7 +475,7 @@ func (c *MDConfig) Attribute(source byte, nodeID int, attribute []byte, transiti
 				att = morpheme.EFCPOS
 				return
 			} else {
-				if SwitchFormLemma {
+				if c.SwitchFormLemma {
 					att = morpheme.Lemma
 				} else {
 					att = morpheme.EForm
@@ -12,8 +12,6 @@ import (
 
 const TSAllOut bool = false
 
-var LEMMAS bool = true
-
 type MDTrans struct {
 	ParamFunc MDParam
 	POP       Transition
@@ -23,6 +21,9 @@ type MDTrans struct {
 
 	Log    bool
 	UsePOP bool
+	// IgnoreLemmas adds the morpheme of ambiguous lemmas instead of
+	// lexicalizing them
+	IgnoreLemmas bool
 }
 
 var _ TransitionSystem = &MDTrans{}
@@ -102,7 +103,7 @@ func (t *MDTrans) Transition(from Configuration, transition Transition) Configur
 		}
 	}
 	if foundMorph != nil {
-		if LEMMAS && ambLemmas != nil && len(ambLemmas) > 1 {
+		if !t.IgnoreLemmas && ambLemmas != nil && len(ambLemmas) > 1 {
 			if TSAllOut || t.Log {
 				log.Println("Add lemma ambiguity", ambLemmas)
 			}
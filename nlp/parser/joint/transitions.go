@@ -43,7 +43,6 @@ func (t *JointTrans) Transition(from Configuration, transition Transition) Confi
 	// transition systems
 	c := from.Copy().(*JointConfig)
 	if transition.Type() == 'M' || transition.Type() == 'P' || transition.Type() == 'L' {
-		t.MDTrans.(*disambig.MDTrans).Log = t.Log
 		// log.Println("Applying transition", t.Transitions.ValueOf(transition.Value()), "to\n", c.MDConfig)
 		c.MDConfig = *t.MDTrans.Transition(&c.MDConfig, transition).(*disambig.MDConfig)
 		// log.Println("MD Config is now:\n", c.MDConfig)
@@ -0,0 +1,312 @@
+// Package tokenizer splits raw (Modern Hebrew) text into sentences and tokens
+// Tokens are separated by white space and punctuation; the following are
+// kept whole:
+//   - Hebrew words, including geresh/gershayim in acronyms and
+//     abbreviations (צה"ל, מנכ״ל, ג'ירפה, פרופ')
+//   - numbers, including decimals, thousands separators, times and dates (3.14, 1,000, 12:30, 1/2/2020)
+//   - URLs and email addresses
+//   - Latin-script words, including internal apostrophes, hyphens and periods (don't, COVID-19, e.g.)
+//
+// Sentences end after terminal punctuation (. ! ? …), including any
+// closing quotes and brackets following it, or at an empty line
+package tokenizer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode"
+
+	nlp "yap/nlp/types"
+)
+
+const (
+	GERESH    = '\u05F3'
+	GERSHAYIM = '\u05F4'
+)
+
+var (
+	urlRegexp    = regexp.MustCompile(`^(?i)(https?://|ftp://|www\.)\S+`)
+	emailRegexp  = regexp.MustCompile(`^\w[\w.+-]*@[\w-]+(\.[\w-]+)+`)
+	numberRegexp = regexp.MustCompile(`^\d+([.,:/]\d+)*`)
+
+	// trailing punctuation not considered part of a URL
+	urlTrailing = ".,;:!?)]}>\"'»”’"
+
+	// Latin-script abbreviations ending with a period
+	Abbreviations = map[string]bool{
+		"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true,
+		"st": true, "jr": true, "sr": true, "vs": true, "etc": true,
+		"inc": true, "ltd": true, "co": true, "no": true, "vol": true,
+	}
+)
+
+// Token is a token of the input text, with its offsets in the text
+// in characters (runes); End is exclusive
+type Token struct {
+	Text       string
+	Start, End int
+}
+
+type Sentence []Token
+
+func (s Sentence) Tokens() []string {
+	tokens := make([]string, len(s))
+	for i, token := range s {
+		tokens[i] = token.Text
+	}
+	return tokens
+}
+
+func (s Sentence) BasicSentence() nlp.BasicSentence {
+	sent := make(nlp.BasicSentence, len(s))
+	for i, token := range s {
+		sent[i] = nlp.Token(token.Text)
+	}
+	return sent
+}
+
+func IsHebrewLetter(r rune) bool {
+	return (r >= '\u05D0' && r <= '\u05EA') || (r >= '\u05F0' && r <= '\u05F2')
+}
+
+// IsHebrewMark is true for niqqud and cantillation marks (which
+// are part of a word), but not Hebrew punctuation such as maqaf
+func IsHebrewMark(r rune) bool {
+	return r >= '\u0591' && r <= '\u05C7' && unicode.Is(unicode.Mn, r)
+}
+
+func isLatinLetter(r rune) bool {
+	return unicode.IsLetter(r) && !IsHebrewLetter(r)
+}
+
+func isGeresh(r rune) bool {
+	return r == GERESH || r == '\''
+}
+
+func isGershayim(r rune) bool {
+	return r == GERSHAYIM || r == '"'
+}
+
+func isTerminal(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+func isCloser(r rune) bool {
+	return strings.ContainsRune("\"')]}»”’", r) || r == GERSHAYIM
+}
+
+type tokenizer struct {
+	text  []rune
+	i     int
+	sents []Sentence
+	cur   Sentence
+	// an unmatched opening single quote, after which a word's trailing
+	// apostrophe is a closing quote rather than a geresh
+	openQuote bool
+}
+
+// Tokenize splits text into sentences of tokens
+func Tokenize(text string) []Sentence {
+	t := &tokenizer{text: []rune(text)}
+	t.tokenize()
+	return t.sents
+}
+
+// TokenizeReader tokenizes all text read from the reader
+func TokenizeReader(reader io.Reader) ([]Sentence, error) {
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return Tokenize(string(text)), nil
+}
+
+// TokenizeSentence splits text known to be a single sentence into tokens
+func TokenizeSentence(text string) Sentence {
+	var sent Sentence
+	for _, s := range Tokenize(text) {
+		sent = append(sent, s...)
+	}
+	return sent
+}
+
+// Pretokenized splits already tokenized text, with tokens separated by
+// white space and sentences by two spaces or new lines (e.g. an empty
+// line, as in the raw input format)
+func Pretokenized(text string) []Sentence {
+	var (
+		sents      []Sentence
+		cur        Sentence
+		start      = -1
+		separators int
+	)
+	runes := []rune(text)
+	for i, r := range runes {
+		if !unicode.IsSpace(r) {
+			if start < 0 {
+				if separators > 1 && len(cur) > 0 {
+					sents = append(sents, cur)
+					cur = nil
+				}
+				start = i
+				separators = 0
+			}
+			continue
+		}
+		if start >= 0 {
+			cur = append(cur, Token{Text: string(runes[start:i]), Start: start, End: i})
+			start = -1
+		}
+		if r == ' ' || r == '\n' {
+			separators++
+		}
+	}
+	if start >= 0 {
+		cur = append(cur, Token{Text: string(runes[start:]), Start: start, End: len(runes)})
+	}
+	if len(cur) > 0 {
+		sents = append(sents, cur)
+	}
+	return sents
+}
+
+// Raw returns the sentences in the raw input format (see format/raw):
+// a token per line, each sentence followed by an empty line
+func Raw(sents []Sentence) string {
+	buf := new(bytes.Buffer)
+	for _, sent := range sents {
+		for _, token := range sent {
+			buf.WriteString(token.Text)
+			buf.WriteByte('\n')
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+func (t *tokenizer) endSentence() {
+	if len(t.cur) > 0 {
+		t.sents = append(t.sents, t.cur)
+	}
+	t.cur = nil
+	t.openQuote = false
+}
+
+func (t *tokenizer) tokenize() {
+	var endPending bool // terminal punctuation seen, awaiting closers
+	for t.i < len(t.text) {
+		// skip white space, an empty line ends the sentence
+		var newlines int
+		start := t.i
+		for t.i < len(t.text) && unicode.IsSpace(t.text[t.i]) {
+			if t.text[t.i] == '\n' {
+				newlines++
+			}
+			t.i++
+		}
+		if t.i == len(t.text) {
+			break
+		}
+		spaced := t.i > start || t.i == 0
+		if newlines > 1 || (endPending && (spaced || !isCloser(t.text[t.i]))) {
+			t.endSentence()
+			endPending = false
+		}
+		start = t.i
+		end := t.scan(spaced)
+		token := Token{Text: string(t.text[start:end]), Start: start, End: end}
+		t.cur = append(t.cur, token)
+		t.i = end
+		if isTerminal(t.text[start]) {
+			endPending = true
+		}
+	}
+	t.endSentence()
+}
+
+// scan returns the end of the token starting at t.i
+// spaced is true if the token follows white space (or starts the text)
+func (t *tokenizer) scan(spaced bool) int {
+	rest := t.text[t.i:]
+	r := rest[0]
+	chunkEnd := 0
+	for chunkEnd < len(rest) && !unicode.IsSpace(rest[chunkEnd]) {
+		chunkEnd++
+	}
+	chunk := string(rest[:chunkEnd])
+	switch {
+	case urlRegexp.MatchString(chunk):
+		url := strings.TrimRight(chunk, urlTrailing)
+		return t.i + len([]rune(url))
+	case emailRegexp.MatchString(chunk):
+		return t.i + len([]rune(emailRegexp.FindString(chunk)))
+	case r >= '0' && r <= '9':
+		return t.i + len([]rune(numberRegexp.FindString(chunk)))
+	case IsHebrewLetter(r):
+		return t.i + t.scanHebrew(rest[:chunkEnd])
+	case isLatinLetter(r):
+		return t.i + t.scanLatin(rest[:chunkEnd])
+	case isTerminal(r):
+		j := 1
+		for j < chunkEnd && isTerminal(rest[j]) {
+			j++
+		}
+		return t.i + j
+	case r == '\'':
+		// opening quote if it follows white space, closing otherwise
+		t.openQuote = spaced
+	}
+	return t.i + 1
+}
+
+func (t *tokenizer) scanHebrew(chunk []rune) int {
+	j := 0
+	for j < len(chunk) {
+		r := chunk[j]
+		switch {
+		case IsHebrewLetter(r) || IsHebrewMark(r):
+			j++
+		case isGeresh(r) || isGershayim(r):
+			if j+1 < len(chunk) && IsHebrewLetter(chunk[j+1]) {
+				// acronym (צה"ל) or foreign sound (ג'ירפה)
+				j++
+			} else if r == GERESH || (r == '\'' && !t.openQuote) {
+				// abbreviation (פרופ') or number (ג')
+				return j + 1
+			} else {
+				return j
+			}
+		default:
+			return j
+		}
+	}
+	return j
+}
+
+func (t *tokenizer) scanLatin(chunk []rune) int {
+	isWordRune := func(r rune) bool {
+		return isLatinLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+	}
+	j := 0
+	var internalPeriod bool
+	for j < len(chunk) {
+		r := chunk[j]
+		switch {
+		case isWordRune(r):
+			j++
+		case (r == '\'' || r == '’' || r == '-' || r == '.' || r == '&') && j+1 < len(chunk) && isWordRune(chunk[j+1]):
+			internalPeriod = internalPeriod || r == '.'
+			j++
+		default:
+			if r == '.' && (internalPeriod || j == 1 || Abbreviations[strings.ToLower(string(chunk[:j]))]) {
+				// abbreviation (e.g., Dr.) or initial (J.)
+				return j + 1
+			}
+			return j
+		}
+	}
+	return j
+}
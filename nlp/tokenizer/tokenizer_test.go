@@ -0,0 +1,70 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func tokenized(text string) string {
+	sents := Tokenize(text)
+	joined := make([]string, len(sents))
+	for i, sent := range sents {
+		joined[i] = strings.Join(sent.Tokens(), " ")
+	}
+	return strings.Join(joined, " | ")
+}
+
+func TestTokenize(t *testing.T) {
+	cases := []struct {
+		text, expected string
+	}{
+		{"גנן גידל דגן בגן.", "גנן גידל דגן בגן ."},
+		{"שלום, עולם! מה נשמע?", "שלום , עולם ! | מה נשמע ?"},
+		{"הוא אמר: \"אני בא.\" ואז הלך.", "הוא אמר : \" אני בא . \" | ואז הלך ."},
+		{"צה\"ל ומנכ״ל החברה", "צה\"ל ומנכ״ל החברה"},
+		{"ג'ירפה ופרופ' כהן", "ג'ירפה ופרופ' כהן"},
+		{"הוא אמר 'שלום' ויצא", "הוא אמר ' שלום ' ויצא"},
+		{"עלה 3.5% ב-12:30 ב1948", "עלה 3.5 % ב - 12:30 ב 1948"},
+		{"ראו https://example.com/a?b=c. תודה", "ראו https://example.com/a?b=c . | תודה"},
+		{"כתבו ל-info@example.co.il או ל COVID-19", "כתבו ל - info@example.co.il או ל COVID-19"},
+		{"Dr. Smith doesn't know... e.g. this", "Dr. Smith doesn't know ... | e.g. this"},
+		{"שורה ראשונה\nהמשך\n\nפסקה שנייה", "שורה ראשונה המשך | פסקה שנייה"},
+		{"  ", ""},
+	}
+	for _, c := range cases {
+		if result := tokenized(c.text); result != c.expected {
+			t.Errorf("Tokenize(%q):\n\tgot      %q\n\texpected %q", c.text, result, c.expected)
+		}
+	}
+}
+
+func TestTokenOffsets(t *testing.T) {
+	text := "שלום, עולם!"
+	runes := []rune(text)
+	for _, sent := range Tokenize(text) {
+		for _, token := range sent {
+			if string(runes[token.Start:token.End]) != token.Text {
+				t.Errorf("Token %q has offsets [%d,%d) of %q", token.Text, token.Start, token.End, string(runes[token.Start:token.End]))
+			}
+		}
+	}
+}
+
+func TestRaw(t *testing.T) {
+	if raw := Raw(Tokenize("א ב. ג")); raw != "א\nב\n.\n\nג\n\n" {
+		t.Errorf("Got raw %q", raw)
+	}
+}
+
+func TestPretokenized(t *testing.T) {
+	sents := Pretokenized("גנן גידל  דגן\nבגן .\n\nשלום")
+	if len(sents) != 3 {
+		t.Fatalf("Expected 3 sentences, got %v", len(sents))
+	}
+	if tokens := strings.Join(sents[1].Tokens(), " "); tokens != "דגן בגן ." {
+		t.Errorf("Got second sentence %q", tokens)
+	}
+	if token := sents[2][0]; token.Start != 21 || token.End != 25 {
+		t.Errorf("Got offsets [%d,%d) for %q", token.Start, token.End, token.Text)
+	}
+}
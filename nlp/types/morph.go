@@ -28,6 +28,8 @@ type EMorpheme struct {
 	EFCPOS, EPOS     int
 	EFeatures        int
 	EMHost, EMSuffix int
+	// character offsets in the input text (see Lattice.SetOffsets)
+	CharStart, CharEnd int
 }
 
 var _ DepNode = &Morpheme{}
@@ -383,6 +385,8 @@ type Lattice struct {
 	Spellouts       Spellouts
 	Next            map[int][]int
 	BottomId, TopId int
+	// character offsets of the token in the input text
+	CharStart, CharEnd int
 }
 
 func (l *Lattice) Signature() string {
@@ -822,6 +826,8 @@ func NewRootLattice() Lattice {
 		make(map[int][]int),
 		0,
 		0,
+		0,
+		0,
 	}
 	return *lat
 }
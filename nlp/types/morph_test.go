@@ -6,15 +6,16 @@ import (
 )
 
 var testLat *Lattice = &Lattice{
-	"KFHM",
-	[]*Morpheme{
-		&Morpheme{G.BasicDirectedEdge{0, 7, 8}, "K", "ADVERB", "ADVERB", nil, 6},
-		&Morpheme{G.BasicDirectedEdge{1, 7, 9}, "KF", "TEMP", "TEMP", nil, 6},
-		&Morpheme{G.BasicDirectedEdge{2, 8, 10}, "FHM", "NNP", "NNP", nil, 6},
-		&Morpheme{G.BasicDirectedEdge{3, 9, 10}, "HM", "PRP", "PRP", map[string]string{"gen": "M", "num": "P", "per": "3"}, 6},
-		&Morpheme{G.BasicDirectedEdge{4, 9, 10}, "HM", "COP", "COP", map[string]string{"gen": "M", "num": "P", "per": "3", "polar": "pos"}, 6},
+	Token: "KFHM",
+	Morphemes: Morphemes{
+		{Morpheme: Morpheme{BasicDirectedEdge: G.BasicDirectedEdge{0, 7, 8}, Form: "K", CPOS: "ADVERB", POS: "ADVERB", TokenID: 6}},
+		{Morpheme: Morpheme{BasicDirectedEdge: G.BasicDirectedEdge{1, 7, 9}, Form: "KF", CPOS: "TEMP", POS: "TEMP", TokenID: 6}},
+		{Morpheme: Morpheme{BasicDirectedEdge: G.BasicDirectedEdge{2, 8, 10}, Form: "FHM", CPOS: "NNP", POS: "NNP", TokenID: 6}},
+		{Morpheme: Morpheme{BasicDirectedEdge: G.BasicDirectedEdge{3, 9, 10}, Form: "HM", CPOS: "PRP", POS: "PRP", Features: map[string]string{"gen": "M", "num": "P", "per": "3"}, TokenID: 6}},
+		{Morpheme: Morpheme{BasicDirectedEdge: G.BasicDirectedEdge{4, 9, 10}, Form: "HM", CPOS: "COP", POS: "COP", Features: map[string]string{"gen": "M", "num": "P", "per": "3", "polar": "pos"}, TokenID: 6}},
 	},
-	nil,
+	BottomId: 7,
+	TopId:    10,
 }
 
 func TestLattice(t *testing.T) {
@@ -0,0 +1,93 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Character offsets link tokens and morphemes back to the input text
+// they were tokenized from; offsets are in characters (runes), and
+// the end offset is exclusive
+
+const OFFSETS_SEPARATOR = ":"
+
+// FormatOffsets returns offsets as written in the offsets field of the
+// lattice, mapping and conll formats (start:end)
+func FormatOffsets(start, end int) string {
+	return fmt.Sprintf("%d%s%d", start, OFFSETS_SEPARATOR, end)
+}
+
+// ParseOffsets parses an offsets field; "_" means no offsets
+func ParseOffsets(value string) (int, int, error) {
+	if value == "_" || value == "" {
+		return 0, 0, nil
+	}
+	split := strings.Split(value, OFFSETS_SEPARATOR)
+	if len(split) != 2 {
+		return 0, 0, fmt.Errorf("expected start%send, found %s", OFFSETS_SEPARATOR, value)
+	}
+	start, err := strconv.Atoi(split[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := strconv.Atoi(split[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if start < 0 || end < start {
+		return 0, 0, fmt.Errorf("invalid offsets %s", value)
+	}
+	return start, end, nil
+}
+
+// SetOffsets sets the offsets of the lattice's token in the input text,
+// and aligns its morphemes with the token's characters:
+// a morpheme whose form is found in the token where its path reaches
+// is given those characters, one that is not (e.g. the definite article
+// of ב+ה+בית for בבית) an empty span there, and the last morpheme of a
+// path the rest of the token (e.g. the suffix of בית+הוא for ביתו)
+func (l *Lattice) SetOffsets(start, end int) {
+	l.CharStart, l.CharEnd = start, end
+	token := []rune(string(l.Token))
+	morphs := make(Morphemes, len(l.Morphemes))
+	copy(morphs, l.Morphemes)
+	sort.SliceStable(morphs, func(i, j int) bool {
+		return morphs[i].From() < morphs[j].From()
+	})
+	// the position in the token each node is reached at
+	nodePos := map[int]int{l.BottomId: 0}
+	for _, m := range morphs {
+		pos := nodePos[m.From()]
+		mEnd := pos
+		if m.To() == l.TopId {
+			mEnd = len(token)
+		} else if form := []rune(m.Form); pos+len(form) <= len(token) && string(token[pos:pos+len(form)]) == m.Form {
+			mEnd = pos + len(form)
+		}
+		if _, exists := nodePos[m.To()]; !exists {
+			nodePos[m.To()] = mEnd
+		}
+		m.CharStart, m.CharEnd = start+pos, start+mEnd
+	}
+}
+
+// Offsets returns the offsets of a spellout's token, spanning its morphemes
+func (s Spellout) Offsets() (int, int) {
+	var start, end int
+	var found bool
+	for _, m := range s {
+		if m == nil {
+			continue
+		}
+		if !found || m.CharStart < start {
+			start = m.CharStart
+		}
+		if !found || m.CharEnd > end {
+			end = m.CharEnd
+		}
+		found = true
+	}
+	return start, end
+}
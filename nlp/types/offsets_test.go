@@ -0,0 +1,49 @@
+package types
+
+import (
+	"testing"
+
+	G "yap/alg/graph"
+)
+
+// testOffsetsLattice returns the lattice of a token with a morpheme per
+// form, each from and to the given nodes
+func testOffsetsLattice(token string, top int, edges ...interface{}) *Lattice {
+	l := &Lattice{Token: Token(token), BottomId: 0, TopId: top}
+	for i := 0; i < len(edges); i += 3 {
+		l.Morphemes = append(l.Morphemes, &EMorpheme{Morpheme: Morpheme{
+			BasicDirectedEdge: G.BasicDirectedEdge{len(l.Morphemes), edges[i].(int), edges[i+1].(int)},
+			Form:              edges[i+2].(string),
+		}})
+	}
+	return l
+}
+
+func TestSetOffsets(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		lattice  *Lattice
+		start    int
+		expected [][2]int
+	}{
+		// the definite article is not written, the noun follows the preposition
+		{"ב+ה+בית", testOffsetsLattice("בבית", 3, 0, 1, "ב", 1, 2, "ה", 2, 3, "בית", 0, 3, "בבית"), 10,
+			[][2]int{{10, 11}, {11, 11}, {11, 14}, {10, 14}}},
+		// the last morpheme of a path spans the rest of the token
+		{"בית+הוא", testOffsetsLattice("ביתו", 2, 0, 1, "בית", 1, 2, "הוא"), 20,
+			[][2]int{{20, 23}, {23, 24}}},
+		// a morpheme spelled differently than in the token has an empty span
+		{"עשיה+הוא", testOffsetsLattice("עשייתו", 2, 0, 1, "עשיה", 1, 2, "הוא"), 0,
+			[][2]int{{0, 0}, {0, 6}}},
+	} {
+		test.lattice.SetOffsets(test.start, test.start+len([]rune(string(test.lattice.Token))))
+		if test.lattice.CharStart != test.start {
+			t.Errorf("%v: token starts at %v, expected %v", test.name, test.lattice.CharStart, test.start)
+		}
+		for i, m := range test.lattice.Morphemes {
+			if offsets := [2]int{m.CharStart, m.CharEnd}; offsets != test.expected[i] {
+				t.Errorf("%v: morpheme %v at %v, expected %v", test.name, m.Form, offsets, test.expected[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package pipeline
+
+import (
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+	"yap/app"
+)
+
+var PipelineCommands = []*commander.Command{
+	PipelineCmd(),
+}
+
+func AllCommands() *commander.Command {
+	cmd := &commander.Command{
+		Subcommands: PipelineCommands,
+		Flag:        *flag.NewFlagSet("pipeline", flag.ExitOnError),
+	}
+	for _, pipeline := range cmd.Subcommands {
+		pipeline.Run = app.NewAppWrapCommand(pipeline.Run)
+		pipeline.Flag.IntVar(&app.CPUs, app.NUM_CPUS_FLAG, 0, "Max CPUS to use (runtime.GOMAXPROCS); 0 = all")
+		pipeline.Flag.StringVar(&app.CPUProfile, "cpuprofile", "", "write cpu profile to file")
+	}
+	return cmd
+}
@@ -0,0 +1,396 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"yap/app"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/lattice"
+	"yap/nlp/format/mapping"
+	"yap/nlp/format/segmentation"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	"yap/nlp/tokenizer"
+	nlp "yap/nlp/types"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+const (
+	MODE_JOINT    = "joint"
+	MODE_PIPELINE = "pipeline"
+)
+
+var (
+	CmdOptions = DefaultOptions()
+	Mode       string
+
+	inTextFile    string
+	pretokenized  bool
+	writeOffsets  bool
+	outLattice    string
+	outMapping    string
+	outSeg        string
+	outConll      string
+	outConllU     string
+	sentenceLimit int
+)
+
+// outputs are the files written by the pipeline command, each sentence
+// written to all of them once decoded
+type outputs struct {
+	files                                []*os.File
+	lattice, mapping, seg, conll, conllU *bufio.Writer
+}
+
+func createOutput(o *outputs, filename string) (*bufio.Writer, error) {
+	if len(filename) == 0 {
+		return nil, nil
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	o.files = append(o.files, file)
+	return bufio.NewWriter(file), nil
+}
+
+func (o *outputs) flush() error {
+	for _, writer := range []*bufio.Writer{o.lattice, o.mapping, o.seg, o.conll, o.conllU} {
+		if writer == nil {
+			continue
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *outputs) close() {
+	for _, file := range o.files {
+		file.Close()
+	}
+}
+
+func openOutputs() (*outputs, error) {
+	o := &outputs{}
+	var err error
+	for _, out := range []struct {
+		writer   **bufio.Writer
+		filename string
+	}{
+		{&o.lattice, outLattice},
+		{&o.mapping, outMapping},
+		{&o.seg, outSeg},
+		{&o.conll, outConll},
+		{&o.conllU, outConllU},
+	} {
+		if *out.writer, err = createOutput(o, out.filename); err != nil {
+			o.close()
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+func parsing() bool {
+	return len(outConll) > 0 || len(outConllU) > 0
+}
+
+func disambiguating() bool {
+	return parsing() || len(outMapping) > 0 || len(outSeg) > 0
+}
+
+// commandOptions returns the options of the components the requested
+// outputs need
+func commandOptions() Options {
+	opts := CmdOptions
+	if Mode == MODE_JOINT || !disambiguating() {
+		opts.MDModelFile = ""
+	}
+	if Mode == MODE_JOINT || !parsing() {
+		opts.DepModelFile = ""
+	}
+	if Mode == MODE_PIPELINE || !disambiguating() {
+		opts.JointModelFile = ""
+	}
+	return opts
+}
+
+func ConfigOut(opts Options) {
+	log.Println("Configuration")
+	log.Printf("Mode:\t\t\t%s", Mode)
+	log.Printf("MA Prefix:\t\t%s", opts.PrefixFile)
+	log.Printf("MA Lexicon:\t\t%s", opts.LexiconFile)
+	if len(opts.MDModelFile) > 0 {
+		log.Printf("MD Model:\t\t%s", opts.MDModelFile)
+		log.Printf("MD Features:\t\t%s", opts.MDFeaturesFile)
+		log.Printf("MD Param Func:\t%s", opts.MDParamFunc)
+	}
+	if len(opts.DepModelFile) > 0 {
+		log.Printf("Dep Model:\t\t%s", opts.DepModelFile)
+		log.Printf("Dep Features:\t\t%s", opts.DepFeaturesFile)
+	}
+	if len(opts.JointModelFile) > 0 {
+		log.Printf("Joint Model:\t\t%s", opts.JointModelFile)
+		log.Printf("Joint Features:\t%s", opts.JointFeaturesFile)
+		log.Printf("Joint Strategy:\t%s", opts.JointStrategy)
+		log.Printf("Oracle Strategy:\t%s", opts.OracleStrategy)
+	}
+	if len(opts.DepModelFile) > 0 || len(opts.JointModelFile) > 0 {
+		log.Printf("Labels:\t\t%s", opts.LabelsFile)
+	}
+	if len(opts.MDModelFile) > 0 || len(opts.JointModelFile) > 0 {
+		log.Printf("Beam Size:\t\t%d", opts.BeamSize)
+		log.Printf("Sentence Timeout:\t%v", opts.Timeout)
+	}
+	log.Println()
+	log.Printf("Input Text:\t\t%s", inTextFile)
+	log.Printf("Pretokenized:\t\t%v", pretokenized)
+	for _, out := range []struct{ name, file string }{
+		{"Lattice", outLattice},
+		{"Mapping", outMapping},
+		{"Segmentation", outSeg},
+		{"CoNLL", outConll},
+		{"CoNLL-U", outConllU},
+	} {
+		if len(out.file) > 0 {
+			log.Printf("Out %s:\t%s", out.name, out.file)
+		}
+	}
+	log.Println()
+}
+
+func readText(filename string) ([]tokenizer.Sentence, error) {
+	text, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var sents []tokenizer.Sentence
+	if pretokenized {
+		sents = tokenizer.Pretokenized(string(text))
+	} else {
+		sents = tokenizer.Tokenize(string(text))
+	}
+	if sentenceLimit > 0 && len(sents) > sentenceLimit {
+		sents = sents[:sentenceLimit]
+	}
+	return sents, nil
+}
+
+func Run(cmd *commander.Command, args []string) error {
+	app.VerifyFlags(cmd, []string{"in"})
+	if Mode != MODE_JOINT && Mode != MODE_PIPELINE {
+		return fmt.Errorf("Unknown mode %v, expected %v or %v", Mode, MODE_JOINT, MODE_PIPELINE)
+	}
+	if len(outLattice) == 0 && !disambiguating() {
+		return fmt.Errorf("No output set, expected at least one of -ol, -om, -os, -oc, -ocu")
+	}
+	if writeOffsets && len(outConllU) > 0 {
+		log.Println("Warning: CoNLL-U output has no offsets field, -offsets applies to the other outputs")
+	}
+	opts := commandOptions()
+	ConfigOut(opts)
+
+	sents, err := readText(inTextFile)
+	if err != nil {
+		return fmt.Errorf("Failed reading text file - %v", err)
+	}
+	log.Println("Read", len(sents), "sentences from", inTextFile)
+
+	log.Println("Loading models")
+	p, err := New(opts)
+	if err != nil {
+		return err
+	}
+	out, err := openOutputs()
+	if err != nil {
+		return err
+	}
+	defer out.close()
+
+	log.Println("Analyzing and parsing")
+	lats, err := p.AnalyzeSentences(sents)
+	if err != nil {
+		return err
+	}
+	for i := range lats {
+		if err := p.writeSentence(out, lats[i:i+1]); err != nil {
+			return fmt.Errorf("Sentence %v: %v", i+1, err)
+		}
+		if err := out.flush(); err != nil {
+			return err
+		}
+	}
+	log.Println("Wrote", len(lats), "sentences")
+	return nil
+}
+
+// writeSentence decodes a single sentence, writing it to the outputs
+func (p *Pipeline) writeSentence(out *outputs, lats []Lattice) error {
+	if out.lattice != nil {
+		lat, err := formatLattice(lats[0])
+		if err != nil {
+			return err
+		}
+		if writeOffsets {
+			lattice.WriteOffsets(out.lattice, []lattice.Lattice{lat})
+		} else {
+			lattice.Write(out.lattice, []lattice.Lattice{lat})
+		}
+	}
+	if !disambiguating() {
+		return nil
+	}
+	if Mode == MODE_JOINT {
+		return p.writeJoint(out, lats)
+	}
+	return p.writePipeline(out, lats)
+}
+
+func (p *Pipeline) writeJoint(out *outputs, lats []Lattice) error {
+	graphs, err := p.jointParse(context.Background(), lats)
+	if err != nil {
+		return err
+	}
+	if out.mapping != nil {
+		writeMappings(out.mapping, app.GetInstances(graphs, app.GetJointMDConfig))
+	}
+	if out.seg != nil {
+		segmentation.Write(out.seg, graphs)
+	}
+	if out.conll != nil {
+		writeConll(out.conll, conll.MorphGraph2ConllCorpus(graphs))
+	}
+	if out.conllU != nil {
+		conllu.Write(out.conllU, conllu.MorphGraph2ConllCorpus(graphs))
+	}
+	return nil
+}
+
+func (p *Pipeline) writePipeline(out *outputs, lats []Lattice) error {
+	mappings, err := p.disambiguate(context.Background(), lats)
+	if err != nil {
+		return err
+	}
+	if out.mapping != nil {
+		writeMappings(out.mapping, mappings)
+	}
+	if out.seg != nil {
+		for _, instance := range mappings {
+			segmentation.WriteMappings(out.seg, instance.(*disambig.MDConfig).Mappings)
+		}
+	}
+	if !parsing() {
+		return nil
+	}
+	sents := make([]Sentence, len(mappings))
+	for i, instance := range mappings {
+		sents[i] = mdConfigSentence(instance)
+	}
+	graphs, err := p.parse(context.Background(), sents)
+	if err != nil {
+		return err
+	}
+	if out.conll != nil {
+		writeConll(out.conll, p.dep.conllSentences(graphs, sents))
+	}
+	if out.conllU != nil {
+		conllu.Write(out.conllU, p.dep.conlluSentences(graphs, sents, mappings))
+	}
+	return nil
+}
+
+func writeMappings(writer *bufio.Writer, mappings []interface{}) {
+	if writeOffsets {
+		mapping.WriteOffsets(writer, mappings)
+	} else {
+		mapping.Write(writer, mappings)
+	}
+}
+
+func writeConll(writer *bufio.Writer, sents []interface{}) {
+	if writeOffsets {
+		conll.WriteOffsets(writer, sents)
+	} else {
+		conll.Write(writer, sents)
+	}
+}
+
+// conlluSentences converts the dependency graphs of disambiguated sentences
+// to CoNLL-U, with the multi-word tokens of their mappings
+func (c *component) conlluSentences(graphs []interface{}, sents []Sentence, mappings []interface{}) []interface{} {
+	conllus := conllu.Graph2ConllUCorpus(graphs, c.eMHost, c.eMSuffix)
+	for i, sent := range sents {
+		conlluSent := conllus[i].(conllu.Sentence)
+		conlluSent.Mappings = nlp.Mappings{}
+		for _, m := range mappings[i].(*disambig.MDConfig).Mappings {
+			if m.Token != nlp.ROOT_TOKEN {
+				conlluSent.Mappings = append(conlluSent.Mappings, m)
+			}
+		}
+		for j, m := range sent {
+			if row, exists := conlluSent.Deps[j+1]; exists {
+				row.TokenID = m.Token
+				conlluSent.Deps[j+1] = row
+			}
+		}
+		conllus[i] = conlluSent
+	}
+	return conllus
+}
+
+func PipelineCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       Run,
+		UsageLine: "pipeline <file options> [arguments]",
+		Short:     "analyzes, disambiguates and parses raw Hebrew text",
+		Long: `
+analyzes, disambiguates and parses raw Hebrew text, writing any of the
+lattice (MA), mapping, segmentation, CoNLL and CoNLL-U outputs
+
+	$ ./yap pipeline -in <text file> [-mode joint|pipeline] [-ol <out lat>] [-om <out map>] [-os <out seg>] [-oc <out conll>] [-ocu <out conllu>] [options]
+
+`,
+		Flag: *flag.NewFlagSet("pipeline", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&inTextFile, "in", "", "Input text file")
+	cmd.Flag.BoolVar(&pretokenized, "pretokenized", false, "Input is tokenized: tokens separated by white space, sentences by an empty line")
+	cmd.Flag.IntVar(&sentenceLimit, "limit", 0, "Limit input set")
+	cmd.Flag.StringVar(&Mode, "mode", MODE_JOINT, "Mode: [joint (morpho-syntactic parser), pipeline (disambiguator, then dependency parser)]")
+	cmd.Flag.StringVar(&outLattice, "ol", "", "Output Lattice File (morphological analysis)")
+	cmd.Flag.StringVar(&outMapping, "om", "", "Output Mapping File")
+	cmd.Flag.StringVar(&outSeg, "os", "", "Output Segmentation File")
+	cmd.Flag.StringVar(&outConll, "oc", "", "Output Conll File")
+	cmd.Flag.StringVar(&outConllU, "ocu", "", "Output CoNLL-U File")
+	cmd.Flag.BoolVar(&writeOffsets, "offsets", false, "Add the character offsets of each morpheme in the input text to the output lattice, mapping and conll")
+	cmd.Flag.StringVar(&CmdOptions.PrefixFile, "ma_prefix", CmdOptions.PrefixFile, "Prefix file for morphological analyzer")
+	cmd.Flag.StringVar(&CmdOptions.LexiconFile, "ma_lexicon", CmdOptions.LexiconFile, "Lexicon file for morphological analyzer")
+	cmd.Flag.BoolVar(&CmdOptions.AlwaysNNP, "ma_always_nnp", false, "Always add NNP to tokens and prefixed subtokens")
+	cmd.Flag.BoolVar(&CmdOptions.NNPNoFeats, "ma_add_nnp_no_feats", false, "Add NNP in lex but without features")
+	cmd.Flag.StringVar(&CmdOptions.MDModelFile, "md_model_name", CmdOptions.MDModelFile, "MD model file")
+	cmd.Flag.StringVar(&CmdOptions.MDFeaturesFile, "md_features", CmdOptions.MDFeaturesFile, "MD features file")
+	cmd.Flag.StringVar(&CmdOptions.MDParamFunc, "md_param_func", CmdOptions.MDParamFunc, "MD param func types: ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.StringVar(&CmdOptions.DepModelFile, "dep_model_name", CmdOptions.DepModelFile, "Dep model file")
+	cmd.Flag.StringVar(&CmdOptions.DepFeaturesFile, "dep_features", CmdOptions.DepFeaturesFile, "Dep features file")
+	cmd.Flag.StringVar(&CmdOptions.LabelsFile, "dep_labels", CmdOptions.LabelsFile, "Dep labels file")
+	cmd.Flag.StringVar(&CmdOptions.JointModelFile, "joint_model_name", CmdOptions.JointModelFile, "Joint model file")
+	cmd.Flag.StringVar(&CmdOptions.JointFeaturesFile, "joint_features", CmdOptions.JointFeaturesFile, "Joint features file")
+	cmd.Flag.StringVar(&CmdOptions.JointStrategy, "joint_strategy", CmdOptions.JointStrategy, "Joint Strategy: ["+joint.JointStrategies+"]")
+	cmd.Flag.StringVar(&CmdOptions.OracleStrategy, "joint_oracle_strategy", CmdOptions.OracleStrategy, "Oracle Strategy: ["+joint.OracleStrategies+"]")
+	cmd.Flag.BoolVar(&app.MmapModels, "mmap", false, "Memory map binary model files (see model convert) while loading them")
+	cmd.Flag.IntVar(&CmdOptions.BeamSize, "beam", CmdOptions.BeamSize, "Beam size")
+	cmd.Flag.BoolVar(&CmdOptions.ConcurrentBeam, "bconc", false, "Concurrent Beam")
+	cmd.Flag.DurationVar(&CmdOptions.Timeout, "sentence_timeout", 0, "Time budget for parsing a single sentence (e.g. 10s); 0 = no limit")
+	cmd.Flag.BoolVar(&CmdOptions.CompleteGreedily, "timeout_greedy", false, "When a sentence runs out of time complete its best candidate greedily instead of failing")
+	cmd.Flag.BoolVar(&CmdOptions.IgnoreLemma, "nolemma", CmdOptions.IgnoreLemma, "Ignore lemmas")
+	return cmd
+}
@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/joint"
+	"yap/nlp/tokenizer"
+	nlp "yap/nlp/types"
+)
+
+// Analyze tokenizes raw text and returns the ambiguous lattice of each
+// sentence: all analyses of its tokens found in the lexicon
+func (p *Pipeline) Analyze(text string) ([]Lattice, error) {
+	return p.AnalyzeSentences(tokenizer.Tokenize(text))
+}
+
+// AnalyzeSentences analyzes already tokenized sentences, their morphemes
+// getting the offsets of their tokens (see tokenizer.Pretokenized)
+func (p *Pipeline) AnalyzeSentences(sents []tokenizer.Sentence) (lats []Lattice, err error) {
+	if p.ma == nil {
+		return nil, fmt.Errorf("analyze: %v", ErrNotLoaded)
+	}
+	defer recoverError("analyzing sentences", &err)
+	// the lexicon and prefixes are shared, only the stats are per instance
+	maInstance := *p.ma
+	maInstance.Stats = nil
+	lats = make([]Lattice, len(sents))
+	for i, sent := range sents {
+		latSent, _ := maInstance.Analyze(sent.Tokens())
+		for j, token := range sent {
+			latSent[j].SetOffsets(token.Start, token.End)
+		}
+		lats[i] = fromLatticeSentence(latSent)
+	}
+	return lats, nil
+}
+
+// Disambiguate chooses an analysis of each token of ambiguous lattices
+func (p *Pipeline) Disambiguate(ctx context.Context, lats []Lattice) ([]Sentence, error) {
+	mappings, err := p.disambiguate(ctx, lats)
+	if err != nil {
+		return nil, err
+	}
+	sents := make([]Sentence, len(mappings))
+	for i, mapping := range mappings {
+		sents[i] = mdConfigSentence(mapping)
+	}
+	return sents, nil
+}
+
+// disambiguate returns the disambiguated lattices as *disambig.MDConfig
+func (p *Pipeline) disambiguate(ctx context.Context, lats []Lattice) ([]interface{}, error) {
+	if p.md == nil {
+		return nil, fmt.Errorf("disambiguate: %v", ErrNotLoaded)
+	}
+	instances, err := p.md.instances(lattices(lats))
+	if err != nil {
+		return nil, err
+	}
+	return p.md.decode(ctx, instances)
+}
+
+// Parse sets the heads and relations of the morphemes of disambiguated
+// sentences, returning parsed copies of them
+func (p *Pipeline) Parse(ctx context.Context, sents []Sentence) ([]Sentence, error) {
+	graphs, err := p.parse(ctx, sents)
+	if err != nil {
+		return nil, err
+	}
+	trees := p.dep.conllSentences(graphs, sents)
+	parsed := make([]Sentence, len(sents))
+	for i, sent := range sents {
+		parsed[i] = make(Sentence, len(sent))
+		copy(parsed[i], sent)
+		setTree(parsed[i], trees[i].(conll.Sentence))
+	}
+	return parsed, nil
+}
+
+// parse returns the dependency graphs of disambiguated sentences
+func (p *Pipeline) parse(ctx context.Context, sents []Sentence) ([]interface{}, error) {
+	if p.dep == nil {
+		return nil, fmt.Errorf("parse: %v", ErrNotLoaded)
+	}
+	morphs := make([][]Morpheme, len(sents))
+	for i, sent := range sents {
+		morphs[i] = sent
+	}
+	instances, err := p.dep.instances(morphs)
+	if err != nil {
+		return nil, err
+	}
+	tagged, err := taggedSentences(instances)
+	if err != nil {
+		return nil, err
+	}
+	return p.dep.decode(ctx, tagged)
+}
+
+// JointParse disambiguates and parses ambiguous lattices jointly
+func (p *Pipeline) JointParse(ctx context.Context, lats []Lattice) ([]Sentence, error) {
+	graphs, err := p.jointParse(ctx, lats)
+	if err != nil {
+		return nil, err
+	}
+	sents := make([]Sentence, len(graphs))
+	for i, graph := range graphs {
+		sents[i] = fromMappings(graph.(*joint.JointConfig).MDConfig.Mappings)
+		setTree(sents[i], conll.MorphGraph2Conll(graph.(nlp.MorphDependencyGraph)))
+	}
+	return sents, nil
+}
+
+// jointParse returns the parsed lattices as *joint.JointConfig
+func (p *Pipeline) jointParse(ctx context.Context, lats []Lattice) ([]interface{}, error) {
+	if p.joint == nil {
+		return nil, fmt.Errorf("joint parse: %v", ErrNotLoaded)
+	}
+	instances, err := p.joint.instances(lattices(lats))
+	if err != nil {
+		return nil, err
+	}
+	return p.joint.decode(ctx, instances)
+}
+
+func lattices(lats []Lattice) [][]Morpheme {
+	morphs := make([][]Morpheme, len(lats))
+	for i, lat := range lats {
+		morphs[i] = lat
+	}
+	return morphs
+}
+
+// instances converts sentences of morphemes to the component's instances
+// (lattice sentences)
+func (c *component) instances(sents [][]Morpheme) ([]interface{}, error) {
+	lats := make([]lattice.Lattice, len(sents))
+	for i, sent := range sents {
+		lat, err := formatLattice(sent)
+		if err != nil {
+			return nil, fmt.Errorf("sentence %v: %v", i+1, err)
+		}
+		lats[i] = lat
+	}
+	return c.sentences(lats)
+}
+
+// taggedSentences converts disambiguated lattice sentences to tagged
+// sentences, reporting ambiguous ones (which panic during conversion)
+func taggedSentences(lats []interface{}) (sents []interface{}, err error) {
+	defer recoverError("reading disambiguated sentences", &err)
+	sents = make([]interface{}, len(lats))
+	for i, instance := range lats {
+		sents[i] = instance.(nlp.LatticeSentence).TaggedSentence()
+	}
+	return sents, nil
+}
+
+// conllSentences converts the dependency graphs of disambiguated sentences
+// to conll.Sentence, with the offsets of their morphemes
+func (c *component) conllSentences(graphs []interface{}, sents []Sentence) []interface{} {
+	trees := conll.Graph2ConllCorpus(graphs, c.eMHost, c.eMSuffix)
+	for i, sent := range sents {
+		tree := trees[i].(conll.Sentence)
+		for j, m := range sent {
+			if row, exists := tree[j+1]; exists {
+				row.CharStart, row.CharEnd = m.Start, m.End
+				tree[j+1] = row
+			}
+		}
+	}
+	return trees
+}
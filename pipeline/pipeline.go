@@ -0,0 +1,600 @@
+// Package pipeline embeds yap's Hebrew morphological analysis,
+// disambiguation and parsing in Go programs
+//
+// A Pipeline owns its models, enum sets and transition systems, so several
+// pipelines (e.g. with different models) may be used in one process, and
+// one pipeline may be used by several goroutines. Options mirror the flags
+// of the api command, and file names are found as the api command finds
+// them (as given, else in the conf, data and lexicon directories next to
+// the executable).
+//
+// Models saved with their training configuration set the features,
+// labels, MD param func and joint strategies, overriding the options;
+// models trained with settings a pipeline does not support are refused.
+//
+// Whether lemmas are ignored is set per model (by its configuration, else
+// by Options.IgnoreLemma). The open class family of the MD param funcs
+// (HEBTB) is a package setting of nlp, set once by the first pipeline and
+// shared by all pipelines.
+//
+//	p, err := pipeline.New(pipeline.DefaultOptions())
+//	lats, err := p.Analyze("גנן גידל דגן בגן.")
+//	sents, err := p.JointParse(ctx, lats)
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"yap/alg/search"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	"yap/app"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	"yap/nlp/parser/ma"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	. "yap/nlp/parser/dependency/transition"
+)
+
+// Options configure the components of a pipeline; a component is loaded
+// only if its files are set
+type Options struct {
+	// morphological analysis (BGU lexicon), for Analyze
+	PrefixFile  string
+	LexiconFile string
+	AlwaysNNP   bool
+	NNPNoFeats  bool
+
+	// morphological disambiguation, for Disambiguate
+	MDModelFile    string
+	MDFeaturesFile string
+	MDParamFunc    string
+	// ignore lemmas (as -nolemma) with MD and joint models saved without
+	// their configuration, models saved with it set their own
+	IgnoreLemma bool
+
+	// dependency parsing, for Parse
+	DepModelFile    string
+	DepFeaturesFile string
+
+	// joint morpho-syntactic parsing, for JointParse
+	JointModelFile    string
+	JointFeaturesFile string
+	JointStrategy     string
+	OracleStrategy    string
+
+	// dependency labels of the dependency and joint parsers
+	LabelsFile string
+
+	BeamSize       int
+	ConcurrentBeam bool
+	// time budget for decoding a single sentence, 0 = no limit
+	Timeout time.Duration
+	// complete a sentence that ran out of time greedily instead of failing
+	CompleteGreedily bool
+}
+
+// DefaultOptions returns the defaults of the api command, loading all
+// components
+func DefaultOptions() Options {
+	return Options{
+		PrefixFile:        app.DEFAULT_HEB_MA_PREFIX_FILE,
+		LexiconFile:       app.DEFAULT_HEB_MA_LEXICON_FILE,
+		MDModelFile:       app.DEFAULT_MD_MODEL_FILE,
+		MDFeaturesFile:    app.DEFAULT_MD_FEATURES_FILE,
+		MDParamFunc:       app.DEFAULT_MD_PARAM_FUNC,
+		IgnoreLemma:       true,
+		DepModelFile:      app.DEFAULT_DEP_MODEL_FILE,
+		DepFeaturesFile:   app.DEFAULT_DEP_FEATURES_FILE,
+		JointModelFile:    app.DEFAULT_JOINT_MODEL_FILE,
+		JointFeaturesFile: app.DEFAULT_JOINT_FEATURES_FILE,
+		JointStrategy:     app.DEFAULT_JOINT_STRATEGY,
+		OracleStrategy:    app.DEFAULT_ORACLE_STRATEGY,
+		LabelsFile:        app.DEFAULT_LABELS_FILE,
+		BeamSize:          app.DEFAULT_BEAM_SIZE,
+	}
+}
+
+var ErrNotLoaded = errors.New("component not loaded")
+
+// paramFamily initializes the open class family of the MD param funcs,
+// read by all pipelines, once
+var paramFamily sync.Once
+
+func initParamFamily() {
+	paramFamily.Do(func() {
+		nlp.InitOpenParamFamily("HEBTB")
+	})
+}
+
+// Pipeline analyzes, disambiguates and parses Hebrew text
+// All methods are safe for concurrent use
+type Pipeline struct {
+	opts  Options
+	ma    *ma.BGULex
+	md    *component
+	dep   *component
+	joint *component
+}
+
+// component is a loaded model: its beam (copied per decoded batch) and
+// the enum sets its input is enumerated with
+type component struct {
+	beam                                 search.Beam
+	eWord, ePOS, eWPOS, eMHost, eMSuffix *util.EnumSet
+	eMorphProp                           *util.EnumSet
+}
+
+// New loads the components set in opts
+func New(opts Options) (p *Pipeline, err error) {
+	defer recoverError("loading pipeline", &err)
+	parsers := len(opts.MDModelFile) > 0 || len(opts.DepModelFile) > 0 || len(opts.JointModelFile) > 0
+	if parsers && opts.BeamSize < 1 {
+		return nil, fmt.Errorf("beam size must be positive, got %v", opts.BeamSize)
+	}
+	p = &Pipeline{opts: opts}
+	if len(opts.PrefixFile) > 0 || len(opts.LexiconFile) > 0 {
+		if p.ma, err = loadMA(opts); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.MDModelFile) > 0 {
+		if p.md, err = loadMD(opts); err != nil {
+			return nil, fmt.Errorf("loading MD: %v", err)
+		}
+	}
+	if len(opts.DepModelFile) > 0 {
+		if p.dep, err = loadDep(opts); err != nil {
+			return nil, fmt.Errorf("loading dep: %v", err)
+		}
+	}
+	if len(opts.JointModelFile) > 0 {
+		if p.joint, err = loadJoint(opts); err != nil {
+			return nil, fmt.Errorf("loading joint: %v", err)
+		}
+	}
+	return p, nil
+}
+
+// Options returns the options the pipeline was created with
+func (p *Pipeline) Options() Options {
+	return p.opts
+}
+
+func recoverError(doing string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("failed %v: %v", doing, r)
+	}
+}
+
+func locate(name string, dirs []string) (string, error) {
+	if app.VerifyExists(name) {
+		return name, nil
+	}
+	location, found := util.LocateFile(name, dirs)
+	if !found {
+		return "", fmt.Errorf("file not found: %v", name)
+	}
+	return location, nil
+}
+
+// readRelations returns the model's labels, or for models without a
+// configuration the ones in the labels file
+func readRelations(config *app.ModelConfig, opts Options) ([]string, error) {
+	if config != nil {
+		return config.Relations("")
+	}
+	location, err := locate(opts.LabelsFile, app.DEFAULT_CONF_DIRS)
+	if err != nil {
+		return nil, err
+	}
+	relations, err := config.Relations(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading labels from %v: %v", location, err)
+	}
+	return relations, nil
+}
+
+func readFeatures(config *app.ModelConfig, file string) (*transition.FeatureSetup, error) {
+	if config != nil {
+		return config.FeatureSetup("")
+	}
+	location, err := locate(file, app.DEFAULT_CONF_DIRS)
+	if err != nil {
+		return nil, err
+	}
+	return config.FeatureSetup(location)
+}
+
+// verifyConfig returns an error if a model was trained with settings
+// pipelines do not support
+func verifyConfig(config *app.ModelConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.Component != app.MODEL_MD {
+		if err := config.VerifyArcSystem("eager"); err != nil {
+			return err
+		}
+	}
+	if config.Component != app.MODEL_DEP && !config.UsePOP {
+		return fmt.Errorf("models trained without the end token (pop) are not supported")
+	}
+	if config.WordBased {
+		return fmt.Errorf("word based MD models are not supported")
+	}
+	return nil
+}
+
+// ignoresLemma returns whether a model ignores lemmas: as it was trained,
+// or for models without a configuration as set in opts
+func ignoresLemma(config *app.ModelConfig, opts Options) bool {
+	if config != nil {
+		return config.IgnoreLemma
+	}
+	return opts.IgnoreLemma
+}
+
+// popTransition returns the POP transition of a model's transitions
+func popTransition(eTrans *util.EnumSet) (transition.Transition, error) {
+	iPOP, exists := eTrans.IndexOf("POP")
+	if !exists {
+		return nil, fmt.Errorf("model has no POP transition")
+	}
+	return &transition.TypedTransition{T: 'P', V: iPOP}, nil
+}
+
+// load reads a component's model and features
+func load(name, modelFile, featuresFile string) (*component, *transitionmodel.AvgMatrixSparse, *app.Serialization, *transition.FeatureSetup, error) {
+	location, err := locate(modelFile, app.DEFAULT_MODEL_DIRS)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	serialization, err := app.LoadModel(location, name)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if err := verifyConfig(serialization.Config); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("%v: %v", location, err)
+	}
+	featureSetup, err := readFeatures(serialization.Config, featuresFile)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if err := serialization.VerifyFeatures(featureSetup); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("%v: %v", location, err)
+	}
+	model := &transitionmodel.AvgMatrixSparse{}
+	if err := serialization.LoadWeights(model); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("%v: %v", location, err)
+	}
+	c := &component{
+		eWord:      serialization.EWord,
+		ePOS:       serialization.EPOS,
+		eWPOS:      serialization.EWPOS,
+		eMHost:     serialization.EMHost,
+		eMSuffix:   serialization.EMSuffix,
+		eMorphProp: serialization.EMorphProp,
+	}
+	if c.eMorphProp == nil {
+		c.eMorphProp = util.NewEnumSet(130) // random guess of number of possible values
+	}
+	return c, model, serialization, featureSetup, nil
+}
+
+func (c *component) extractor(setup *transition.FeatureSetup, groups []byte, eRel, eTokens *util.EnumSet, pop transition.Transition) *transition.GenericExtractor {
+	extractor := &transition.GenericExtractor{
+		EFeatures:  util.NewEnumSet(setup.NumFeatures()),
+		EWord:      c.eWord,
+		EPOS:       c.ePOS,
+		EWPOS:      c.eWPOS,
+		ERel:       eRel,
+		EMHost:     c.eMHost,
+		EMSuffix:   c.eMSuffix,
+		EMorphProp: c.eMorphProp,
+		EToken:     eTokens,
+		POPTrans:   pop,
+	}
+	extractor.InitTypes(groups)
+	extractor.LoadFeatureSetup(setup)
+	return extractor
+}
+
+func (c *component) setBeam(opts Options, beam search.Beam) {
+	beam.Size = opts.BeamSize
+	beam.ConcurrentExec = opts.ConcurrentBeam
+	beam.ShortTempAgenda = true
+	beam.Timeout = opts.Timeout
+	beam.CompleteGreedily = opts.CompleteGreedily
+	c.beam = beam
+}
+
+func mdParamFunc(name string) (nlp.MDParam, error) {
+	paramFunc, exists := nlp.MDParams[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown MD param func %v, must be one of [%v]", name, nlp.AllParamFuncNames)
+	}
+	return paramFunc, nil
+}
+
+func verifyStrategy(name, strategies string) error {
+	for _, strategy := range strings.Split(strategies, ", ") {
+		if strategy == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown strategy %v, must be one of [%v]", name, strategies)
+}
+
+// relationEnum returns the enum set of dependency labels, as built by
+// app.SetupRelationEnum
+func relationEnum(relations []string) *util.EnumSet {
+	eRel := util.NewEnumSet(len(relations) + 1)
+	eRel.Add(nlp.DepRel(nlp.ROOT_LABEL))
+	for _, label := range relations {
+		eRel.Add(nlp.DepRel(label))
+	}
+	eRel.Frozen = true
+	return eRel
+}
+
+// arcTransitions are the arc eager transitions of a transition enum set
+type arcTransitions struct {
+	eTrans                         *util.EnumSet
+	shift, reduce, popRoot, la, ra transition.Transition
+}
+
+// arcTransitionEnum returns the transitions of the dependency parser,
+// as built by app.SetupTransEnum, or of the joint parser, followed by
+// POP, as built by app.SetupMorphTransEnum
+func arcTransitionEnum(relations []string, morph bool) arcTransitions {
+	var t arcTransitions
+	if morph {
+		t.eTrans = util.NewEnumSet((len(relations)+1)*2 + 2 + app.APPROX_MORPH_TRANSITIONS)
+		t.eTrans.Add("NO") // dummy for 0 action
+	} else {
+		t.eTrans = util.NewEnumSet((len(relations)+1)*2 + 2)
+		t.eTrans.Add("IDLE") // dummy no action transition for zpar equivalence
+	}
+	iSH, _ := t.eTrans.Add("SH")
+	iRE, _ := t.eTrans.Add("RE")
+	t.eTrans.Add("AL") // dummy action transition for zpar equivalence
+	t.eTrans.Add("AR") // dummy action transition for zpar equivalence
+	iPR, _ := t.eTrans.Add("PR")
+	t.shift = transition.ConstTransition(iSH)
+	t.reduce = transition.ConstTransition(iRE)
+	t.popRoot = transition.ConstTransition(iPR)
+	t.la = transition.ConstTransition(iPR + 1)
+	t.eTrans.Add("LA-" + string(nlp.ROOT_LABEL))
+	for _, relation := range relations {
+		t.eTrans.Add("LA-" + relation)
+	}
+	t.ra = transition.ConstTransition(t.eTrans.Len())
+	t.eTrans.Add("RA-" + string(nlp.ROOT_LABEL))
+	for _, relation := range relations {
+		t.eTrans.Add("RA-" + relation)
+	}
+	return t
+}
+
+func (t arcTransitions) arcEager(eRel, eTrans *util.EnumSet) *ArcEager {
+	arcSystem := &ArcEager{
+		ArcStandard: ArcStandard{
+			SHIFT:       t.shift.Value(),
+			LEFT:        t.la.Value(),
+			RIGHT:       t.ra.Value(),
+			Relations:   eRel,
+			Transitions: eTrans,
+		},
+		REDUCE:  t.reduce.Value(),
+		POPROOT: t.popRoot.Value(),
+	}
+	arcSystem.AddDefaultOracle()
+	return arcSystem
+}
+
+func loadMA(opts Options) (*ma.BGULex, error) {
+	prefixLocation, err := locate(opts.PrefixFile, app.HEB_MA_DEFAULT_DATA_DIRS)
+	if err != nil {
+		return nil, err
+	}
+	lexiconLocation, err := locate(opts.LexiconFile, app.HEB_MA_DEFAULT_DATA_DIRS)
+	if err != nil {
+		return nil, err
+	}
+	maData := &ma.BGULex{MAType: "spmrl", AlwaysNNP: opts.AlwaysNNP}
+	maData.LoadPrefixes(prefixLocation)
+	maData.LoadLex(lexiconLocation, opts.NNPNoFeats)
+	return maData, nil
+}
+
+func loadMD(opts Options) (*component, error) {
+	c, model, serialization, featureSetup, err := load(app.MODEL_MD, opts.MDModelFile, opts.MDFeaturesFile)
+	if err != nil {
+		return nil, err
+	}
+	if config := serialization.Config; config != nil {
+		opts.MDParamFunc = config.ParamFunc
+	}
+	paramFunc, err := mdParamFunc(opts.MDParamFunc)
+	if err != nil {
+		return nil, err
+	}
+	pop, err := popTransition(serialization.ETrans)
+	if err != nil {
+		return nil, err
+	}
+	ignoreLemma := ignoresLemma(serialization.Config, opts)
+	initParamFamily()
+	mdTrans := &disambig.MDTrans{
+		ParamFunc:   paramFunc,
+		UsePOP:      true,
+		POP:         pop,
+		Transitions: serialization.ETrans,
+	}
+	mdTrans.AddDefaultOracle()
+	c.setBeam(opts, search.Beam{
+		TransFunc:     mdTrans,
+		FeatExtractor: c.extractor(featureSetup, []byte("MPL"), nil, serialization.ETokens, pop),
+		Base: &disambig.MDConfig{
+			ETokens:         serialization.ETokens,
+			POP:             pop,
+			Transitions:     serialization.ETrans,
+			ParamFunc:       paramFunc,
+			UsePOP:          true,
+			SwitchFormLemma: !ignoreLemma,
+		},
+		Model:                model,
+		Transitions:          serialization.ETrans,
+		EstimatedTransitions: 1000, // chosen by random dice roll
+	})
+	return c, nil
+}
+
+func loadDep(opts Options) (*component, error) {
+	c, model, serialization, featureSetup, err := load(app.MODEL_DEP, opts.DepModelFile, opts.DepFeaturesFile)
+	if err != nil {
+		return nil, err
+	}
+	relations, err := readRelations(serialization.Config, opts)
+	if err != nil {
+		return nil, err
+	}
+	eRel := relationEnum(relations)
+	transitions := arcTransitionEnum(relations, false)
+	c.setBeam(opts, search.Beam{
+		TransFunc:     transitions.arcEager(eRel, transitions.eTrans),
+		FeatExtractor: c.extractor(featureSetup, []byte("A"), eRel, nil, nil),
+		Base: &SimpleConfiguration{
+			EWord:         c.eWord,
+			EPOS:          c.ePOS,
+			EWPOS:         c.eWPOS,
+			EMHost:        c.eMHost,
+			EMSuffix:      c.eMSuffix,
+			ERel:          eRel,
+			ETrans:        transitions.eTrans,
+			TerminalStack: 0,
+			TerminalQueue: 0,
+		},
+		Model:                model,
+		EstimatedTransitions: eRel.Len()*2 + 2,
+		ScoredStoreDense:     true,
+	})
+	return c, nil
+}
+
+func loadJoint(opts Options) (*component, error) {
+	c, model, serialization, featureSetup, err := load(app.MODEL_JOINT, opts.JointModelFile, opts.JointFeaturesFile)
+	if err != nil {
+		return nil, err
+	}
+	if config := serialization.Config; config != nil {
+		opts.MDParamFunc = config.ParamFunc
+		opts.JointStrategy = config.JointStrategy
+		opts.OracleStrategy = config.OracleStrategy
+	}
+	paramFunc, err := mdParamFunc(opts.MDParamFunc)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyStrategy(opts.JointStrategy, joint.JointStrategies); err != nil {
+		return nil, err
+	}
+	if err := verifyStrategy(opts.OracleStrategy, joint.OracleStrategies); err != nil {
+		return nil, err
+	}
+	relations, err := readRelations(serialization.Config, opts)
+	if err != nil {
+		return nil, err
+	}
+	eRel := relationEnum(relations)
+	transitions := arcTransitionEnum(relations, true)
+	pop, err := popTransition(serialization.ETrans)
+	if err != nil {
+		return nil, err
+	}
+	// MD transitions follow POP, as in app.SetupMorphTransEnum
+	if iPOP, _ := transitions.eTrans.Add("POP"); pop.Value() != iPOP {
+		return nil, fmt.Errorf("model's POP transition is %v, expected %v", pop.Value(), iPOP)
+	}
+	md := transition.ConstTransition(transitions.eTrans.Len())
+	ignoreLemma := ignoresLemma(serialization.Config, opts)
+	initParamFamily()
+	mdTrans := &disambig.MDTrans{
+		ParamFunc:    paramFunc,
+		UsePOP:       true,
+		IgnoreLemmas: ignoreLemma,
+		POP:          pop,
+		Transitions:  serialization.ETrans,
+	}
+	mdTrans.AddDefaultOracle()
+	jointTrans := &joint.JointTrans{
+		MDTrans:       mdTrans,
+		ArcSys:        transitions.arcEager(eRel, serialization.ETrans),
+		Transitions:   serialization.ETrans,
+		JointStrategy: opts.JointStrategy,
+		MDTransition:  md,
+	}
+	jointTrans.AddDefaultOracle()
+	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = opts.OracleStrategy
+	c.setBeam(opts, search.Beam{
+		TransFunc:     jointTrans,
+		FeatExtractor: c.extractor(featureSetup, []byte("MPLA"), eRel, serialization.ETokens, pop),
+		Base: &joint.JointConfig{
+			SimpleConfiguration: SimpleConfiguration{
+				EWord:         c.eWord,
+				EPOS:          c.ePOS,
+				EWPOS:         c.eWPOS,
+				EMHost:        c.eMHost,
+				EMSuffix:      c.eMSuffix,
+				ERel:          eRel,
+				ETrans:        serialization.ETrans,
+				TerminalStack: 0,
+				TerminalQueue: 0,
+			},
+			MDConfig: disambig.MDConfig{
+				ETokens:         serialization.ETokens,
+				POP:             pop,
+				Transitions:     serialization.ETrans,
+				ParamFunc:       paramFunc,
+				UsePOP:          true,
+				SwitchFormLemma: !ignoreLemma,
+			},
+			MDTrans: md,
+		},
+		Model:                model,
+		Transitions:          serialization.ETrans,
+		EstimatedTransitions: 1000, // chosen by random dice roll
+	})
+	return c, nil
+}
+
+// decode decodes instances with a copy of the component's beam, stopping
+// at the first instance that fails
+func (c *component) decode(ctx context.Context, instances []interface{}) ([]interface{}, error) {
+	beam := c.beam
+	decoded := make([]interface{}, len(instances))
+	for i, instance := range instances {
+		result, _, err := beam.ParseContext(ctx, instance)
+		if err != nil {
+			return nil, fmt.Errorf("sentence %v: %v", i+1, err)
+		}
+		decoded[i] = result
+	}
+	return decoded, nil
+}
+
+// sentences enumerates lattices (or disambiguated sentences) with the
+// component's enum sets
+func (c *component) sentences(lats []lattice.Lattice) (sents []interface{}, err error) {
+	defer recoverError("reading lattices", &err)
+	return lattice.Lattice2SentenceCorpus(lats, c.eWord, c.ePOS, c.eWPOS, c.eMorphProp, c.eMHost, c.eMSuffix), nil
+}
@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	"yap/app"
+	"yap/nlp/parser/disambig"
+	"yap/util"
+)
+
+func testEnumSet(values ...interface{}) *util.EnumSet {
+	e := util.NewEnumSet(len(values))
+	for _, value := range values {
+		e.Add(value)
+	}
+	return e
+}
+
+// testMDModel returns an untrained MD model with the default features,
+// saved with its configuration unless config is nil
+func testMDModel(t *testing.T, config *app.ModelConfig, transitions ...interface{}) *app.Serialization {
+	features, err := ioutil.ReadFile(filepath.Join("../conf", app.DEFAULT_MD_FEATURES_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mat := make([]interface{}, transition.LoadFeatureConf(features).NumFeatures())
+	for i := range mat {
+		mat[i] = map[interface{}]map[int]int64{}
+	}
+	if config != nil {
+		config.Component, config.Features, config.FeaturesFile = app.MODEL_MD, features, app.DEFAULT_MD_FEATURES_FILE
+	}
+	return &app.Serialization{
+		WeightModel: &transitionmodel.AvgMatrixSparseSerialized{Mat: mat},
+		EWord:       testEnumSet(),
+		EPOS:        testEnumSet(),
+		EWPOS:       testEnumSet(),
+		EMHost:      testEnumSet(""),
+		EMSuffix:    testEnumSet(""),
+		EMorphProp:  testEnumSet(),
+		ETokens:     testEnumSet(),
+		ETrans:      testEnumSet(transitions...),
+		Config:      config,
+	}
+}
+
+func writeModel(t *testing.T, dir, name string, s *app.Serialization) string {
+	file := filepath.Join(dir, name)
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err := app.EncodeModel(out, s); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func mdConfig(ignoreLemma bool) *app.ModelConfig {
+	return &app.ModelConfig{ParamFunc: app.DEFAULT_MD_PARAM_FUNC, UsePOP: true, IgnoreLemma: ignoreLemma}
+}
+
+func TestOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	legacy := writeModel(t, dir, "legacy.model", testMDModel(t, nil, "IDLE", "POP"))
+	noPOP := writeModel(t, dir, "nopop.model", testMDModel(t, mdConfig(true), "IDLE"))
+
+	p, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Disambiguate(context.Background(), nil); err == nil || !strings.HasSuffix(err.Error(), ErrNotLoaded.Error()) {
+		t.Errorf("disambiguated with no MD model, error %v", err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		opts    Options
+		message string
+	}{
+		{"no beam", Options{MDModelFile: legacy}, "beam size must be positive, got 0"},
+		{"missing model", Options{MDModelFile: filepath.Join(dir, "missing.model"), BeamSize: 4}, "file not found"},
+		{"missing features", Options{MDModelFile: legacy, MDFeaturesFile: "missing.yaml", MDParamFunc: app.DEFAULT_MD_PARAM_FUNC, BeamSize: 4}, "file not found"},
+		{"unknown param func", Options{MDModelFile: legacy, MDFeaturesFile: "../conf/" + app.DEFAULT_MD_FEATURES_FILE, MDParamFunc: "Unknown", BeamSize: 4}, "unknown MD param func Unknown"},
+		{"MD model as dep", Options{DepModelFile: legacy, BeamSize: 4}, "expected dep"},
+		{"no POP transition", Options{MDModelFile: noPOP, BeamSize: 4}, "model has no POP transition"},
+	} {
+		if _, err := New(test.opts); err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%v: loaded with error %v, expected %q", test.name, err, test.message)
+		}
+	}
+
+	if err := verifyStrategy("ArcGreedy", "ArcGreedy, MDFirst"); err != nil {
+		t.Error(err)
+	}
+	if err := verifyStrategy("Unknown", "ArcGreedy, MDFirst"); err == nil {
+		t.Error("verified an unknown strategy")
+	}
+}
+
+func TestVerifyConfig(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		config  *app.ModelConfig
+		message string
+	}{
+		{"legacy", nil, ""},
+		{"md", &app.ModelConfig{Component: app.MODEL_MD, UsePOP: true}, ""},
+		{"dep", &app.ModelConfig{Component: app.MODEL_DEP, ArcSystem: "eager"}, ""},
+		{"joint", &app.ModelConfig{Component: app.MODEL_JOINT, ArcSystem: "eager", UsePOP: true}, ""},
+		{"lemmas", &app.ModelConfig{Component: app.MODEL_MD, UsePOP: true, IgnoreLemma: false}, ""},
+		{"arc standard dep", &app.ModelConfig{Component: app.MODEL_DEP, ArcSystem: "standard"},
+			"model was trained with the standard arc system, only eager is supported"},
+		{"arc standard joint", &app.ModelConfig{Component: app.MODEL_JOINT, ArcSystem: "standard", UsePOP: true},
+			"model was trained with the standard arc system, only eager is supported"},
+		{"md without pop", &app.ModelConfig{Component: app.MODEL_MD},
+			"models trained without the end token (pop) are not supported"},
+		{"joint without pop", &app.ModelConfig{Component: app.MODEL_JOINT, ArcSystem: "eager"},
+			"models trained without the end token (pop) are not supported"},
+		{"word based", &app.ModelConfig{Component: app.MODEL_MD, UsePOP: true, WordBased: true},
+			"word based MD models are not supported"},
+	} {
+		err := verifyConfig(test.config)
+		if len(test.message) == 0 && err != nil || len(test.message) > 0 && (err == nil || err.Error() != test.message) {
+			t.Errorf("%v: verified with error %v, expected %q", test.name, err, test.message)
+		}
+	}
+}
+
+func TestPipelinesSeparate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// models trained with and without lemmas, POP after other transitions
+	withoutLemmas := writeModel(t, dir, "nolemma.model", testMDModel(t, mdConfig(true), "IDLE", "POP"))
+	withLemmas := writeModel(t, dir, "lemma.model", testMDModel(t, mdConfig(false), "IDLE", "MD-a", "POP"))
+
+	pipelines := make([]*Pipeline, 2)
+	for i, file := range []string{withoutLemmas, withLemmas} {
+		if pipelines[i], err = New(Options{MDModelFile: file, BeamSize: 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, expected := range []struct {
+		switchFormLemma bool
+		pop             int
+	}{{false, 1}, {true, 2}} {
+		beam := pipelines[i].md.beam
+		if switchFormLemma := beam.Base.(*disambig.MDConfig).SwitchFormLemma; switchFormLemma != expected.switchFormLemma {
+			t.Errorf("pipeline %v switches form and lemma: %v, expected %v", i, switchFormLemma, expected.switchFormLemma)
+		}
+		if pop := beam.TransFunc.(*disambig.MDTrans).POP.Value(); pop != expected.pop {
+			t.Errorf("pipeline %v POP transition is %v, expected %v", i, pop, expected.pop)
+		}
+	}
+
+	lats := []Lattice{{
+		{From: 0, To: 1, Token: 1, TokenForm: "גנן", Form: "גנן", Lemma: "גנן", CPOS: "NN", POS: "NN", Feats: "gen=M|num=S"},
+		{From: 1, To: 2, Token: 2, TokenForm: "גידל", Form: "גידל", Lemma: "גידל", CPOS: "VB", POS: "VB", Feats: "gen=M|num=S|per=3|tense=PAST"},
+	}}
+	words := pipelines[1].md.eWord.Len()
+	if _, err := pipelines[0].Disambiguate(context.Background(), lats); err != nil {
+		t.Fatal(err)
+	}
+	if pipelines[0].md.eWord == pipelines[1].md.eWord {
+		t.Fatal("pipelines share their word enum set")
+	}
+	if pipelines[0].md.eWord.Len() == 0 {
+		t.Error("disambiguated words were not enumerated")
+	}
+	if pipelines[1].md.eWord.Len() != words {
+		t.Errorf("words disambiguated by one pipeline were enumerated by the other")
+	}
+}
@@ -0,0 +1,175 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+)
+
+// Morpheme is an edge of an ambiguous lattice (see Analyze) or a morpheme
+// of a disambiguated sentence (see Disambiguate), with its fields as in
+// the lattice format; Head and Relation are set once the sentence is parsed
+type Morpheme struct {
+	// lattice nodes the morpheme spans
+	From, To int
+	// 1-based index of the token in the sentence, and the token's text
+	Token     int
+	TokenForm string
+
+	Form  string
+	Lemma string
+	CPOS  string
+	POS   string
+	// features in the lattice FEATS notation (e.g. gen=M|num=S), _ if none
+	Feats string
+
+	// 1-based index of the head morpheme in the sentence, 0 for the root
+	Head     int
+	Relation string
+
+	// character offsets in the input text (end is exclusive); a morpheme
+	// not written in the text, such as the definite article of בבית,
+	// has an empty span
+	Start, End int
+}
+
+// Features returns the morpheme's features as a map
+func (m Morpheme) Features() map[string]string {
+	features, err := lattice.ParseFeatures(m.featStr())
+	if err != nil {
+		return nil
+	}
+	return features
+}
+
+func (m Morpheme) featStr() string {
+	if len(m.Feats) == 0 {
+		return "_"
+	}
+	return m.Feats
+}
+
+// Lattice is the ambiguous lattice of a sentence: all analyses of its tokens
+type Lattice []Morpheme
+
+// Sentence is a disambiguated sentence: its morphemes in order
+type Sentence []Morpheme
+
+func edge(id int, m Morpheme) (lattice.Edge, error) {
+	if m.To <= m.From {
+		return lattice.Edge{}, fmt.Errorf("morpheme %v spans nodes %v to %v", m.Form, m.From, m.To)
+	}
+	if m.Token < 1 {
+		return lattice.Edge{}, fmt.Errorf("morpheme %v has token %v, tokens are 1-based", m.Form, m.Token)
+	}
+	if len(m.CPOS) == 0 || len(m.POS) == 0 {
+		return lattice.Edge{}, fmt.Errorf("morpheme %v has no part of speech", m.Form)
+	}
+	features, err := lattice.ParseFeatures(m.featStr())
+	if err != nil {
+		return lattice.Edge{}, fmt.Errorf("morpheme %v has bad features %v: %v", m.Form, m.Feats, err)
+	}
+	return lattice.Edge{
+		Start:     m.From,
+		End:       m.To,
+		Word:      m.Form,
+		Lemma:     m.Lemma,
+		CPosTag:   m.CPOS,
+		PosTag:    m.POS,
+		Feats:     features,
+		FeatStr:   m.featStr(),
+		Token:     m.Token,
+		Id:        id,
+		TokenStr:  m.TokenForm,
+		CharStart: m.Start,
+		CharEnd:   m.End,
+	}, nil
+}
+
+// formatLattice converts morphemes to the lattice format, as read by
+// lattice.Read
+func formatLattice(morphs []Morpheme) (lattice.Lattice, error) {
+	if len(morphs) == 0 {
+		return nil, errors.New("empty sentence")
+	}
+	lat := make(lattice.Lattice)
+	for i, m := range morphs {
+		e, err := edge(i+1, m)
+		if err != nil {
+			return nil, err
+		}
+		lat[e.Start] = append(lat[e.Start], e)
+	}
+	return lat, nil
+}
+
+func fromEMorpheme(m *nlp.EMorpheme, token string) Morpheme {
+	feats := m.FeatureStr
+	if len(feats) == 0 {
+		feats = "_"
+	}
+	return Morpheme{
+		From:      m.From(),
+		To:        m.To(),
+		Token:     m.TokenID,
+		TokenForm: token,
+		Form:      m.Form,
+		Lemma:     m.Lemma,
+		CPOS:      m.CPOS,
+		POS:       m.POS,
+		Feats:     feats,
+		Start:     m.CharStart,
+		End:       m.CharEnd,
+	}
+}
+
+// fromLatticeSentence returns the analyses of an MA lattice sentence
+func fromLatticeSentence(sent nlp.LatticeSentence) Lattice {
+	var lat Lattice
+	for _, tokenLat := range sent {
+		for _, m := range tokenLat.Morphemes {
+			lat = append(lat, fromEMorpheme(m, string(tokenLat.Token)))
+		}
+	}
+	return lat
+}
+
+// fromMappings returns the disambiguated morphemes of mappings,
+// numbered as in the mapping format: a node per morpheme in order
+func fromMappings(mappings nlp.Mappings) Sentence {
+	var sent Sentence
+	for i, mapping := range mappings {
+		if mapping.Token == nlp.ROOT_TOKEN {
+			continue
+		}
+		for _, m := range mapping.Spellout {
+			if m == nil {
+				continue
+			}
+			morph := fromEMorpheme(m, string(mapping.Token))
+			morph.From, morph.To = len(sent), len(sent)+1
+			morph.Token = i + 1
+			sent = append(sent, morph)
+		}
+	}
+	return sent
+}
+
+// setTree sets the heads and relations of a sentence from its tree,
+// whose rows are numbered as the sentence's morphemes (1-based)
+func setTree(sent Sentence, tree conll.Sentence) {
+	for i := range sent {
+		if row, exists := tree[i+1]; exists {
+			sent[i].Head = row.Head
+			sent[i].Relation = row.DepRel
+		}
+	}
+}
+
+func mdConfigSentence(instance interface{}) Sentence {
+	return fromMappings(instance.(*disambig.MDConfig).Mappings)
+}
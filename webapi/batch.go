@@ -0,0 +1,193 @@
+package webapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+	"yap/alg/search"
+	"yap/app"
+	"yap/nlp/format/lattice"
+	"yap/nlp/tokenizer"
+)
+
+// BatchResult is a single line of the NDJSON batch response
+type BatchResult struct {
+	ID int `json:"id"`
+	Data
+}
+
+type batchItem struct {
+	id        int
+	maLattice string
+	err       error
+}
+
+// batchSentences returns the request's tokenized sentences
+// Sentences are either given as a list, or as a document in Text which
+// is split into sentences by the tokenizer if asked to, and otherwise
+// given a sentence per line with tokens separated by white space
+// Offsets are into the document, or into each sentence of a list
+func batchSentences(request Request) []tokenizer.Sentence {
+	if len(request.Sentences) == 0 && request.Tokenize {
+		return tokenizer.Tokenize(request.Text)
+	}
+	var (
+		sents     = request.Sentences
+		lineStart []int
+	)
+	if len(sents) == 0 {
+		sents = strings.Split(request.Text, "\n")
+		lineStart = make([]int, len(sents))
+		for i := 1; i < len(sents); i++ {
+			lineStart[i] = lineStart[i-1] + utf8.RuneCountInString(sents[i-1]) + 1
+		}
+	}
+	tokenized := make([]tokenizer.Sentence, 0, len(sents))
+	for i, sent := range sents {
+		var tokens tokenizer.Sentence
+		if request.Tokenize {
+			tokens = tokenizer.TokenizeSentence(sent)
+		} else {
+			for _, s := range tokenizer.Pretokenized(sent) {
+				tokens = append(tokens, s...)
+			}
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		if lineStart != nil {
+			for j := range tokens {
+				tokens[j].Start += lineStart[i]
+				tokens[j].End += lineStart[i]
+			}
+		}
+		tokenized = append(tokenized, tokens)
+	}
+	return tokenized
+}
+
+// BatchHandler jointly parses a list of sentences, streaming back
+// one JSON object per line (NDJSON) as each sentence is parsed
+func BatchHandler(resp http.ResponseWriter, req *http.Request) {
+	request := Request{}
+	err := json.NewDecoder(req.Body).Decode(&request)
+	if err != nil {
+		data := Data{Error: NewError(http.StatusBadRequest, "Failed decoding request: %v", err)}
+		respondWithJSON(resp, http.StatusBadRequest, data)
+		return
+	}
+	if err := request.Validate("batch"); err != nil {
+		respondWithParseError(resp, err)
+		return
+	}
+	sents := batchSentences(request)
+	if len(sents) == 0 {
+		respondWithParseError(resp, NewError(http.StatusBadRequest, "Missing input field: sentences"))
+		return
+	}
+	instance, err := jointPool.Acquire(req.Context())
+	if err != nil {
+		respondWithParseError(resp, DeadlineError(err))
+		return
+	}
+	var panicked bool
+	defer func() {
+		if panicked {
+			jointPool.Discard(instance)
+		} else {
+			jointPool.Release(instance)
+		}
+	}()
+
+	var (
+		pending   = make(chan batchItem, len(sents))
+		instances = make(chan interface{}, 2)
+		parsed    = make(chan interface{}, 2)
+		done      = req.Context().Done()
+	)
+	// analyze each sentence and feed it to the parser
+	go func() {
+		defer close(pending)
+		defer close(instances)
+		for i, sent := range sents {
+			select {
+			case <-done:
+				log.Println("Batch request cancelled after", i, "sentences")
+				return
+			default:
+			}
+			lattices, err := HebrewMorphAnalyze(req.Context(), []tokenizer.Sentence{sent})
+			if err != nil {
+				pending <- batchItem{i, "", err}
+				continue
+			}
+			maLattice := request.LatticesToString(lattices)
+			lAmb, err := lattice.Read(strings.NewReader(offsetsOptions.LatticesToString(lattices)), 0)
+			if err != nil {
+				pending <- batchItem{i, maLattice, InputError(err)}
+				continue
+			}
+			predAmbLat, err := latticeSentences(lAmb, app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix)
+			if err != nil {
+				pending <- batchItem{i, maLattice, err}
+				continue
+			}
+			if len(predAmbLat) != 1 {
+				pending <- batchItem{i, maLattice, NewError(http.StatusBadRequest, "Expected a single sentence, found %v", len(predAmbLat))}
+				continue
+			}
+			pending <- batchItem{i, maLattice, nil}
+			instances <- predAmbLat[0]
+		}
+	}()
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Println("Recovered from panic while parsing batch -", r)
+				panicked = true
+				close(parsed)
+				// drain so the analyzer isn't blocked
+				for range instances {
+				}
+			}
+		}()
+		app.ParseStreamContext(req.Context(), instances, parsed, request.Beam(instance.(*search.Beam)))
+	}()
+
+	resp.Header().Set("Content-Type", "application/x-ndjson")
+	resp.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(resp)
+	flusher, canFlush := resp.(http.Flusher)
+	for item := range pending {
+		result := BatchResult{ID: item.id}
+		if item.err != nil {
+			result.Error = AsError(item.err)
+		} else if graph, ok := <-parsed; !ok {
+			result.Error = NewError(http.StatusInternalServerError, "Failed parsing sentence %v", item.id)
+		} else if err, failed := graph.(error); failed {
+			result.MALattice = item.maLattice
+			result.Error = DeadlineError(err)
+		} else {
+			parsedGraphs := []interface{}{graph}
+			mappings := app.GetInstances(parsedGraphs, app.GetJointMDConfig)
+			countProcessed("batch", mappings)
+			result.MALattice = item.maLattice
+			result.MDLattice = request.MappingsToString(mappings)
+			result.DepTree = request.JointTreesToString(parsedGraphs)
+			if request.Structured {
+				result.Sentences = JointStructuredSentences(parsedGraphs)
+			}
+		}
+		if err := encoder.Encode(result); err != nil {
+			log.Println("Failed writing batch result", item.id, "-", err)
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+	}
+	// let the parser finish with whatever the analyzer already sent
+	for range parsed {
+	}
+}
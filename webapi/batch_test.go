@@ -0,0 +1,108 @@
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"yap/alg/search"
+	"yap/app"
+	"yap/nlp/parser/ma"
+	nlp "yap/nlp/types"
+	"yap/nlp/tokenizer"
+	"yap/util"
+)
+
+func token(text string, start, end int) tokenizer.Token {
+	return tokenizer.Token{Text: text, Start: start, End: end}
+}
+
+func TestBatchSentences(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		request  Request
+		expected []tokenizer.Sentence
+	}{
+		{"sentences", Request{Sentences: []string{"גנן גידל  דגן", " ", "בגן ."}}, []tokenizer.Sentence{
+			{token("גנן", 0, 3), token("גידל", 4, 8), token("דגן", 10, 13)},
+			{token("בגן", 0, 3), token(".", 4, 5)},
+		}},
+		// a sentence per line, offsets into the whole text
+		{"text", Request{Text: "גנן גידל\n\nדגן בגן .\n"}, []tokenizer.Sentence{
+			{token("גנן", 0, 3), token("גידל", 4, 8)},
+			{token("דגן", 10, 13), token("בגן", 14, 17), token(".", 18, 19)},
+		}},
+		{"tokenized sentences", Request{Sentences: []string{"גנן גידל דגן.", "בגן, בבוקר"}, Options: Options{Tokenize: true}}, []tokenizer.Sentence{
+			{token("גנן", 0, 3), token("גידל", 4, 8), token("דגן", 9, 12), token(".", 12, 13)},
+			{token("בגן", 0, 3), token(",", 3, 4), token("בבוקר", 5, 10)},
+		}},
+		{"tokenized text", Request{Text: "גנן גידל דגן. בגן", Options: Options{Tokenize: true}}, []tokenizer.Sentence{
+			{token("גנן", 0, 3), token("גידל", 4, 8), token("דגן", 9, 12), token(".", 12, 13)},
+			{token("בגן", 14, 17)},
+		}},
+	} {
+		if sents := batchSentences(test.request); !reflect.DeepEqual(sents, test.expected) {
+			t.Errorf("%v: sentences %v, expected %v", test.name, sents, test.expected)
+		}
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, body, message string
+	}{
+		{"malformed json", `{"sentences": "גנן"}`, "Failed decoding request"},
+		{"invalid options", `{"sentences": ["גנן"], "format": "xml"}`, "Unknown format xml"},
+		{"kbest", `{"sentences": ["גנן"], "kbest": 2}`, "K-best analyses are not supported by the batch endpoint"},
+		{"no sentences", `{"text": "\n \n"}`, "Missing input field: sentences"},
+		{"empty sentences", `{"sentences": ["", "  "]}`, "Missing input field: sentences"},
+	} {
+		code, data := serve(t, BatchHandler, test.body)
+		if code != http.StatusBadRequest {
+			t.Errorf("%v: responded %v, expected %v", test.name, code, http.StatusBadRequest)
+		}
+		if data.Error == nil || !strings.HasPrefix(data.Error.Message, test.message) {
+			t.Errorf("%v: responded with error %+v, expected %q", test.name, data.Error, test.message)
+		}
+	}
+}
+
+func TestBatchPanic(t *testing.T) {
+	defer func(ma, joint *ParserPool) { maPool, jointPool = ma, joint }(maPool, jointPool)
+	defer func(eWord, ePOS, eWPOS, eMorphProp, eMHost, eMSuffix *util.EnumSet) {
+		app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix = eWord, ePOS, eWPOS, eMorphProp, eMHost, eMSuffix
+	}(app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix)
+	app.EWord, app.EPOS, app.EWPOS = util.NewEnumSet(10), util.NewEnumSet(10), util.NewEnumSet(10)
+	app.EMorphProp, app.EMHost, app.EMSuffix = util.NewEnumSet(10), util.NewEnumSet(10), util.NewEnumSet(10)
+	maPool = NewParserPool(1, 0, func() interface{} {
+		return &ma.BGULex{MAType: "spmrl", Prefixes: make(map[string][]nlp.BasicMorphemes), Lex: make(map[string][]nlp.BasicMorphemes)}
+	})
+	// beams without a transition system panic when parsing
+	var beams []*search.Beam
+	jointPool = NewParserPool(1, 0, func() interface{} {
+		beams = append(beams, &search.Beam{})
+		return beams[len(beams)-1]
+	})
+
+	recorder := httptest.NewRecorder()
+	BatchHandler(recorder, httptest.NewRequest("POST", "/yap/heb/batch", strings.NewReader(`{"sentences": ["."]}`)))
+	var result BatchResult
+	if err := json.NewDecoder(recorder.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Error == nil || result.Error.Code != http.StatusInternalServerError {
+		t.Errorf("panicking parser responded with error %+v, expected %v", result.Error, http.StatusInternalServerError)
+	}
+	// the beam the panic may have left corrupt is replaced
+	instance, err := jointPool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(beams) != 2 || instance != beams[1] {
+		t.Errorf("pool holds beam %p of %v created, expected a fresh one", instance, len(beams))
+	}
+}
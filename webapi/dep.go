@@ -1,12 +1,12 @@
 package webapi
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"github.com/gonuts/commander"
 	"log"
+	"net/http"
 	"strings"
-	"sync"
 	"yap/alg/search"
 	"yap/alg/transition"
 	transitionmodel "yap/alg/transition/model"
@@ -16,12 +16,11 @@ import (
 	. "yap/nlp/parser/dependency/transition"
 	nlp "yap/nlp/types"
 	"yap/util"
-	"yap/util/conf"
 )
 
 var (
 	depBeam *search.Beam
-	depLock sync.Mutex
+	depPool *ParserPool
 )
 
 func DepParserInitialize(cmd *commander.Command, args []string) {
@@ -33,16 +32,6 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 	terminalStack = 0
 	arcSystem.AddDefaultOracle()
 	transitionSystem := transition.TransitionSystem(arcSystem)
-	featuresLocation, found := util.LocateFile(app.DepFeaturesFile, app.DEFAULT_CONF_DIRS)
-	if !found {
-		panic(fmt.Sprintf("Dep features not found"))
-	}
-	app.DepFeaturesFile = featuresLocation
-	labelsLocation, found := util.LocateFile(app.DepLabelsFile, app.DEFAULT_CONF_DIRS)
-	if !found {
-		panic(fmt.Sprintf("Dep labels not found"))
-	}
-	app.DepLabelsFile = labelsLocation
 	var (
 		model *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
 	)
@@ -51,12 +40,31 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 		panic(fmt.Sprintf("Dep model not found"))
 	}
 	app.DepModelName = modelLocation
+	// the model's configuration sets the features and labels
+	serialization := loadModel(app.MODEL_DEP, modelLocation)
+	app.ConfigureFromModel(cmd, app.MODEL_DEP, serialization.Config, DepModelFlags)
+	if err := serialization.Config.VerifyArcSystem("eager"); err != nil {
+		panic(err.Error())
+	}
+	config := app.LoadedConfigs[app.MODEL_DEP]
+	if config == nil {
+		featuresLocation, found := util.LocateFile(app.DepFeaturesFile, app.DEFAULT_CONF_DIRS)
+		if !found {
+			panic(fmt.Sprintf("Dep features not found"))
+		}
+		app.DepFeaturesFile = featuresLocation
+		labelsLocation, found := util.LocateFile(app.DepLabelsFile, app.DEFAULT_CONF_DIRS)
+		if !found {
+			panic(fmt.Sprintf("Dep labels not found"))
+		}
+		app.DepLabelsFile = labelsLocation
+	}
 	app.DepConfigOut(modelLocation, &search.Beam{}, transitionSystem)
-	relations, err := conf.ReadFile(labelsLocation)
+	relations, err := config.Relations(app.DepLabelsFile)
 	if err != nil {
-		panic(fmt.Sprintf("Failed reading Dep labels from file: %v", labelsLocation))
+		panic(fmt.Sprintf("Failed reading Dep labels from file: %v", app.DepLabelsFile))
 	}
-	app.SetupDepEnum(relations.Values)
+	app.SetupDepEnum(relations)
 	arcSystem = &ArcEager{
 		ArcStandard: ArcStandard{
 			SHIFT:       app.SH.Value(),
@@ -73,10 +81,11 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 	log.Println()
 	log.Println("Loading features")
 
-	featureSetup, err := transition.LoadFeatureConfFile(featuresLocation)
+	featureSetup, err := config.FeatureSetup(app.DepFeaturesFile)
 	if err != nil {
-		panic(fmt.Sprintf("Failed reading Dep features from file: %v", featuresLocation))
+		panic(fmt.Sprintf("Failed reading Dep features from file: %v", app.DepFeaturesFile))
 	}
+	verifyFeatures(app.MODEL_DEP, serialization, featureSetup)
 	extractor := app.SetupExtractor(featureSetup, []byte("A"))
 	group, _ := extractor.TransTypeGroups['A']
 	formatters := make([]util.Format, len(group.FeatureTemplates))
@@ -85,8 +94,9 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 	}
 
 	log.Println("Found model file", modelLocation, " ... loading model")
-	serialization := app.ReadModel(modelLocation)
-	model.Deserialize(serialization.WeightModel)
+	if err := serialization.LoadWeights(model); err != nil {
+		log.Fatalln("Failed loading", app.MODEL_DEP, "model:", err)
+	}
 	app.DepEWord = serialization.EWord
 	app.DepEPOS = serialization.EPOS
 	app.DepEWPOS = serialization.EWPOS
@@ -116,27 +126,88 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 		ShortTempAgenda:      true,
 		EstimatedTransitions: app.EstimatedBeamTransitions(),
 		ScoredStoreDense:     true,
+		Timeout:              SentenceTimeout,
+		CompleteGreedily:     CompleteGreedily,
+		RoundsObserver:       observeRounds(COMPONENT_DEP),
 	}
+	depPool = NewParserPool(Workers, QueueSize, func() interface{} {
+		// weights, extractor and enum sets are shared between beams
+		beam := *depBeam
+		return &beam
+	})
+}
+
+func DepParseDisambiguatedLattice(input string) (string, error) {
+	graphAsConll, err := DepParse(context.Background(), input, Options{})
+	if err != nil {
+		return "", err
+	}
+	return Options{}.ConllToString(graphAsConll), nil
+}
+
+func DepParse(ctx context.Context, input string, opts Options) ([]interface{}, error) {
+	graphAsConll, _, err := depParse(ctx, input, opts, 1)
+	return graphAsConll, err
+}
+
+// DepParseKBest is DepParse also returning the opts.KBest best trees
+// (conll.Sentence) of each sentence
+func DepParseKBest(ctx context.Context, input string, opts Options) ([]interface{}, [][]scoredAnalysis, error) {
+	return depParse(ctx, input, opts, opts.KBest)
 }
 
-func DepParseDisambiguatedLattice(input string) string {
-	depLock.Lock()
+func depParse(ctx context.Context, input string, opts Options, k int) (graphAsConll []interface{}, kBest [][]scoredAnalysis, err error) {
+	instance, err := depPool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, DeadlineError(err)
+	}
+	defer depPool.Release(instance)
+	defer recoverAs(http.StatusInternalServerError, "parsing disambiguated lattice", &err)
 	log.Println("Reading disambiguated lattice")
 	log.Println("input:\n", input)
 	reader := strings.NewReader(input)
 	lDisamb, lDisambE := lattice.Read(reader, 0)
 	if lDisambE != nil {
-		panic(fmt.Sprintf("Failed reading raw input - %v", lDisamb))
-	}
-	internalSents := lattice.Lattice2SentenceCorpus(lDisamb, app.DepEWord, app.DepEPOS, app.DepEWPOS, app.DepEMorphProp, app.DepEMHost, app.DepEMSuffix)
-	sents := make([]interface{}, len(internalSents))
-	for i, instance := range internalSents {
-		sents[i] = instance.(nlp.LatticeSentence).TaggedSentence()
-	}
-	parsedGraphs := app.Parse(sents, depBeam)
-	graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, app.DepEMHost, app.DepEMSuffix)
-	buf := new(bytes.Buffer)
-	conll.Write(buf, graphAsConll)
-	depLock.Unlock()
-	return buf.String()
+		return nil, nil, InputError(lDisambE)
+	}
+	internalSents, err := latticeSentences(lDisamb, app.DepEWord, app.DepEPOS, app.DepEWPOS, app.DepEMorphProp, app.DepEMHost, app.DepEMSuffix)
+	if err != nil {
+		return nil, nil, err
+	}
+	sents, err := taggedSentences(internalSents)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsedGraphs, kBest, err := parse(ctx, sents, opts.Beam(instance.(*search.Beam)), k)
+	if err != nil {
+		return nil, nil, err
+	}
+	graphAsConll = conll.Graph2ConllCorpus(parsedGraphs, app.DepEMHost, app.DepEMSuffix)
+	setTreeOffsets(graphAsConll, internalSents)
+	for i, analyses := range kBest {
+		for rank, scored := range analyses {
+			tree := conll.Graph2ConllCorpus([]interface{}{scored.analysis}, app.DepEMHost, app.DepEMSuffix)
+			setTreeOffsets(tree, internalSents[i:i+1])
+			kBest[i][rank].analysis = tree[0]
+		}
+	}
+	return graphAsConll, kBest, nil
+}
+
+// setTreeOffsets copies the offsets of the disambiguated lattices'
+// morphemes to the rows of their trees, which are numbered in order
+func setTreeOffsets(graphAsConll []interface{}, lats []interface{}) {
+	for i, sent := range graphAsConll {
+		rows := sent.(conll.Sentence)
+		id := 1
+		for _, lat := range lats[i].(nlp.LatticeSentence) {
+			for _, morph := range lat.Spellouts[0] {
+				if row, exists := rows[id]; exists {
+					row.CharStart, row.CharEnd = morph.CharStart, morph.CharEnd
+					rows[id] = row
+				}
+				id++
+			}
+		}
+	}
 }
@@ -0,0 +1,92 @@
+package webapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"yap/nlp/format/lattice"
+	nlp "yap/nlp/types"
+	"yap/util"
+)
+
+// Error is the JSON error reported in Data.Error
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"` // 1-based line of the offending input
+	Token   string `json:"token,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func NewError(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// InputError reports a failure to read request input as 400 Bad Request,
+// pointing at the offending lattice line and token when known
+func InputError(err error) *Error {
+	apiErr := NewError(http.StatusBadRequest, "Invalid input: %v", err)
+	if parseErr, ok := err.(*lattice.ParseError); ok {
+		apiErr.Line = parseErr.Record + 1
+		if fields := strings.Split(parseErr.Line, "\t"); len(fields) > 2 {
+			apiErr.Token = fields[2]
+		}
+	}
+	return apiErr
+}
+
+// AsError converts any error returned in the request path into an *Error
+func AsError(err error) *Error {
+	if apiErr, ok := err.(*Error); ok {
+		return apiErr
+	}
+	return NewError(http.StatusInternalServerError, "%v", err)
+}
+
+// DeadlineError reports a parse that was cancelled (the client went away)
+// or ran out of its time budget
+func DeadlineError(err error) *Error {
+	switch err {
+	case context.DeadlineExceeded:
+		return NewError(http.StatusGatewayTimeout, "Parsing timed out: %v", err)
+	case context.Canceled:
+		return NewError(http.StatusServiceUnavailable, "Parsing cancelled: %v", err)
+	}
+	return AsError(err)
+}
+
+// recoverAs turns a panic in the deferring function into an *Error
+// with the given code, so a single bad request can't kill the server
+// usage: defer recoverAs(http.StatusInternalServerError, "parsing", &err)
+func recoverAs(code int, stage string, err *error) {
+	if r := recover(); r != nil {
+		log.Println("Recovered from panic while", stage, "-", r)
+		log.Println(string(debug.Stack()))
+		*err = NewError(code, "Failed %v: %v", stage, r)
+	}
+}
+
+// latticeSentences converts read lattices to sentences, reporting
+// malformed lattices (which panic during conversion) as input errors
+func latticeSentences(lats []lattice.Lattice, eWord, ePOS, eWPOS, eMorphProp, eMHost, eMSuffix *util.EnumSet) (sents []interface{}, err error) {
+	defer recoverAs(http.StatusBadRequest, "reading lattices", &err)
+	sents = lattice.Lattice2SentenceCorpus(lats, eWord, ePOS, eWPOS, eMorphProp, eMHost, eMSuffix)
+	return sents, nil
+}
+
+// taggedSentences converts disambiguated lattice sentences to tagged sentences,
+// reporting ambiguous lattices (which panic during conversion) as input errors
+func taggedSentences(lats []interface{}) (sents []interface{}, err error) {
+	defer recoverAs(http.StatusBadRequest, "reading disambiguated lattices", &err)
+	sents = make([]interface{}, len(lats))
+	for i, instance := range lats {
+		sents[i] = instance.(nlp.LatticeSentence).TaggedSentence()
+	}
+	return sents, nil
+}
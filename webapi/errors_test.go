@@ -0,0 +1,92 @@
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"yap/alg/search"
+)
+
+// serve sends a JSON request body to a handler, returning the response
+// code and decoded data
+func serve(t *testing.T, handler http.HandlerFunc, body string) (int, Data) {
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/", strings.NewReader(body)))
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("responded with content type %v, expected application/json", contentType)
+	}
+	var data Data
+	if err := json.NewDecoder(recorder.Body).Decode(&data); err != nil {
+		t.Fatalf("failed decoding response: %v", err)
+	}
+	return recorder.Code, data
+}
+
+func TestHandlerErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		message string
+	}{
+		{"malformed json", HebrewJointHandler, `{"text": `, "Failed decoding request"},
+		{"ma without text", HebrewMorphAnalyzerHandler, `{"text": "  "}`, "Missing input field: text"},
+		{"md without lattice", MorphDisambiguatorHandler, `{}`, "Missing input field: amb_lattice"},
+		{"dep without lattice", DepParserHandler, `{"text": "גנן"}`, "Missing input field: disamb_lattice"},
+		{"pipeline without text", HebrewPipelineHandler, `{}`, "Missing input field: text"},
+		{"joint without text", HebrewJointHandler, `{}`, "Missing input field: text"},
+		{"invalid options", HebrewJointHandler, `{"text": "גנן", "beam": -1}`, "Beam size must be"},
+	} {
+		code, data := serve(t, test.handler, test.body)
+		if code != http.StatusBadRequest {
+			t.Errorf("%v: responded %v, expected %v", test.name, code, http.StatusBadRequest)
+		}
+		if data.Error == nil || data.Error.Code != http.StatusBadRequest || !strings.HasPrefix(data.Error.Message, test.message) {
+			t.Errorf("%v: responded with error %+v, expected %q", test.name, data.Error, test.message)
+		}
+	}
+}
+
+func TestInputError(t *testing.T) {
+	defer func(pool *ParserPool) { mdPool = pool }(mdPool)
+	mdPool = NewParserPool(1, 0, func() interface{} { return &search.Beam{} })
+
+	// the second lattice line has a malformed start field
+	lattice := `0\t1\tגנן\tגנן\tNN\tNN\tgen=M|num=S\t1\nx\t2\tגידל\tגידל\tVB\tVB\t_\t2\n\n`
+	code, data := serve(t, MorphDisambiguatorHandler, `{"amb_lattice": "`+lattice+`"}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("malformed lattice responded %v, expected %v", code, http.StatusBadRequest)
+	}
+	if data.Error == nil || data.Error.Line != 2 || data.Error.Token != "גידל" || !strings.HasPrefix(data.Error.Message, "Invalid input") {
+		t.Errorf("malformed lattice responded with error %+v, expected line 2 token גידל", data.Error)
+	}
+}
+
+func TestDeadlineError(t *testing.T) {
+	for err, code := range map[error]int{
+		context.DeadlineExceeded: http.StatusGatewayTimeout,
+		context.Canceled:         http.StatusServiceUnavailable,
+		errors.New("failed"):     http.StatusInternalServerError,
+		ErrServerBusy:            http.StatusServiceUnavailable,
+	} {
+		if apiErr := DeadlineError(err); apiErr.Code != code {
+			t.Errorf("error %v reported as %v, expected %v", err, apiErr.Code, code)
+		}
+	}
+}
+
+func TestRecoverAs(t *testing.T) {
+	process := func() (err error) {
+		defer recoverAs(http.StatusBadRequest, "reading", &err)
+		panic("bad input")
+	}
+	apiErr, ok := process().(*Error)
+	if !ok || apiErr.Code != http.StatusBadRequest || apiErr.Message != "Failed reading: bad input" {
+		t.Errorf("recovered as %v, expected a %v error", apiErr, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,276 @@
+//go:build grpc
+// +build grpc
+
+package webapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	"yap/webapi/yappb"
+
+	"github.com/gonuts/commander"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+var GRPCPort int
+
+func registerGRPCFlags(cmd *commander.Command) {
+	cmd.Flag.IntVar(&GRPCPort, "grpc_port", 0, "Port to serve gRPC on (see webapi/yappb/yap.proto); 0 = disabled")
+}
+
+// startGRPCServer serves the enabled endpoints over gRPC (if a port is set),
+// returning a function that stops the server
+func startGRPCServer(enabled []Endpoint) func(context.Context) {
+	if GRPCPort == 0 {
+		return nil
+	}
+	addr := net.JoinHostPort(Host, strconv.Itoa(GRPCPort))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalln("Failed listening for gRPC on", addr, "-", err)
+	}
+	var opts []grpc.ServerOption
+	if TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(TLSCertFile, TLSKeyFile)
+		if err != nil {
+			log.Fatalln("Failed loading TLS credentials for gRPC -", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(opts...)
+	yapServer := &grpcServer{enabled: make(map[string]Endpoint, len(enabled))}
+	for _, endpoint := range enabled {
+		yapServer.enabled[endpoint.Name] = endpoint
+	}
+	yappb.RegisterYapServer(server, yapServer)
+	reflection.Register(server)
+	go func() {
+		log.Println("Listening (gRPC) on", addr)
+		if err := server.Serve(listener); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	return func(ctx context.Context) {
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			log.Println("gRPC shutdown timed out, closing open streams")
+			server.Stop()
+		}
+	}
+}
+
+// grpcServer implements the Yap gRPC service with the same request
+// processing as the REST endpoints
+type grpcServer struct {
+	yappb.UnimplementedYapServer
+	enabled map[string]Endpoint
+}
+
+type sentenceStream interface {
+	Context() context.Context
+	Recv() (*yappb.SentenceRequest, error)
+	Send(*yappb.SentenceResponse) error
+}
+
+func (s *grpcServer) Analyze(ctx context.Context, req *yappb.TextRequest) (*yappb.Response, error) {
+	request := Request{Text: req.Text, Structured: req.Structured, Options: optionsFromProto(req.Options)}
+	return s.serve(ctx, "ma", request, AnalyzeText)
+}
+
+func (s *grpcServer) Disambiguate(ctx context.Context, req *yappb.LatticeRequest) (*yappb.Response, error) {
+	request := Request{AmbLattice: req.Lattice, Structured: req.Structured, Options: optionsFromProto(req.Options)}
+	return s.serve(ctx, "md", request, DisambiguateLattice)
+}
+
+func (s *grpcServer) DepParse(ctx context.Context, req *yappb.LatticeRequest) (*yappb.Response, error) {
+	request := Request{DisambLattice: req.Lattice, Structured: req.Structured, Options: optionsFromProto(req.Options)}
+	return s.serve(ctx, "dep", request, ParseLattice)
+}
+
+func (s *grpcServer) Pipeline(ctx context.Context, req *yappb.TextRequest) (*yappb.Response, error) {
+	request := Request{Text: req.Text, Structured: req.Structured, Options: optionsFromProto(req.Options)}
+	return s.serve(ctx, "pipeline", request, PipelineText)
+}
+
+func (s *grpcServer) Joint(ctx context.Context, req *yappb.TextRequest) (*yappb.Response, error) {
+	request := Request{Text: req.Text, Structured: req.Structured, Options: optionsFromProto(req.Options)}
+	return s.serve(ctx, "joint", request, JointText)
+}
+
+func (s *grpcServer) AnalyzeStream(stream yappb.Yap_AnalyzeStreamServer) error {
+	return s.stream(stream, "ma", AnalyzeText)
+}
+
+func (s *grpcServer) PipelineStream(stream yappb.Yap_PipelineStreamServer) error {
+	return s.stream(stream, "pipeline", PipelineText)
+}
+
+func (s *grpcServer) JointStream(stream yappb.Yap_JointStreamServer) error {
+	return s.stream(stream, "joint", JointText)
+}
+
+// ready fails unless the endpoint is enabled and its components are loaded
+func (s *grpcServer) ready(name string) error {
+	endpoint, exists := s.enabled[name]
+	if !exists {
+		return status.Errorf(codes.Unimplemented, "Endpoint %v is not enabled", name)
+	}
+	if !ComponentsLoaded(endpoint.Components...) {
+		return status.Error(codes.Unavailable, "Server is not ready, still loading models")
+	}
+	return nil
+}
+
+func (s *grpcServer) serve(ctx context.Context, name string, request Request, process func(context.Context, Request) (Data, error)) (*yappb.Response, error) {
+	start := time.Now()
+	metricsName := "grpc_" + name
+	if err := s.ready(name); err != nil {
+		requestsTotal.Add(1, metricsName, strconv.Itoa(http.StatusServiceUnavailable))
+		return nil, err
+	}
+	data, err := process(ctx, request)
+	requestDuration.Observe(time.Since(start).Seconds(), metricsName)
+	if err != nil {
+		apiErr := AsError(err)
+		requestsTotal.Add(1, metricsName, strconv.Itoa(apiErr.Code))
+		return nil, grpcError(apiErr)
+	}
+	requestsTotal.Add(1, metricsName, strconv.Itoa(http.StatusOK))
+	return responseProto(data), nil
+}
+
+// stream processes each sentence received on the stream as a request,
+// sending back its response (or error) before reading the next one
+func (s *grpcServer) stream(stream sentenceStream, name string, process func(context.Context, Request) (Data, error)) error {
+	if err := s.ready(name); err != nil {
+		return err
+	}
+	for {
+		sentReq, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		request := Request{Text: sentReq.Text, Structured: sentReq.Structured, Options: optionsFromProto(sentReq.Options)}
+		sentResp := &yappb.SentenceResponse{Id: sentReq.Id}
+		data, err := process(stream.Context(), request)
+		if err != nil {
+			sentResp.Error = errorProto(AsError(err))
+		} else {
+			sentResp.Response = responseProto(data)
+		}
+		if err := stream.Send(sentResp); err != nil {
+			return err
+		}
+	}
+}
+
+// grpcError converts an *Error to the gRPC status with the equivalent code
+func grpcError(apiErr *Error) error {
+	code := codes.Internal
+	switch apiErr.Code {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	}
+	message := apiErr.Message
+	if apiErr.Line > 0 {
+		message = fmt.Sprintf("%v (line %v, token %v)", message, apiErr.Line, apiErr.Token)
+	}
+	return status.Error(code, message)
+}
+
+func optionsFromProto(opts *yappb.Options) Options {
+	if opts == nil {
+		return Options{}
+	}
+	return Options{
+		BeamSize:      int(opts.Beam),
+		JointStrategy: opts.JointStrategy,
+		Format:        opts.Format,
+		StripLemmas:   opts.StripLemmas,
+		Tokenize:      opts.Tokenize,
+		Offsets:       opts.Offsets,
+		KBest:         int(opts.Kbest),
+	}
+}
+
+func errorProto(apiErr *Error) *yappb.Error {
+	return &yappb.Error{
+		Code:    int32(apiErr.Code),
+		Message: apiErr.Message,
+		Line:    int32(apiErr.Line),
+		Token:   apiErr.Token,
+	}
+}
+
+func responseProto(data Data) *yappb.Response {
+	resp := &yappb.Response{
+		MaLattice: data.MALattice,
+		MdLattice: data.MDLattice,
+		DepTree:   data.DepTree,
+		Sentences: make([]*yappb.Sentence, len(data.Sentences)),
+	}
+	for i, sent := range data.Sentences {
+		pbSent := &yappb.Sentence{Tokens: make([]*yappb.Token, len(sent.Tokens))}
+		for j, token := range sent.Tokens {
+			pbToken := &yappb.Token{
+				Id:        int32(token.ID),
+				Form:      token.Form,
+				Morphemes: make([]*yappb.Morpheme, len(token.Morphemes)),
+				Start:     int32(token.Start),
+				End:       int32(token.End),
+			}
+			for k, morph := range token.Morphemes {
+				pbToken.Morphemes[k] = &yappb.Morpheme{
+					Id:       int32(morph.ID),
+					Form:     morph.Form,
+					Lemma:    morph.Lemma,
+					Cpos:     morph.CPOS,
+					Pos:      morph.POS,
+					Features: morph.Features,
+					Head:     int32(morph.Head),
+					Relation: morph.Relation,
+					Start:    int32(morph.Start),
+					End:      int32(morph.End),
+				}
+			}
+			pbSent.Tokens[j] = pbToken
+		}
+		resp.Sentences[i] = pbSent
+	}
+	for _, analyses := range data.KBest {
+		pbAnalyses := &yappb.SentenceAnalyses{Analyses: make([]*yappb.Analysis, len(analyses))}
+		for rank, analysis := range analyses {
+			pbAnalyses.Analyses[rank] = &yappb.Analysis{
+				Rank:      int32(analysis.Rank),
+				Score:     analysis.Score,
+				MdLattice: analysis.MDLattice,
+				DepTree:   analysis.DepTree,
+			}
+		}
+		resp.Kbest = append(resp.Kbest, pbAnalyses)
+	}
+	return resp
+}
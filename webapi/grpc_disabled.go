@@ -0,0 +1,19 @@
+//go:build !grpc
+// +build !grpc
+
+package webapi
+
+import (
+	"context"
+
+	"github.com/gonuts/commander"
+)
+
+// gRPC support is only built with -tags grpc (see grpc.go), as it
+// requires the gRPC and protobuf packages and a recent Go version
+
+func registerGRPCFlags(cmd *commander.Command) {}
+
+func startGRPCServer(enabled []Endpoint) func(context.Context) {
+	return nil
+}
@@ -0,0 +1,59 @@
+//go:build grpc
+// +build grpc
+
+package webapi
+
+import (
+	"testing"
+	"yap/webapi/yappb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOptionsFromProto(t *testing.T) {
+	opts := optionsFromProto(&yappb.Options{Beam: 8, Format: "ud", StripLemmas: true, Offsets: true, Kbest: 3})
+	expected := Options{BeamSize: 8, Format: "ud", StripLemmas: true, Offsets: true, KBest: 3}
+	if opts != expected {
+		t.Errorf("options %+v, expected %+v", opts, expected)
+	}
+	if opts := optionsFromProto(nil); opts != (Options{}) {
+		t.Errorf("options %+v of no options, expected defaults", opts)
+	}
+}
+
+func TestResponseProtoKBest(t *testing.T) {
+	data := Data{
+		MDLattice: "md",
+		KBest: [][]Analysis{
+			{{Rank: 1, Score: 2.5, MDLattice: "best"}, {Rank: 2, Score: -1, MDLattice: "second"}},
+			{{Rank: 1, Score: 3, DepTree: "tree"}},
+		},
+	}
+	// through the wire, with the generated descriptor
+	wire, err := proto.Marshal(responseProto(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &yappb.Response{}
+	if err := proto.Unmarshal(wire, resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.MdLattice != "md" || len(resp.Kbest) != len(data.KBest) {
+		t.Fatalf("response %v, expected the k best of %v sentences", resp, len(data.KBest))
+	}
+	for i, analyses := range data.KBest {
+		if len(resp.Kbest[i].Analyses) != len(analyses) {
+			t.Errorf("sentence %v: %v analyses, expected %v", i, len(resp.Kbest[i].Analyses), len(analyses))
+			continue
+		}
+		for rank, analysis := range analyses {
+			pb := resp.Kbest[i].Analyses[rank]
+			if int(pb.Rank) != analysis.Rank || pb.Score != analysis.Score || pb.MdLattice != analysis.MDLattice || pb.DepTree != analysis.DepTree {
+				t.Errorf("sentence %v analysis %v: %v, expected %+v", i, rank, pb, analysis)
+			}
+		}
+	}
+	if resp := responseProto(Data{}); resp.Kbest != nil {
+		t.Errorf("k best %v without kbest", resp.Kbest)
+	}
+}
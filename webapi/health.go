@@ -0,0 +1,98 @@
+package webapi
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	COMPONENT_MA    = "ma"
+	COMPONENT_MD    = "md"
+	COMPONENT_DEP   = "dep"
+	COMPONENT_JOINT = "joint"
+)
+
+var (
+	componentsLock sync.RWMutex
+	components     = make(map[string]bool)
+	shuttingDown   bool
+)
+
+type Health struct {
+	Status     string          `json:"status"`
+	Ready      bool            `json:"ready"`
+	Components map[string]bool `json:"components,omitempty"`
+}
+
+// RegisterComponent adds a component that must finish loading before the server is ready
+func RegisterComponent(name string) {
+	componentsLock.Lock()
+	components[name] = false
+	componentsLock.Unlock()
+}
+
+func SetComponentLoaded(name string) {
+	componentsLock.Lock()
+	components[name] = true
+	componentsLock.Unlock()
+	componentLoadTime.Set(float64(time.Now().Unix()), name)
+}
+
+func SetShuttingDown() {
+	componentsLock.Lock()
+	shuttingDown = true
+	componentsLock.Unlock()
+}
+
+func ComponentsLoaded(names ...string) bool {
+	componentsLock.RLock()
+	defer componentsLock.RUnlock()
+	for _, name := range names {
+		if !components[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func health() Health {
+	componentsLock.RLock()
+	defer componentsLock.RUnlock()
+	h := Health{Status: "ok", Ready: !shuttingDown, Components: make(map[string]bool, len(components))}
+	for name, loaded := range components {
+		h.Components[name] = loaded
+		h.Ready = h.Ready && loaded
+	}
+	if shuttingDown {
+		h.Status = "shutting down"
+	}
+	return h
+}
+
+// HealthHandler reports liveness; it succeeds as long as the server is up
+func HealthHandler(resp http.ResponseWriter, req *http.Request) {
+	respondWithJSON(resp, http.StatusOK, health())
+}
+
+// ReadyHandler succeeds once the lexicon and all models have been loaded,
+// and fails again once the server starts shutting down
+func ReadyHandler(resp http.ResponseWriter, req *http.Request) {
+	h := health()
+	code := http.StatusOK
+	if !h.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	respondWithJSON(resp, code, h)
+}
+
+// requireComponents responds with 503 until the given components are loaded
+func requireComponents(handler http.HandlerFunc, names ...string) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if !ComponentsLoaded(names...) {
+			respondWithParseError(resp, NewError(http.StatusServiceUnavailable, "Server is not ready, still loading models"))
+			return
+		}
+		handler(resp, req)
+	}
+}
@@ -0,0 +1,67 @@
+package webapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func getHealth(t *testing.T, handler http.HandlerFunc) (int, Health) {
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/", nil))
+	var h Health
+	if err := json.NewDecoder(recorder.Body).Decode(&h); err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, h
+}
+
+func TestReady(t *testing.T) {
+	defer func() {
+		components, shuttingDown = make(map[string]bool), false
+	}()
+	components, shuttingDown = make(map[string]bool), false
+	RegisterComponent(COMPONENT_MA)
+	RegisterComponent(COMPONENT_JOINT)
+	parsed := false
+	handler := requireComponents(func(resp http.ResponseWriter, req *http.Request) {
+		parsed = true
+		respondWithJSON(resp, http.StatusOK, Data{})
+	}, COMPONENT_MA, COMPONENT_JOINT)
+
+	for _, step := range []struct {
+		name       string
+		set        func()
+		readyCode  int
+		health     Health
+		handlerRun bool
+	}{
+		{"loading", func() {}, http.StatusServiceUnavailable,
+			Health{"ok", false, map[string]bool{COMPONENT_MA: false, COMPONENT_JOINT: false}}, false},
+		{"partly loaded", func() { SetComponentLoaded(COMPONENT_MA) }, http.StatusServiceUnavailable,
+			Health{"ok", false, map[string]bool{COMPONENT_MA: true, COMPONENT_JOINT: false}}, false},
+		{"loaded", func() { SetComponentLoaded(COMPONENT_JOINT) }, http.StatusOK,
+			Health{"ok", true, map[string]bool{COMPONENT_MA: true, COMPONENT_JOINT: true}}, true},
+		{"shutting down", SetShuttingDown, http.StatusServiceUnavailable,
+			Health{"shutting down", false, map[string]bool{COMPONENT_MA: true, COMPONENT_JOINT: true}}, true},
+	} {
+		step.set()
+		// the server is alive throughout
+		if code, h := getHealth(t, HealthHandler); code != http.StatusOK || !reflect.DeepEqual(h, step.health) {
+			t.Errorf("%v: health %v %+v, expected %v %+v", step.name, code, h, http.StatusOK, step.health)
+		}
+		if code, h := getHealth(t, ReadyHandler); code != step.readyCode || !reflect.DeepEqual(h, step.health) {
+			t.Errorf("%v: ready %v %+v, expected %v %+v", step.name, code, h, step.readyCode, step.health)
+		}
+		parsed = false
+		code, data := serve(t, handler, `{}`)
+		if parsed != step.handlerRun {
+			t.Errorf("%v: handler run %v, expected %v", step.name, parsed, step.handlerRun)
+		}
+		if !step.handlerRun && (code != http.StatusServiceUnavailable || data.Error == nil) {
+			t.Errorf("%v: responded %v %+v before loading, expected %v", step.name, code, data.Error, http.StatusServiceUnavailable)
+		}
+	}
+}
@@ -1,24 +1,22 @@
 package webapi
 
 import (
+	"context"
 	"yap/nlp/format/lattice"
-	"yap/nlp/format/raw"
 	"log"
 	"fmt"
 	nlp "yap/nlp/types"
 	"yap/util"
 	"yap/nlp/parser/ma"
 	"yap/app"
-	"strings"
-	"io"
+	"yap/nlp/tokenizer"
+	"net/http"
 	"github.com/gonuts/commander"
-	"bytes"
 	"yap/nlp/parser/xliter8"
-	"sync"
 )
 
 var (
-	maLock sync.Mutex
+	maPool *ParserPool
 	maHebrew xliter8.Interface
 	maData *ma.BGULex
 )
@@ -44,37 +42,49 @@ func HebrewMorphAnalyazerInitialize(cmd *commander.Command, args []string) {
 	log.Println()
 	maData.AlwaysNNP = app.HebMaAlwaysnnp
 	maData.LogOOV = app.HebMaShowoov
+	maPool = NewParserPool(Workers, QueueSize, func() interface{} {
+		// the lexicon and prefixes are shared, only the stats are per instance
+		maInstance := *maData
+		return &maInstance
+	})
+}
 
+func HebrewMorphAnalyzeRawSentences(input string) (string, error) {
+	output, err := HebrewMorphAnalyze(context.Background(), tokenizer.Pretokenized(input))
+	if err != nil {
+		return "", err
+	}
+	return Options{}.LatticesToString(output), nil
 }
 
-func HebrewMorphAnalyzeRawSentences(input string) string {
-	maLock.Lock()
-	var (
-		reader io.Reader
-		sents []nlp.BasicSentence
-		err error
-	)
-	reader = strings.NewReader(input)
-	sents, err = raw.Read(reader, 0)
+// HebrewMorphAnalyze analyzes tokenized sentences, setting the offsets
+// of each token and its morphemes from the tokenizer's offsets
+func HebrewMorphAnalyze(ctx context.Context, sents []tokenizer.Sentence) (output []lattice.Lattice, err error) {
+	instance, err := maPool.Acquire(ctx)
 	if err != nil {
-		panic(fmt.Sprintf("Failed reading raw input - %v", err))
+		return nil, DeadlineError(err)
 	}
+	defer maPool.Release(instance)
+	defer recoverAs(http.StatusInternalServerError, "analyzing raw input", &err)
+	maInstance := instance.(*ma.BGULex)
 	log.Println("Running Hebrew Morphological Analysis")
-	log.Println("input:\n",input)
+	log.Println("input:\n", tokenizer.Raw(sents))
 	stats := new(ma.AnalyzeStats)
 	stats.Init()
-	maData.Stats = stats
+	maInstance.Stats = stats
 	//prefix := log.Prefix()
 	lattices := make([]nlp.LatticeSentence, len(sents))
 	oovInd := make([]interface{}, len(sents))
 	for i, sent := range sents {
 		//log.SetPrefix(fmt.Sprintf("%v graph# %v ", prefix, i))
-		lattices[i], oovInd[i] = maData.Analyze(sent.Tokens())
+		lattices[i], oovInd[i] = maInstance.Analyze(sent.Tokens())
+		for j, token := range sent {
+			lattices[i][j].SetOffsets(token.Start, token.End)
+		}
 	}
 	log.Println()
-	output := lattice.Sentence2LatticeCorpus(lattices, maHebrew)
-	buf := new(bytes.Buffer)
-	err = lattice.Write(buf, output)
-	maLock.Unlock()
-	return buf.String()
+	maTokensTotal.Add(float64(stats.TotalTokens))
+	maOOVTokensTotal.Add(float64(stats.OOVTokens))
+	output = lattice.Sentence2LatticeCorpus(lattices, maHebrew)
+	return output, nil
 }
@@ -1,12 +1,13 @@
 package webapi
 
 import (
+	"context"
 	"log"
-	"yap/nlp/format/conll"
+	"net/http"
 	"yap/nlp/parser/disambig"
 	"yap/nlp/parser/joint"
 	"yap/alg/search"
-	"yap/util/conf"
+	"github.com/gonuts/commander"
 	"yap/nlp/format/lattice"
 	nlp "yap/nlp/types"
 	transitionmodel "yap/alg/transition/model"
@@ -15,11 +16,9 @@ import (
 	"yap/alg/transition"
 	"fmt"
 	"bytes"
-	"yap/nlp/format/mapping"
 	"yap/nlp/format/segmentation"
 	"yap/util"
 	"strings"
-	"sync"
 )
 
 var (
@@ -29,10 +28,40 @@ var (
 	model *transitionmodel.AvgMatrixSparse
 	terminalStack int
 	paramFunc nlp.MDParam
-	jointLock sync.Mutex
+	jointPool *ParserPool
 )
 
-func JointParserInitialize() {
+func JointParserInitialize(cmd *commander.Command, args []string) {
+	if !app.VerifyExists(app.JointModelFile) {
+		modelLocation, found := util.LocateFile(app.JointModelFile, app.DEFAULT_MODEL_DIRS)
+		if !found {
+			panic(fmt.Sprintf("Joint model not found"))
+		}
+		app.JointModelFile = modelLocation
+	}
+	// the model's configuration sets the features, labels and strategies
+	serialization := loadModel(app.MODEL_JOINT, app.JointModelFile)
+	app.ConfigureFromModel(cmd, app.MODEL_JOINT, serialization.Config, JointModelFlags)
+	if err := serialization.Config.VerifyArcSystem("eager"); err != nil {
+		panic(err.Error())
+	}
+	config := app.LoadedConfigs[app.MODEL_JOINT]
+	if config == nil {
+		if !app.VerifyExists(app.JointFeaturesFile) {
+			featuresLocation, found := util.LocateFile(app.JointFeaturesFile, app.DEFAULT_CONF_DIRS)
+			if !found {
+				panic(fmt.Sprintf("Joint features not found"))
+			}
+			app.JointFeaturesFile = featuresLocation
+		}
+		if !app.VerifyExists(app.DepLabelsFile) {
+			labelsLocation, found := util.LocateFile(app.DepLabelsFile, app.DEFAULT_CONF_DIRS)
+			if !found {
+				panic(fmt.Sprintf("Dep labels not found"))
+			}
+			app.DepLabelsFile = labelsLocation
+		}
+	}
 	paramFunc, exists := nlp.MDParams[app.MdParamFuncName]
 	if !exists {
 		log.Fatalln("Param Func", app.MdParamFuncName, "does not exist")
@@ -54,36 +83,15 @@ func JointParserInitialize() {
 	jointTrans.AddDefaultOracle()
 	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = app.OracleStrategy
 	transitionSystem = transition.TransitionSystem(jointTrans)
-	if !app.VerifyExists(app.JointFeaturesFile) {
-		featuresLocation, found := util.LocateFile(app.JointFeaturesFile, app.DEFAULT_CONF_DIRS)
-		if !found {
-			panic(fmt.Sprintf("Joint features not found"))
-		}
-		app.JointFeaturesFile = featuresLocation
-	}
-	if !app.VerifyExists(app.DepLabelsFile) {
-		labelsLocation, found := util.LocateFile(app.DepLabelsFile, app.DEFAULT_CONF_DIRS)
-		if !found {
-			panic(fmt.Sprintf("Dep labels not found"))
-		}
-		app.DepLabelsFile = labelsLocation
-	}
-	if !app.VerifyExists(app.JointModelFile) {
-		modelLocation, found := util.LocateFile(app.JointModelFile, app.DEFAULT_MODEL_DIRS)
-		if !found {
-			panic(fmt.Sprintf("Joint model not found"))
-		}
-		app.JointModelFile = modelLocation
-	}
 	confBeam := &search.Beam{}
 	confBeam.Align = app.AlignBeam
 	confBeam.Averaged = app.AverageScores
 	app.JointConfigOut(app.JointModelFile, confBeam, transitionSystem)
-	relations, err := conf.ReadFile(app.DepLabelsFile)
+	relations, err := config.Relations(app.DepLabelsFile)
 	if err != nil {
 		panic(fmt.Sprintf("Joint labels not found"))
 	}
-	app.SetupEnum(relations.Values)
+	app.SetupEnum(relations)
 	arcSystem = &ArcEager{
 		ArcStandard: ArcStandard{
 			SHIFT:       app.SH.Value(),
@@ -101,19 +109,18 @@ func JointParserInitialize() {
 	mdTrans.Transitions = app.ETrans
 	mdTrans.UsePOP = app.UsePOP
 	mdTrans.POP = app.POP
-	disambig.UsePOP = app.UsePOP
-	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
-	disambig.LEMMAS = !lattice.IGNORE_LEMMA
+	mdTrans.IgnoreLemmas = lattice.IGNORE_LEMMA
 	mdTrans.AddDefaultOracle()
 	jointTrans.MDTransition = app.MD
 	jointTrans.JointStrategy = app.JointStrategy
 	jointTrans.AddDefaultOracle()
 	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = app.OracleStrategy
 	transitionSystem = transition.TransitionSystem(jointTrans)
-	featureSetup, err := transition.LoadFeatureConfFile(app.JointFeaturesFile)
+	featureSetup, err := config.FeatureSetup(app.JointFeaturesFile)
 	if err != nil {
 		panic(fmt.Sprintf("Joint features not found"))
 	}
+	verifyFeatures(app.MODEL_JOINT, serialization, featureSetup)
 	groups := []byte("MPLA")
 	extractor = app.SetupExtractor(featureSetup, groups)
 	log.Println()
@@ -121,9 +128,10 @@ func JointParserInitialize() {
 	log.Println()
 
 	log.Println("Found model file", app.JointModelFile, " ... loading model")
-	serialization := app.ReadModel(app.JointModelFile)
 	model = &transitionmodel.AvgMatrixSparse{}
-	model.Deserialize(serialization.WeightModel)
+	if err := serialization.LoadWeights(model); err != nil {
+		log.Fatalln("Failed loading", app.MODEL_JOINT, "model:", err)
+	}
 	app.EWord = serialization.EWord
 	app.EPOS = serialization.EPOS
 	app.EWPOS = serialization.EWPOS
@@ -150,25 +158,30 @@ func JointParserInitialize() {
 	mdTrans.Transitions = app.ETrans
 	mdTrans.UsePOP = app.UsePOP
 	mdTrans.POP = app.POP
-	disambig.UsePOP = app.UsePOP
-	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
-	disambig.LEMMAS = !lattice.IGNORE_LEMMA
+	mdTrans.IgnoreLemmas = lattice.IGNORE_LEMMA
 	mdTrans.AddDefaultOracle()
 	jointTrans.MDTransition = app.MD
 	jointTrans.JointStrategy = app.JointStrategy
 	transitionSystem = transition.TransitionSystem(jointTrans)
+	jointPool = NewParserPool(Workers, QueueSize, func() interface{} {
+		return newJointBeam()
+	})
 }
 
-func JointParseAmbiguousLattices(input string) (string, string, string) {
-	jointLock.Lock()
-	log.Println("Reading ambiguous lattices")
-	log.Println("input:\n",input)
-	reader := strings.NewReader(input)
-	lAmb, lAmbE := lattice.Read(reader, 0)
-	if lAmbE != nil {
-		panic(fmt.Sprintf("Failed reading raw input - %v", lAmbE))
+func JointParseAmbiguousLattices(input string) (string, string, string, error) {
+	parsedGraphs, err := JointParse(context.Background(), input, Options{})
+	if err != nil {
+		return "", "", "", err
 	}
-	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix)
+	conllDepOut := Options{}.JointTreesToString(parsedGraphs)
+	mappingMdOut := Options{}.MappingsToString(app.GetInstances(parsedGraphs, app.GetJointMDConfig))
+	buf := new(bytes.Buffer)
+	segmentation.Write(buf, parsedGraphs)
+	segmentationMdOut := buf.String()
+	return conllDepOut, mappingMdOut, segmentationMdOut, nil
+}
+
+func newJointBeam() *search.Beam {
 	conf := &joint.JointConfig{
 		SimpleConfiguration: SimpleConfiguration{
 			EWord: app.EWord,
@@ -186,6 +199,8 @@ func JointParseAmbiguousLattices(input string) (string, string, string) {
 			POP: app.POP,
 			Transitions: app.ETrans,
 			ParamFunc: paramFunc,
+			UsePOP: app.UsePOP,
+			SwitchFormLemma: !lattice.IGNORE_LEMMA,
 		},
 		MDTrans: app.MD,
 	}
@@ -197,20 +212,43 @@ func JointParseAmbiguousLattices(input string) (string, string, string) {
 		ConcurrentExec: app.ConcurrentBeam,
 		Transitions: app.ETrans,
 		EstimatedTransitions: 1000, // chosen by random dice roll
+		Timeout: SentenceTimeout,
+		CompleteGreedily: CompleteGreedily,
+		RoundsObserver: observeRounds(COMPONENT_JOINT),
 	}
 	beam.Model = model
 	beam.ShortTempAgenda = true
-	parsedGraphs := app.Parse(predAmbLat, beam)
-	graphAsConll := conll.MorphGraph2ConllCorpus(parsedGraphs)
-	buf1 := new(bytes.Buffer)
-	conll.Write(buf1, graphAsConll)
-	conllDepOut := buf1.String()
-	buf2 := new(bytes.Buffer)
-	mapping.Write(buf2, app.GetInstances(parsedGraphs, app.GetJointMDConfig))
-	mappingMdOut := buf2.String()
-	buf3 := new(bytes.Buffer)
-	segmentation.Write(buf3, parsedGraphs)
-	segmentationMdOut := buf3.String()
-	jointLock.Unlock()
-	return conllDepOut, mappingMdOut, segmentationMdOut
+	return beam
+}
+
+func JointParse(ctx context.Context, input string, opts Options) ([]interface{}, error) {
+	parsedGraphs, _, err := jointParse(ctx, input, opts, 1)
+	return parsedGraphs, err
+}
+
+// JointParseKBest is JointParse also returning the opts.KBest best
+// parsed graphs of each sentence
+func JointParseKBest(ctx context.Context, input string, opts Options) ([]interface{}, [][]scoredAnalysis, error) {
+	return jointParse(ctx, input, opts, opts.KBest)
+}
+
+func jointParse(ctx context.Context, input string, opts Options, k int) (parsedGraphs []interface{}, kBest [][]scoredAnalysis, err error) {
+	instance, err := jointPool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, DeadlineError(err)
+	}
+	defer jointPool.Release(instance)
+	defer recoverAs(http.StatusInternalServerError, "parsing ambiguous lattices", &err)
+	log.Println("Reading ambiguous lattices")
+	log.Println("input:\n",input)
+	reader := strings.NewReader(input)
+	lAmb, lAmbE := lattice.Read(reader, 0)
+	if lAmbE != nil {
+		return nil, nil, InputError(lAmbE)
+	}
+	predAmbLat, err := latticeSentences(lAmb, app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parse(ctx, predAmbLat, opts.Beam(instance.(*search.Beam)), k)
 }
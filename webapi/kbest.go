@@ -0,0 +1,65 @@
+package webapi
+
+import (
+	"context"
+	"yap/alg/search"
+	"yap/app"
+)
+
+// Analysis is one of the k best analyses of a sentence (see Options.KBest)
+type Analysis struct {
+	Rank      int     `json:"rank"`
+	Score     float64 `json:"score"`
+	MDLattice string  `json:"md_lattice,omitempty"`
+	DepTree   string  `json:"dep_tree,omitempty"`
+}
+
+// scoredAnalysis is one of the k best analyses of a sentence: a mapping
+// (md), a tree (conll.Sentence, dep) or a jointly parsed graph (joint)
+type scoredAnalysis struct {
+	analysis interface{}
+	score    float64
+}
+
+// parse parses the instances, returning the best parse of each and, if k
+// is more than 1, its k best parses
+func parse(ctx context.Context, instances []interface{}, beam *search.Beam, k int) (parsed []interface{}, kBest [][]scoredAnalysis, err error) {
+	if k < 2 {
+		parsed, err = app.ParseContext(ctx, instances, beam)
+		if err != nil {
+			return nil, nil, DeadlineError(err)
+		}
+		return parsed, nil, nil
+	}
+	parses, err := app.ParseKBestContext(ctx, instances, beam, k)
+	if err != nil {
+		return nil, nil, DeadlineError(err)
+	}
+	parsed = make([]interface{}, len(parses))
+	kBest = make([][]scoredAnalysis, len(parses))
+	for i, sentParses := range parses {
+		parsed[i] = sentParses[0].C
+		kBest[i] = make([]scoredAnalysis, len(sentParses))
+		for rank, sentParse := range sentParses {
+			kBest[i][rank] = scoredAnalysis{sentParse.C, sentParse.Score}
+		}
+	}
+	return parsed, kBest, nil
+}
+
+// kBestAnalyses writes the k best analyses of each sentence with write
+func kBestAnalyses(kBest [][]scoredAnalysis, write func(analysis []interface{}) Analysis) [][]Analysis {
+	if kBest == nil {
+		return nil
+	}
+	analyses := make([][]Analysis, len(kBest))
+	for i, sentAnalyses := range kBest {
+		analyses[i] = make([]Analysis, len(sentAnalyses))
+		for rank, scored := range sentAnalyses {
+			analysis := write([]interface{}{scored.analysis})
+			analysis.Rank, analysis.Score = rank+1, scored.score
+			analyses[i][rank] = analysis
+		}
+	}
+	return analyses
+}
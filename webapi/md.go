@@ -1,18 +1,17 @@
 package webapi
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"github.com/gonuts/commander"
 	"log"
+	"net/http"
 	"strings"
-	"sync"
 	"yap/alg/search"
 	"yap/alg/transition"
 	transitionmodel "yap/alg/transition/model"
 	"yap/app"
 	"yap/nlp/format/lattice"
-	"yap/nlp/format/mapping"
 	"yap/nlp/parser/disambig"
 	nlp "yap/nlp/types"
 	"yap/util"
@@ -20,53 +19,62 @@ import (
 
 var (
 	mdBeam *search.Beam
-	mdLock sync.Mutex
+	mdPool *ParserPool
 )
 
 func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
-	paramFunc, exists := nlp.MDParams[app.MdParamFuncName]
-	if !exists {
-		panic(fmt.Sprintf("MD param func %v doesn't exist", app.MdParamFuncName))
-	}
 	var (
 		mdTrans transition.TransitionSystem
 		model   *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
 	)
-	mdTrans = &disambig.MDTrans{
-		ParamFunc: paramFunc,
-		UsePOP:    app.UsePOP,
-	}
-	disambig.UsePOP = app.UsePOP
-	transitionSystem := transition.TransitionSystem(mdTrans)
-	featuresLocation, found := util.LocateFile(app.MdFeaturesFile, app.DEFAULT_CONF_DIRS)
-	if !found {
-		panic(fmt.Sprintf("MD features not found"))
-	}
-	app.MdFeaturesFile = featuresLocation
 	modelLocation, found := util.LocateFile(app.MdModelName, app.DEFAULT_MODEL_DIRS)
 	if !found {
 		panic(fmt.Sprintf("MD model not found"))
 	}
 	app.MdModelName = modelLocation
+	// the model's configuration sets the features, param func and pop
+	serialization := loadModel(app.MODEL_MD, modelLocation)
+	app.ConfigureFromModel(cmd, app.MODEL_MD, serialization.Config, MDModelFlags)
+	config := app.LoadedConfigs[app.MODEL_MD]
+	if config != nil && config.WordBased {
+		panic("Word based MD models are not supported")
+	}
+	if config == nil {
+		featuresLocation, found := util.LocateFile(app.MdFeaturesFile, app.DEFAULT_CONF_DIRS)
+		if !found {
+			panic(fmt.Sprintf("MD features not found"))
+		}
+		app.MdFeaturesFile = featuresLocation
+	}
+	paramFunc, exists := nlp.MDParams[app.MdParamFuncName]
+	if !exists {
+		panic(fmt.Sprintf("MD param func %v doesn't exist", app.MdParamFuncName))
+	}
+	mdTrans = &disambig.MDTrans{
+		ParamFunc: paramFunc,
+		UsePOP:    app.UsePOP,
+	}
+	transitionSystem := transition.TransitionSystem(mdTrans)
 	confBeam := &search.Beam{}
 	app.MDConfigOut(modelLocation, confBeam, transitionSystem)
-	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
 	app.SetupMDEnum()
 	mdTrans.(*disambig.MDTrans).POP = app.POP
 	mdTrans.(*disambig.MDTrans).Transitions = app.MdETrans
 	mdTrans.AddDefaultOracle()
-	featureSetup, err := transition.LoadFeatureConfFile(featuresLocation)
+	featureSetup, err := config.FeatureSetup(app.MdFeaturesFile)
 	if err != nil {
-		panic(fmt.Sprintf("Failed reading MD feature configuration file [%v]: %v", featuresLocation, err))
+		panic(fmt.Sprintf("Failed reading MD feature configuration file [%v]: %v", app.MdFeaturesFile, err))
 	}
+	verifyFeatures(app.MODEL_MD, serialization, featureSetup)
 	extractor := app.SetupExtractor(featureSetup, []byte("MPL"))
 	log.Println()
 	nlp.InitOpenParamFamily("HEBTB")
 	log.Println()
 	log.Println("Found MD model file", modelLocation, " ... loading model")
 
-	serialization := app.ReadModel(modelLocation)
-	model.Deserialize(serialization.WeightModel)
+	if err := serialization.LoadWeights(model); err != nil {
+		log.Fatalln("Failed loading", app.MODEL_MD, "model:", err)
+	}
 	app.MdEWord = serialization.EWord
 	app.MdEPOS = serialization.EPOS
 	app.MdEWPOS = serialization.EWPOS
@@ -87,10 +95,12 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 	extractor = app.SetupExtractor(featureSetup, []byte("MPL"))
 
 	conf := &disambig.MDConfig{
-		ETokens:     app.MdETokens,
-		POP:         app.POP,
-		Transitions: app.MdETrans,
-		ParamFunc:   paramFunc,
+		ETokens:         app.MdETokens,
+		POP:             app.POP,
+		Transitions:     app.MdETrans,
+		ParamFunc:       paramFunc,
+		UsePOP:          app.UsePOP,
+		SwitchFormLemma: !lattice.IGNORE_LEMMA,
 	}
 
 	mdBeam = &search.Beam{
@@ -101,24 +111,55 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 		ConcurrentExec:       app.ConcurrentBeam,
 		Transitions:          app.MdETrans,
 		EstimatedTransitions: 1000, // chosen by random dice roll
+		Timeout:              SentenceTimeout,
+		CompleteGreedily:     CompleteGreedily,
+		RoundsObserver:       observeRounds(COMPONENT_MD),
 	}
 	mdBeam.ShortTempAgenda = true
 	mdBeam.Model = model
+	mdPool = NewParserPool(Workers, QueueSize, func() interface{} {
+		// weights, extractor and enum sets are shared between beams
+		beam := *mdBeam
+		return &beam
+	})
 }
 
-func MorphDisambiguateLattices(input string) string {
-	mdLock.Lock()
+func MorphDisambiguateLattices(input string) (string, error) {
+	mappings, err := MorphDisambiguate(context.Background(), input, Options{})
+	if err != nil {
+		return "", err
+	}
+	return Options{}.MappingsToString(mappings), nil
+}
+
+func MorphDisambiguate(ctx context.Context, input string, opts Options) ([]interface{}, error) {
+	mappings, _, err := morphDisambiguate(ctx, input, opts, 1)
+	return mappings, err
+}
+
+// MorphDisambiguateKBest is MorphDisambiguate also returning the
+// opts.KBest best mappings of each sentence
+func MorphDisambiguateKBest(ctx context.Context, input string, opts Options) ([]interface{}, [][]scoredAnalysis, error) {
+	return morphDisambiguate(ctx, input, opts, opts.KBest)
+}
+
+func morphDisambiguate(ctx context.Context, input string, opts Options, k int) (mappings []interface{}, kBest [][]scoredAnalysis, err error) {
+	instance, err := mdPool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, DeadlineError(err)
+	}
+	defer mdPool.Release(instance)
+	defer recoverAs(http.StatusInternalServerError, "disambiguating lattices", &err)
 	log.Println("Reading ambiguous lattices")
 	log.Println("input:\n ", input)
 	reader := strings.NewReader(input)
 	lAmb, lAmbE := lattice.Read(reader, 0)
 	if lAmbE != nil {
-		panic(fmt.Sprintf("Failed reading raw input - %v", lAmbE))
+		return nil, nil, InputError(lAmbE)
+	}
+	predAmbLat, err := latticeSentences(lAmb, app.MdEWord, app.MdEPOS, app.MdEWPOS, app.MdEMorphProp, app.MdEMHost, app.MdEMSuffix)
+	if err != nil {
+		return nil, nil, err
 	}
-	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, app.MdEWord, app.MdEPOS, app.MdEWPOS, app.MdEMorphProp, app.MdEMHost, app.MdEMSuffix)
-	mappings := app.Parse(predAmbLat, mdBeam)
-	buf := new(bytes.Buffer)
-	mapping.Write(buf, mappings)
-	mdLock.Unlock()
-	return buf.String()
+	return parse(ctx, predAmbLat, opts.Beam(instance.(*search.Beam)), k)
 }
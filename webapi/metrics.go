@@ -0,0 +1,269 @@
+package webapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+)
+
+// Metrics are exposed at /metrics in the Prometheus text format
+
+var (
+	requestsTotal = newMetric("counter", "yap_requests_total",
+		"Requests handled, by endpoint and response code", "endpoint", "code")
+	requestDuration = newHistogram("yap_request_duration_seconds",
+		"Request latency, by endpoint", []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}, "endpoint")
+	sentencesTotal = newMetric("counter", "yap_sentences_total",
+		"Sentences processed, by endpoint", "endpoint")
+	tokensTotal = newMetric("counter", "yap_tokens_total",
+		"Tokens processed, by endpoint", "endpoint")
+	morphemesTotal = newMetric("counter", "yap_morphemes_total",
+		"Disambiguated morphemes output, by endpoint", "endpoint")
+	maTokensTotal = newMetric("counter", "yap_ma_tokens_total",
+		"Tokens analyzed by the morphological analyzer")
+	maOOVTokensTotal = newMetric("counter", "yap_ma_oov_tokens_total",
+		"Tokens not found in the lexicon by the morphological analyzer (OOV rate = oov / tokens)")
+	beamRounds = newHistogram("yap_beam_rounds",
+		"Beam search rounds per sentence, by component", []float64{10, 25, 50, 100, 200, 400, 800}, "component")
+	componentLoadTime = newMetric("gauge", "yap_component_loaded_timestamp_seconds",
+		"Unix time at which each component (lexicon/model) finished loading", "component")
+
+	allMetrics = []*metric{
+		requestsTotal,
+		requestDuration,
+		sentencesTotal,
+		tokensTotal,
+		morphemesTotal,
+		maTokensTotal,
+		maOOVTokensTotal,
+		beamRounds,
+		componentLoadTime,
+	}
+)
+
+// metric is a family of counters, gauges or histograms sharing a name,
+// with a series per combination of label values
+type metric struct {
+	kind, name, help string
+	labels           []string
+	buckets          []float64 // histograms only
+
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// histograms only
+	bucketCounts []uint64 // not cumulative
+	count        uint64
+}
+
+func newMetric(kind, name, help string, labels ...string) *metric {
+	return &metric{kind: kind, name: name, help: help, labels: labels, series: make(map[string]*series)}
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *metric {
+	m := newMetric("histogram", name, help, labels...)
+	m.buckets = buckets
+	return m
+}
+
+func (m *metric) get(labelValues []string) *series {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("Metric %v expects labels %v, got %v", m.name, m.labels, labelValues))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, exists := m.series[key]
+	if !exists {
+		s = &series{labelValues: labelValues}
+		if m.buckets != nil {
+			s.bucketCounts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Add adds to a counter (or gauge)
+func (m *metric) Add(value float64, labelValues ...string) {
+	m.lock.Lock()
+	m.get(labelValues).value += value
+	m.lock.Unlock()
+}
+
+// Set sets a gauge
+func (m *metric) Set(value float64, labelValues ...string) {
+	m.lock.Lock()
+	m.get(labelValues).value = value
+	m.lock.Unlock()
+}
+
+// Observe adds an observation to a histogram
+func (m *metric) Observe(value float64, labelValues ...string) {
+	m.lock.Lock()
+	s := m.get(labelValues)
+	for i, bound := range m.buckets {
+		if value <= bound {
+			s.bucketCounts[i]++
+			break
+		}
+	}
+	s.value += value
+	s.count++
+	m.lock.Unlock()
+}
+
+func (m *metric) Write(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	fmt.Fprintf(w, "# HELP %v %v\n", m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %v %v\n", m.name, m.kind)
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := m.series[key]
+		if m.kind != "histogram" {
+			fmt.Fprintf(w, "%v%v %v\n", m.name, labelString(m.labels, s.labelValues, "", ""), formatValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += s.bucketCounts[i]
+			fmt.Fprintf(w, "%v_bucket%v %v\n", m.name, labelString(m.labels, s.labelValues, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%v_bucket%v %v\n", m.name, labelString(m.labels, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%v_sum%v %v\n", m.name, labelString(m.labels, s.labelValues, "", ""), formatValue(s.value))
+		fmt.Fprintf(w, "%v_count%v %v\n", m.name, labelString(m.labels, s.labelValues, "", ""), s.count)
+	}
+}
+
+func labelString(names, values []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+"=\""+escapeLabel(values[i])+"\"")
+	}
+	if len(extraName) > 0 {
+		pairs = append(pairs, extraName+"=\""+extraValue+"\"")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// MetricsHandler serves all metrics in the Prometheus text exposition format
+func MetricsHandler(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range allMetrics {
+		m.Write(resp)
+	}
+}
+
+// statusRecorder remembers the response code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush keeps streaming (batch) responses working
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// instrument counts and times the requests of an endpoint
+func instrument(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: resp, code: http.StatusOK}
+		handler(recorder, req)
+		requestDuration.Observe(time.Since(start).Seconds(), endpoint)
+		requestsTotal.Add(1, endpoint, strconv.Itoa(recorder.code))
+	}
+}
+
+// countProcessed records the sentences, tokens and morphemes
+// of an endpoint's disambiguated mappings (*disambig.MDConfig)
+func countProcessed(endpoint string, mappedSents []interface{}) {
+	var tokens, morphemes int
+	for _, mappedSent := range mappedSents {
+		for _, mapping := range mappedSent.(*disambig.MDConfig).Mappings {
+			if mapping.Token == nlp.ROOT_TOKEN {
+				continue
+			}
+			tokens++
+			for _, morph := range mapping.Spellout {
+				if morph != nil {
+					morphemes++
+				}
+			}
+		}
+	}
+	sentencesTotal.Add(float64(len(mappedSents)), endpoint)
+	tokensTotal.Add(float64(tokens), endpoint)
+	morphemesTotal.Add(float64(morphemes), endpoint)
+}
+
+// countAnalyzed records the sentences and tokens of an endpoint's
+// (ambiguous) lattices
+func countAnalyzed(endpoint string, lats []lattice.Lattice) {
+	var tokens int
+	for _, lat := range lats {
+		seen := make(map[int]bool)
+		for _, edges := range lat {
+			for _, edge := range edges {
+				seen[edge.Token] = true
+			}
+		}
+		tokens += len(seen)
+	}
+	sentencesTotal.Add(float64(len(lats)), endpoint)
+	tokensTotal.Add(float64(tokens), endpoint)
+}
+
+// countParsed records the sentences and morphemes of an endpoint's trees (conll.Sentence)
+func countParsed(endpoint string, trees []interface{}) {
+	var morphemes int
+	for _, tree := range trees {
+		morphemes += len(tree.(conll.Sentence))
+	}
+	sentencesTotal.Add(float64(len(trees)), endpoint)
+	morphemesTotal.Add(float64(morphemes), endpoint)
+}
+
+// observeRounds returns a search.Beam RoundsObserver for the component
+func observeRounds(component string) func(int) {
+	return func(rounds int) {
+		beamRounds.Observe(float64(rounds), component)
+	}
+}
@@ -0,0 +1,78 @@
+package webapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+)
+
+func TestMetricWrite(t *testing.T) {
+	histogram := newHistogram("test_seconds", "Test latency", []float64{.1, 1}, "endpoint")
+	for _, value := range []float64{.05, .5, .5, 2} {
+		histogram.Observe(value, `a"b`)
+	}
+	counter := newMetric("counter", "test_total", "Test counter", "endpoint", "code")
+	counter.Add(1, "md", "200")
+	counter.Add(2, "md", "200")
+	counter.Add(1, "dep", "400")
+
+	var buf bytes.Buffer
+	histogram.Write(&buf)
+	counter.Write(&buf)
+	expected := `# HELP test_seconds Test latency
+# TYPE test_seconds histogram
+test_seconds_bucket{endpoint="a\"b",le="0.1"} 1
+test_seconds_bucket{endpoint="a\"b",le="1"} 3
+test_seconds_bucket{endpoint="a\"b",le="+Inf"} 4
+test_seconds_sum{endpoint="a\"b"} 3.05
+test_seconds_count{endpoint="a\"b"} 4
+# HELP test_total Test counter
+# TYPE test_total counter
+test_total{endpoint="dep",code="400"} 1
+test_total{endpoint="md",code="200"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("metrics written as\n%v\nexpected\n%v", buf.String(), expected)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	handler := instrument("metrics_test", func(resp http.ResponseWriter, req *http.Request) {
+		respondWithParseError(resp, NewError(http.StatusBadRequest, "bad request"))
+	})
+	for i := 0; i < 2; i++ {
+		handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	countProcessed("metrics_test", []interface{}{&disambig.MDConfig{Mappings: nlp.Mappings{
+		{Token: "בבית", Spellout: nlp.Spellout{&nlp.EMorpheme{}, &nlp.EMorpheme{}}},
+		{Token: nlp.ROOT_TOKEN, Spellout: nlp.Spellout{nlp.NewRootMorpheme()}},
+	}}})
+
+	recorder := httptest.NewRecorder()
+	MetricsHandler(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("metrics responded %v with content type %v", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		lines[line] = true
+	}
+	for _, line := range []string{
+		"# TYPE yap_requests_total counter",
+		`yap_requests_total{endpoint="metrics_test",code="400"} 2`,
+		`yap_request_duration_seconds_count{endpoint="metrics_test"} 2`,
+		`yap_sentences_total{endpoint="metrics_test"} 1`,
+		`yap_tokens_total{endpoint="metrics_test"} 1`,
+		`yap_morphemes_total{endpoint="metrics_test"} 2`,
+		"# TYPE yap_beam_rounds histogram",
+	} {
+		if !lines[line] {
+			t.Errorf("metrics without %q", line)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package webapi
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"yap/alg/search"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/conllul"
+	"yap/nlp/format/lattice"
+	"yap/nlp/format/mapping"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	"yap/nlp/tokenizer"
+)
+
+const (
+	FORMAT_SPMRL = "spmrl"
+	FORMAT_UD    = "ud"
+)
+
+var (
+	MaxBeamSize int
+
+	// writes lattices passed between components, keeping their offsets
+	offsetsOptions = Options{Offsets: true}
+)
+
+// Options are optional per-request decoding and output settings,
+// overriding the server's (command line) defaults
+type Options struct {
+	BeamSize      int    `json:"beam,omitempty"`
+	JointStrategy string `json:"joint_strategy,omitempty"`
+	// Also return the k best analyses of each sentence, ranked with their
+	// scores (md, dep and joint only; at most the beam size)
+	KBest int `json:"kbest,omitempty"`
+	// Output format of lattices and trees: spmrl (default) or ud;
+	// analysis and decoding always use the models' own format
+	Format string `json:"format,omitempty"`
+	// Strip lemmas from the output; analysis and decoding still use the
+	// lemmas as the models were trained (see -nolemma)
+	StripLemmas bool `json:"strip_lemmas,omitempty"`
+	// Split raw text into sentences and tokens with the tokenizer; by
+	// default text is already tokenized: tokens are separated by spaces
+	// (or new lines), sentences by two spaces (or an empty line)
+	Tokenize bool `json:"tokenize,omitempty"`
+	// Add the character offsets (start:end) of each morpheme in the input
+	// text as a last field of lattice, mapping and conll lines (spmrl only)
+	Offsets bool `json:"offsets,omitempty"`
+}
+
+// Validate checks the options are valid for the given endpoint
+func (o Options) Validate(endpoint string) error {
+	if o.BeamSize < 0 || o.BeamSize > MaxBeamSize {
+		return NewError(http.StatusBadRequest, "Beam size must be between 1 and %v (0 for the server's default), got %v", MaxBeamSize, o.BeamSize)
+	}
+	if o.KBest < 0 || o.KBest > MaxBeamSize {
+		return NewError(http.StatusBadRequest, "K-best must be between 1 and %v (0 for the best analysis only), got %v", MaxBeamSize, o.KBest)
+	}
+	if o.KBest > 1 && endpoint != "md" && endpoint != "dep" && endpoint != "joint" {
+		return NewError(http.StatusBadRequest, "K-best analyses are not supported by the %v endpoint", endpoint)
+	}
+	if len(o.JointStrategy) > 0 {
+		var found bool
+		for _, strategy := range strings.Split(joint.JointStrategies, ", ") {
+			found = found || strategy == o.JointStrategy
+		}
+		if !found {
+			return NewError(http.StatusBadRequest, "Unknown joint strategy %v, must be one of [%v]", o.JointStrategy, joint.JointStrategies)
+		}
+	}
+	switch o.Format {
+	case "", FORMAT_SPMRL:
+	case FORMAT_UD:
+		if endpoint == "dep" || endpoint == "pipeline" {
+			return NewError(http.StatusBadRequest, "Format %v is not supported by the %v endpoint", o.Format, endpoint)
+		}
+	default:
+		return NewError(http.StatusBadRequest, "Unknown format %v, must be one of [%v, %v]", o.Format, FORMAT_SPMRL, FORMAT_UD)
+	}
+	if o.Offsets && o.Format == FORMAT_UD {
+		return NewError(http.StatusBadRequest, "Offsets are not supported by format %v", o.Format)
+	}
+	return nil
+}
+
+// Beam returns the beam to decode with: the given (pooled) beam,
+// or a copy of it sharing the model if any decoding option is set
+func (o Options) Beam(beam *search.Beam) *search.Beam {
+	if o.BeamSize == 0 && len(o.JointStrategy) == 0 {
+		return beam
+	}
+	requestBeam := *beam
+	if o.BeamSize > 0 {
+		requestBeam.Size = o.BeamSize
+	}
+	if len(o.JointStrategy) > 0 {
+		if jointTrans, ok := beam.TransFunc.(*joint.JointTrans); ok {
+			requestTrans := *jointTrans
+			requestTrans.JointStrategy = o.JointStrategy
+			requestBeam.TransFunc = &requestTrans
+		}
+	}
+	return &requestBeam
+}
+
+// SplitText splits text into sentences of tokens with their offsets
+// in the text, using the tokenizer if asked to
+func (o Options) SplitText(text string) []tokenizer.Sentence {
+	if o.Tokenize {
+		return tokenizer.Tokenize(text)
+	}
+	return tokenizer.Pretokenized(text)
+}
+
+func (o Options) LatticesToString(lats []lattice.Lattice) string {
+	if o.StripLemmas {
+		stripped := make([]lattice.Lattice, len(lats))
+		for i, lat := range lats {
+			stripped[i] = make(lattice.Lattice, len(lat))
+			for j, edges := range lat {
+				stripped[i][j] = make([]lattice.Edge, len(edges))
+				for k, edge := range edges {
+					edge.Lemma = ""
+					stripped[i][j][k] = edge
+				}
+			}
+		}
+		lats = stripped
+	}
+	buf := new(bytes.Buffer)
+	if o.Format == FORMAT_UD {
+		lattice.UDWrite(buf, lats, nil, nil)
+	} else if o.Offsets {
+		lattice.WriteOffsets(buf, lats)
+	} else {
+		lattice.Write(buf, lats)
+	}
+	return buf.String()
+}
+
+// MappingsToString writes disambiguated mappings (*disambig.MDConfig);
+// note that StripLemmas strips the lemmas from the mappings themselves
+func (o Options) MappingsToString(mappedSents []interface{}) string {
+	if o.StripLemmas {
+		for _, mappedSent := range mappedSents {
+			for _, m := range mappedSent.(*disambig.MDConfig).Mappings {
+				for _, morph := range m.Spellout {
+					if morph != nil {
+						morph.Lemma = ""
+					}
+				}
+			}
+		}
+	}
+	buf := new(bytes.Buffer)
+	if o.Format == FORMAT_UD {
+		comments := make([]conllul.ConlluLattice, len(mappedSents))
+		mapping.UDWrite(buf, mappedSents, comments)
+	} else if o.Offsets {
+		mapping.WriteOffsets(buf, mappedSents)
+	} else {
+		mapping.Write(buf, mappedSents)
+	}
+	return buf.String()
+}
+
+// ConllToString writes dependency trees (conll.Sentence)
+func (o Options) ConllToString(graphAsConll []interface{}) string {
+	if o.StripLemmas {
+		stripped := make([]interface{}, len(graphAsConll))
+		for i, sent := range graphAsConll {
+			strippedSent := make(conll.Sentence, len(sent.(conll.Sentence)))
+			for id, row := range sent.(conll.Sentence) {
+				row.Lemma = "_"
+				strippedSent[id] = row
+			}
+			stripped[i] = strippedSent
+		}
+		graphAsConll = stripped
+	}
+	buf := new(bytes.Buffer)
+	if o.Offsets {
+		conll.WriteOffsets(buf, graphAsConll)
+	} else {
+		conll.Write(buf, graphAsConll)
+	}
+	return buf.String()
+}
+
+// JointTreesToString writes the dependency trees of jointly parsed graphs
+func (o Options) JointTreesToString(parsedGraphs []interface{}) string {
+	if o.Format == FORMAT_UD {
+		graphAsConllU := conllu.MorphGraph2ConllCorpus(parsedGraphs)
+		if o.StripLemmas {
+			for _, sent := range graphAsConllU {
+				for id, row := range sent.(conllu.Sentence).Deps {
+					row.Lemma = "_"
+					sent.(conllu.Sentence).Deps[id] = row
+				}
+			}
+		}
+		buf := new(bytes.Buffer)
+		conllu.Write(buf, graphAsConllU)
+		return buf.String()
+	}
+	return o.ConllToString(conll.MorphGraph2ConllCorpus(parsedGraphs))
+}
@@ -0,0 +1,82 @@
+package webapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"yap/alg/search"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/joint"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	defer func(max int) { MaxBeamSize = max }(MaxBeamSize)
+	MaxBeamSize = 32
+	for _, test := range []struct {
+		options  Options
+		endpoint string
+		message  string
+	}{
+		{Options{}, "joint", ""},
+		{Options{BeamSize: 32, KBest: 32, JointStrategy: "MDFirst", Format: FORMAT_UD}, "joint", ""},
+		{Options{Format: FORMAT_SPMRL, Offsets: true, StripLemmas: true, Tokenize: true}, "pipeline", ""},
+		{Options{KBest: 1}, "batch", ""},
+		{Options{BeamSize: -1}, "md", "Beam size must be between 1 and 32 (0 for the server's default), got -1"},
+		{Options{BeamSize: 33}, "md", "Beam size must be between 1 and 32 (0 for the server's default), got 33"},
+		{Options{KBest: -1}, "dep", "K-best must be between 1 and 32 (0 for the best analysis only), got -1"},
+		{Options{KBest: 33}, "dep", "K-best must be between 1 and 32 (0 for the best analysis only), got 33"},
+		{Options{KBest: 2}, "pipeline", "K-best analyses are not supported by the pipeline endpoint"},
+		{Options{KBest: 2}, "ma", "K-best analyses are not supported by the ma endpoint"},
+		{Options{JointStrategy: "Greedy"}, "joint", "Unknown joint strategy Greedy"},
+		{Options{Format: FORMAT_UD}, "dep", "Format ud is not supported by the dep endpoint"},
+		{Options{Format: FORMAT_UD}, "pipeline", "Format ud is not supported by the pipeline endpoint"},
+		{Options{Format: "conll"}, "md", "Unknown format conll, must be one of [spmrl, ud]"},
+		{Options{Format: FORMAT_UD, Offsets: true}, "ma", "Offsets are not supported by format ud"},
+	} {
+		err := test.options.Validate(test.endpoint)
+		if len(test.message) == 0 {
+			if err != nil {
+				t.Errorf("%v %+v: %v", test.endpoint, test.options, err)
+			}
+			continue
+		}
+		apiErr, ok := err.(*Error)
+		if !ok || apiErr.Code != http.StatusBadRequest || !strings.HasPrefix(apiErr.Message, test.message) {
+			t.Errorf("%v %+v: error %v, expected %q", test.endpoint, test.options, err, test.message)
+		}
+	}
+}
+
+func TestOptionsBeam(t *testing.T) {
+	pooled := &search.Beam{Size: 64, TransFunc: &joint.JointTrans{JointStrategy: "ArcGreedy"}}
+	if beam := (Options{}).Beam(pooled); beam != pooled {
+		t.Error("copied the pooled beam without decoding options")
+	}
+	beam := Options{BeamSize: 4, JointStrategy: "MDFirst"}.Beam(pooled)
+	if beam == pooled || beam.Size != 4 || beam.TransFunc.(*joint.JointTrans).JointStrategy != "MDFirst" {
+		t.Errorf("request beam of size %v strategy %v, expected 4 MDFirst", beam.Size, beam.TransFunc.(*joint.JointTrans).JointStrategy)
+	}
+	if pooled.Size != 64 || pooled.TransFunc.(*joint.JointTrans).JointStrategy != "ArcGreedy" {
+		t.Errorf("pooled beam changed to size %v strategy %v", pooled.Size, pooled.TransFunc.(*joint.JointTrans).JointStrategy)
+	}
+}
+
+func TestOptionsStripLemmas(t *testing.T) {
+	lats := []lattice.Lattice{{0: {{Start: 0, End: 1, Word: "גנן", Lemma: "גינן", CPosTag: "VB", PosTag: "VB", FeatStr: "_", Token: 1}}}}
+	if out := (Options{}).LatticesToString(lats); !strings.Contains(out, "גינן") {
+		t.Errorf("lattice %q without its lemma", out)
+	}
+	if out := (Options{StripLemmas: true}).LatticesToString(lats); strings.Contains(out, "גינן") {
+		t.Errorf("lattice %q with its lemma stripped", out)
+	}
+	trees := []interface{}{conll.Sentence{1: {ID: 1, Form: "גנן", Lemma: "גינן", CPosTag: "VB", PosTag: "VB", Head: 0, DepRel: "ROOT"}}}
+	if out := (Options{StripLemmas: true}).ConllToString(trees); strings.Contains(out, "גינן") {
+		t.Errorf("tree %q with its lemma stripped", out)
+	}
+	// only the output is stripped
+	if lats[0][0][0].Lemma != "גינן" || trees[0].(conll.Sentence)[1].Lemma != "גינן" {
+		t.Error("stripped the lemmas of the decoded lattices and trees")
+	}
+}
@@ -0,0 +1,72 @@
+package webapi
+
+import (
+	"context"
+	"net/http"
+)
+
+var (
+	Workers   int
+	QueueSize int
+
+	ErrServerBusy error = &Error{Code: http.StatusServiceUnavailable, Message: "Server is busy, try again later"}
+)
+
+// ParserPool holds a fixed number of independent parser instances
+// (which may share read-only state such as model weights and enum sets)
+// Requests wait for a free instance; at most QueueSize requests may wait
+// beyond the number of instances, any more are turned away with ErrServerBusy
+type ParserPool struct {
+	instances   chan interface{}
+	slots       chan struct{}
+	newInstance func() interface{}
+}
+
+func NewParserPool(workers, queueSize int, newInstance func() interface{}) *ParserPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	pool := &ParserPool{
+		instances:   make(chan interface{}, workers),
+		slots:       make(chan struct{}, workers+queueSize),
+		newInstance: newInstance,
+	}
+	for i := 0; i < workers; i++ {
+		pool.instances <- newInstance()
+	}
+	return pool
+}
+
+// Acquire waits for a free instance, returning the context's error if it
+// is done first (e.g. the client went away) so it doesn't take a slot
+func (p *ParserPool) Acquire(ctx context.Context) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return nil, ErrServerBusy
+	}
+	select {
+	case instance := <-p.instances:
+		return instance, nil
+	case <-ctx.Done():
+		<-p.slots
+		return nil, ctx.Err()
+	}
+}
+
+func (p *ParserPool) Release(instance interface{}) {
+	p.instances <- instance
+	<-p.slots
+}
+
+// Discard drops an acquired instance that may be left corrupt (e.g. by a
+// recovered panic), releasing a fresh instance in its place
+func (p *ParserPool) Discard(instance interface{}) {
+	p.Release(p.newInstance())
+}
@@ -0,0 +1,127 @@
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yap/alg/search"
+)
+
+func TestParserPool(t *testing.T) {
+	var created int
+	pool := NewParserPool(2, 1, func() interface{} {
+		created++
+		return created
+	})
+	if created != 2 {
+		t.Fatalf("created %v instances, expected 2", created)
+	}
+	ctx := context.Background()
+	first, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("acquired instance %v twice", first)
+	}
+
+	// a third request waits in the queue for a released instance
+	acquired := make(chan interface{})
+	go func() {
+		instance, err := pool.Acquire(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- instance
+	}()
+	// give it time to take its queue slot
+	for i := 0; i < 100 && len(pool.slots) < 3; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := pool.Acquire(ctx); err != ErrServerBusy {
+		t.Errorf("acquired beyond the queue with error %v, expected %v", err, ErrServerBusy)
+	}
+	pool.Release(first)
+	if instance := <-acquired; instance != first {
+		t.Errorf("waiting request acquired %v, expected released %v", instance, first)
+	}
+	pool.Release(first)
+	pool.Release(second)
+
+	// released instances are shared by concurrent requests
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				instance, err := pool.Acquire(ctx)
+				if err == ErrServerBusy {
+					time.Sleep(time.Millisecond)
+					continue
+				}
+				pool.Release(instance)
+				return
+			}
+		}()
+	}
+	wg.Wait()
+	if len(pool.instances) != 2 || len(pool.slots) != 0 {
+		t.Errorf("%v free instances and %v taken slots, expected 2 and 0", len(pool.instances), len(pool.slots))
+	}
+}
+
+func TestParserPoolCancelled(t *testing.T) {
+	pool := NewParserPool(1, 1, func() interface{} { return &search.Beam{} })
+	instance, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a request whose client went away doesn't take a slot
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.Acquire(cancelled); err != context.Canceled {
+		t.Errorf("acquired for a cancelled request with error %v, expected %v", err, context.Canceled)
+	}
+	// nor keeps the one it waited in once cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("acquired past the deadline with error %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if len(pool.slots) != 1 {
+		t.Errorf("%v taken slots, expected 1", len(pool.slots))
+	}
+	pool.Release(instance)
+}
+
+func TestParserPoolBusy(t *testing.T) {
+	defer func(pool *ParserPool) { mdPool = pool }(mdPool)
+	mdPool = NewParserPool(1, 0, func() interface{} { return &search.Beam{} })
+	instance, _ := mdPool.Acquire(context.Background())
+	defer mdPool.Release(instance)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/yap/heb/md", strings.NewReader(`{"amb_lattice": "0\t1\tגנן\tגנן\tNN\tNN\tgen=M|num=S\t1\n\n"}`))
+	MorphDisambiguatorHandler(recorder, request)
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("busy server responded %v, expected %v", recorder.Code, http.StatusServiceUnavailable)
+	}
+	var data Data
+	if err := json.NewDecoder(recorder.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Error == nil || *data.Error != *ErrServerBusy.(*Error) {
+		t.Errorf("busy server responded with error %v, expected %v", data.Error, ErrServerBusy)
+	}
+}
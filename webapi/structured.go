@@ -0,0 +1,96 @@
+package webapi
+
+import (
+	"yap/app"
+	"yap/nlp/format/conll"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+)
+
+// Sentence is a structured (opt-in) response sentence, returned in
+// Data.Sentences when a request sets "structured": true
+// Start and end are the character offsets of tokens and morphemes in the
+// request's text (end is exclusive); a morpheme not written in the text,
+// such as the definite article of בבית, has an empty span
+type Sentence struct {
+	Tokens []Token `json:"tokens"`
+}
+
+type Token struct {
+	ID        int        `json:"id"`
+	Form      string     `json:"form"`
+	Start     int        `json:"start"`
+	End       int        `json:"end"`
+	Morphemes []Morpheme `json:"morphemes"`
+}
+
+type Morpheme struct {
+	ID       int               `json:"id"`
+	Form     string            `json:"form"`
+	Lemma    string            `json:"lemma,omitempty"`
+	CPOS     string            `json:"cpos"`
+	POS      string            `json:"pos"`
+	Features map[string]string `json:"features,omitempty"`
+	Head     int               `json:"head"`
+	Relation string            `json:"relation,omitempty"`
+	Start    int               `json:"start"`
+	End      int               `json:"end"`
+}
+
+// StructuredSentences combines disambiguated mappings (*disambig.MDConfig)
+// with their parsed trees (conll.Sentence); morphemes are numbered
+// as in the mapping and conll outputs (1-based per sentence)
+func StructuredSentences(mappedSents []interface{}, trees []interface{}) []Sentence {
+	sents := make([]Sentence, len(mappedSents))
+	for i, mappedSent := range mappedSents {
+		var tree conll.Sentence
+		if trees != nil && i < len(trees) {
+			tree = trees[i].(conll.Sentence)
+		}
+		sents[i] = structuredSentence(mappedSent.(*disambig.MDConfig).Mappings, tree)
+	}
+	return sents
+}
+
+func structuredSentence(mappings nlp.Mappings, tree conll.Sentence) Sentence {
+	sent := Sentence{Tokens: make([]Token, 0, len(mappings))}
+	curMorph := 1
+	for i, mapping := range mappings {
+		if mapping.Token == nlp.ROOT_TOKEN {
+			continue
+		}
+		token := Token{
+			ID:        i + 1,
+			Form:      string(mapping.Token),
+			Morphemes: make([]Morpheme, 0, len(mapping.Spellout)),
+		}
+		token.Start, token.End = mapping.Spellout.Offsets()
+		for _, morph := range mapping.Spellout {
+			if morph == nil {
+				continue
+			}
+			structMorph := Morpheme{
+				ID:       curMorph,
+				Form:     morph.Form,
+				Lemma:    morph.Lemma,
+				CPOS:     morph.CPOS,
+				POS:      morph.POS,
+				Features: morph.Features,
+				Start:    morph.CharStart,
+				End:      morph.CharEnd,
+			}
+			if row, exists := tree[curMorph]; exists {
+				structMorph.Head = row.Head
+				structMorph.Relation = row.DepRel
+			}
+			token.Morphemes = append(token.Morphemes, structMorph)
+			curMorph++
+		}
+		sent.Tokens = append(sent.Tokens, token)
+	}
+	return sent
+}
+
+func JointStructuredSentences(parsedGraphs []interface{}) []Sentence {
+	return StructuredSentences(app.GetInstances(parsedGraphs, app.GetJointMDConfig), conll.MorphGraph2ConllCorpus(parsedGraphs))
+}
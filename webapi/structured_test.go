@@ -0,0 +1,68 @@
+package webapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"yap/nlp/format/conll"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+)
+
+func testMorpheme(form, pos string, start, end int) *nlp.EMorpheme {
+	return &nlp.EMorpheme{
+		Morpheme:  nlp.Morpheme{Form: form, Lemma: form, CPOS: pos, POS: pos},
+		CharStart: start,
+		CharEnd:   end,
+	}
+}
+
+func TestStructuredSentences(t *testing.T) {
+	// "בבית גדול", with a definite article not written in the text
+	mappings := &disambig.MDConfig{Mappings: nlp.Mappings{
+		{Token: "בבית", Spellout: nlp.Spellout{
+			testMorpheme("ב", "PREPOSITION", 0, 1),
+			testMorpheme("ה", "DEF", 1, 1),
+			testMorpheme("בית", "NN", 1, 4),
+		}},
+		{Token: "גדול", Spellout: nlp.Spellout{testMorpheme("גדול", "JJ", 5, 9)}},
+		{Token: nlp.ROOT_TOKEN, Spellout: nlp.Spellout{nlp.NewRootMorpheme()}},
+	}}
+	tree := conll.Sentence{
+		1: {ID: 1, Head: 0, DepRel: "ROOT"},
+		2: {ID: 2, Head: 3, DepRel: "def"},
+		3: {ID: 3, Head: 1, DepRel: "pobj"},
+		4: {ID: 4, Head: 3, DepRel: "amod"},
+	}
+	expected := []Sentence{{Tokens: []Token{
+		{ID: 1, Form: "בבית", Start: 0, End: 4, Morphemes: []Morpheme{
+			{ID: 1, Form: "ב", Lemma: "ב", CPOS: "PREPOSITION", POS: "PREPOSITION", Head: 0, Relation: "ROOT", Start: 0, End: 1},
+			{ID: 2, Form: "ה", Lemma: "ה", CPOS: "DEF", POS: "DEF", Head: 3, Relation: "def", Start: 1, End: 1},
+			{ID: 3, Form: "בית", Lemma: "בית", CPOS: "NN", POS: "NN", Head: 1, Relation: "pobj", Start: 1, End: 4},
+		}},
+		{ID: 2, Form: "גדול", Start: 5, End: 9, Morphemes: []Morpheme{
+			{ID: 4, Form: "גדול", Lemma: "גדול", CPOS: "JJ", POS: "JJ", Head: 3, Relation: "amod", Start: 5, End: 9},
+		}},
+	}}}
+	if sents := StructuredSentences([]interface{}{mappings}, []interface{}{tree}); !reflect.DeepEqual(sents, expected) {
+		t.Errorf("structured sentences %+v, expected %+v", sents, expected)
+	}
+
+	// without trees (md), morphemes have no heads or relations
+	sents := StructuredSentences([]interface{}{mappings}, nil)
+	for _, token := range sents[0].Tokens {
+		for _, morph := range token.Morphemes {
+			if morph.Head != 0 || len(morph.Relation) > 0 {
+				t.Errorf("morpheme %v has head %v relation %v without a tree", morph.ID, morph.Head, morph.Relation)
+			}
+		}
+	}
+	encoded, err := json.Marshal(sents[0].Tokens[1].Morphemes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"id":4,"form":"גדול","lemma":"גדול","cpos":"JJ","pos":"JJ","head":0,"start":5,"end":9}`; string(encoded) != expected {
+		t.Errorf("morpheme encoded as %s, expected %s", encoded, expected)
+	}
+}
@@ -1,13 +1,22 @@
 package webapi
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
 	"github.com/gorilla/mux"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	"yap/alg/transition"
 	"yap/app"
 	"yap/nlp/format/conll"
 	"yap/nlp/format/lattice"
@@ -18,97 +27,239 @@ import (
 
 var (
 	router *mux.Router
+
+	Host            string
+	Port            int
+	TLSCertFile     string
+	TLSKeyFile      string
+	ShutdownTimeout int
+
+	// per sentence parse time budget, see search.Beam
+	SentenceTimeout  time.Duration
+	CompleteGreedily bool
+
+	// flags set from the configuration saved with each model
+	DepModelFlags   = app.ModelFlags{Features: "dep_features", Labels: "dep_labels", IgnoreLemma: "nolemma"}
+	MDModelFlags    = app.ModelFlags{Features: "md_features", ParamFunc: "md_param_func", UsePOP: "use_end_token", IgnoreLemma: "nolemma"}
+	JointModelFlags = app.ModelFlags{Features: "joint_features", Labels: "dep_labels", ParamFunc: "md_param_func",
+		UsePOP: "use_end_token", JointStrategy: "joint_strategy", OracleStrategy: "joint_oracle_strategy", IgnoreLemma: "nolemma"}
 )
 
 type Request struct {
-	Text          string `json:text`
-	AmbLattice    string `json:amb_lattice`
-	DisambLattice string `json:disamb_lattice`
+	Text          string `json:"text"`
+	AmbLattice    string `json:"amb_lattice"`
+	DisambLattice string `json:"disamb_lattice"`
+	Structured    bool   `json:"structured"`
+	// batch only: a list of sentences, tokens separated by white space
+	Sentences []string `json:"sentences,omitempty"`
+	Options
+}
+
+// UnmarshalJSON also accepts the lattices under the keys AmbLattice and
+// DisambLattice, which clients of earlier versions (whose malformed tags
+// left the field names as keys) send
+func (r *Request) UnmarshalJSON(data []byte) error {
+	type request Request
+	legacy := struct {
+		*request
+		AmbLattice    string
+		DisambLattice string
+	}{request: (*request)(r)}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	if len(r.AmbLattice) == 0 {
+		r.AmbLattice = legacy.AmbLattice
+	}
+	if len(r.DisambLattice) == 0 {
+		r.DisambLattice = legacy.DisambLattice
+	}
+	return nil
 }
 
 type Data struct {
-	MALattice string `json:"ma_lattice,omitempty"`
-	MDLattice string `json:"md_lattice,omitempty"`
-	DepTree   string `json:"dep_tree,omitempty"`
-	Error     error  `json:"error,omitempty"`
+	MALattice string     `json:"ma_lattice,omitempty"`
+	MDLattice string     `json:"md_lattice,omitempty"`
+	DepTree   string     `json:"dep_tree,omitempty"`
+	Sentences []Sentence `json:"sentences,omitempty"`
+	// the k best analyses of each sentence, for requests with kbest
+	KBest [][]Analysis `json:"kbest,omitempty"`
+	Error *Error       `json:"error,omitempty"`
 }
 
 func HebrewMorphAnalyzerHandler(resp http.ResponseWriter, req *http.Request) {
+	serveRequest(resp, req, AnalyzeText)
+}
+
+func MorphDisambiguatorHandler(resp http.ResponseWriter, req *http.Request) {
+	serveRequest(resp, req, DisambiguateLattice)
+}
+
+func DepParserHandler(resp http.ResponseWriter, req *http.Request) {
+	serveRequest(resp, req, ParseLattice)
+}
+
+func HebrewPipelineHandler(resp http.ResponseWriter, req *http.Request) {
+	serveRequest(resp, req, PipelineText)
+}
+
+func HebrewJointHandler(resp http.ResponseWriter, req *http.Request) {
+	serveRequest(resp, req, JointText)
+}
+
+// serveRequest decodes a JSON request, processes it and responds with the result
+func serveRequest(resp http.ResponseWriter, req *http.Request, process func(context.Context, Request) (Data, error)) {
 	request := Request{}
 	err := json.NewDecoder(req.Body).Decode(&request)
 	if err != nil {
-		data := Data{Error: err}
+		data := Data{Error: NewError(http.StatusBadRequest, "Failed decoding request: %v", err)}
 		respondWithJSON(resp, http.StatusBadRequest, data)
 		return
 	}
-	rawText := strings.Replace(request.Text, " ", "\n", -1)
-	maLattice := HebrewMorphAnalyzeRawSentences(rawText)
-	data := Data{MALattice: maLattice}
+	data, err := process(req.Context(), request)
+	if err != nil {
+		respondWithParseError(resp, err)
+		return
+	}
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
-func MorphDisambiguatorHandler(resp http.ResponseWriter, req *http.Request) {
-	request := Request{}
-	err := json.NewDecoder(req.Body).Decode(&request)
+// The following process a request for each endpoint, and are shared
+// by the REST and gRPC servers
+
+// AnalyzeText runs morphological analysis on the request's text
+func AnalyzeText(ctx context.Context, request Request) (Data, error) {
+	if len(strings.TrimSpace(request.Text)) == 0 {
+		return Data{}, NewError(http.StatusBadRequest, "Missing input field: text")
+	}
+	if err := request.Validate("ma"); err != nil {
+		return Data{}, err
+	}
+	lattices, err := HebrewMorphAnalyze(ctx, request.SplitText(request.Text))
 	if err != nil {
-		data := Data{Error: err}
-		respondWithJSON(resp, http.StatusBadRequest, data)
-		return
+		return Data{}, err
+	}
+	countAnalyzed("ma", lattices)
+	return Data{MALattice: request.LatticesToString(lattices)}, nil
+}
+
+// DisambiguateLattice runs morphological disambiguation on the request's ambiguous lattice
+func DisambiguateLattice(ctx context.Context, request Request) (Data, error) {
+	if len(strings.TrimSpace(request.AmbLattice)) == 0 {
+		return Data{}, NewError(http.StatusBadRequest, "Missing input field: amb_lattice")
 	}
 	ambLattice := strings.Replace(request.AmbLattice, "\\t", "\t", -1)
 	ambLattice = strings.Replace(ambLattice, "\\n", "\n", -1)
-	mdLattice := MorphDisambiguateLattices(ambLattice)
-	data := Data{MDLattice: mdLattice}
-	respondWithJSON(resp, http.StatusOK, data)
+	if err := request.Validate("md"); err != nil {
+		return Data{}, err
+	}
+	mappings, kBest, err := MorphDisambiguateKBest(ctx, ambLattice, request.Options)
+	if err != nil {
+		return Data{}, err
+	}
+	countProcessed("md", mappings)
+	data := Data{MDLattice: request.MappingsToString(mappings)}
+	data.KBest = kBestAnalyses(kBest, func(analysis []interface{}) Analysis {
+		return Analysis{MDLattice: request.MappingsToString(analysis)}
+	})
+	return data, nil
 }
 
-func DepParserHandler(resp http.ResponseWriter, req *http.Request) {
-	request := Request{}
-	err := json.NewDecoder(req.Body).Decode(&request)
-	if err != nil {
-		result := Data{Error: err}
-		respondWithJSON(resp, http.StatusBadRequest, result)
-		return
+// ParseLattice runs dependency parsing on the request's disambiguated lattice
+func ParseLattice(ctx context.Context, request Request) (Data, error) {
+	if len(strings.TrimSpace(request.DisambLattice)) == 0 {
+		return Data{}, NewError(http.StatusBadRequest, "Missing input field: disamb_lattice")
 	}
 	disambLattice := strings.Replace(request.DisambLattice, "\\t", "\t", -1)
 	disambLattice = strings.Replace(disambLattice, "\\n", "\n", -1)
-	depTree := DepParseDisambiguatedLattice(disambLattice)
-	data := Data{DepTree: depTree}
-	respondWithJSON(resp, http.StatusOK, data)
+	if err := request.Validate("dep"); err != nil {
+		return Data{}, err
+	}
+	trees, kBest, err := DepParseKBest(ctx, disambLattice, request.Options)
+	if err != nil {
+		return Data{}, err
+	}
+	countParsed("dep", trees)
+	data := Data{DepTree: request.ConllToString(trees)}
+	data.KBest = kBestAnalyses(kBest, func(analysis []interface{}) Analysis {
+		return Analysis{DepTree: request.ConllToString(analysis)}
+	})
+	return data, nil
 }
 
-func HebrewPipelineHandler(resp http.ResponseWriter, req *http.Request) {
-	request := Request{}
-	err := json.NewDecoder(req.Body).Decode(&request)
+// PipelineText runs MA, MD and dependency parsing on the request's text
+func PipelineText(ctx context.Context, request Request) (Data, error) {
+	if len(strings.TrimSpace(request.Text)) == 0 {
+		return Data{}, NewError(http.StatusBadRequest, "Missing input field: text")
+	}
+	if err := request.Validate("pipeline"); err != nil {
+		return Data{}, err
+	}
+	lattices, err := HebrewMorphAnalyze(ctx, request.SplitText(request.Text))
 	if err != nil {
-		data := Data{Error: err}
-		respondWithJSON(resp, http.StatusBadRequest, data)
-		return
+		return Data{}, err
 	}
-	rawText := strings.Replace(request.Text, " ", "\n", -1)
-	maLattice := HebrewMorphAnalyzeRawSentences(rawText)
-	mdLattice := MorphDisambiguateLattices(maLattice)
-	depTree := DepParseDisambiguatedLattice(mdLattice)
-	data := Data{MALattice: maLattice, MDLattice: mdLattice, DepTree: depTree}
-	respondWithJSON(resp, http.StatusOK, data)
+	mappings, err := MorphDisambiguate(ctx, offsetsOptions.LatticesToString(lattices), request.Options)
+	if err != nil {
+		return Data{}, err
+	}
+	trees, err := DepParse(ctx, offsetsOptions.MappingsToString(mappings), request.Options)
+	if err != nil {
+		return Data{}, err
+	}
+	countProcessed("pipeline", mappings)
+	data := Data{
+		MALattice: request.LatticesToString(lattices),
+		MDLattice: request.MappingsToString(mappings),
+		DepTree:   request.ConllToString(trees),
+	}
+	if request.Structured {
+		data.Sentences = StructuredSentences(mappings, trees)
+	}
+	return data, nil
 }
 
-func HebrewJointHandler(resp http.ResponseWriter, req *http.Request) {
-	request := Request{}
-	err := json.NewDecoder(req.Body).Decode(&request)
+// JointText runs MA followed by joint parsing on the request's text
+func JointText(ctx context.Context, request Request) (Data, error) {
+	if len(strings.TrimSpace(request.Text)) == 0 {
+		return Data{}, NewError(http.StatusBadRequest, "Missing input field: text")
+	}
+	if err := request.Validate("joint"); err != nil {
+		return Data{}, err
+	}
+	lattices, err := HebrewMorphAnalyze(ctx, request.SplitText(request.Text))
 	if err != nil {
-		data := Data{Error: err}
-		respondWithJSON(resp, http.StatusBadRequest, data)
-		return
+		return Data{}, err
 	}
-	rawText := strings.Replace(request.Text, " ", "\n", -1)
-	maLattice := HebrewMorphAnalyzeRawSentences(rawText)
-	depTree, mdLattice, _ := JointParseAmbiguousLattices(maLattice)
-	data := Data{MALattice: maLattice, MDLattice: mdLattice, DepTree: depTree}
-	respondWithJSON(resp, http.StatusOK, data)
+	parsedGraphs, kBest, err := JointParseKBest(ctx, offsetsOptions.LatticesToString(lattices), request.Options)
+	if err != nil {
+		return Data{}, err
+	}
+	mappings := app.GetInstances(parsedGraphs, app.GetJointMDConfig)
+	countProcessed("joint", mappings)
+	data := Data{
+		MALattice: request.LatticesToString(lattices),
+		MDLattice: request.MappingsToString(mappings),
+		DepTree:   request.JointTreesToString(parsedGraphs),
+	}
+	if request.Structured {
+		data.Sentences = JointStructuredSentences(parsedGraphs)
+	}
+	data.KBest = kBestAnalyses(kBest, func(analysis []interface{}) Analysis {
+		return Analysis{
+			MDLattice: request.MappingsToString(app.GetInstances(analysis, app.GetJointMDConfig)),
+			DepTree:   request.JointTreesToString(analysis),
+		}
+	})
+	return data, nil
+}
+
+func respondWithParseError(resp http.ResponseWriter, err error) {
+	apiErr := AsError(err)
+	respondWithJSON(resp, apiErr.Code, Data{Error: apiErr})
 }
 
-func respondWithJSON(resp http.ResponseWriter, code int, payload Data) {
+func respondWithJSON(resp http.ResponseWriter, code int, payload interface{}) {
 	resp.Header().Set("Content-Type", "application/json")
 	resp.WriteHeader(code)
 	jsonPayload, err := json.Marshal(payload)
@@ -133,40 +284,214 @@ listen to api requests
 `,
 		Flag: *flag.NewFlagSet("api", flag.ExitOnError),
 	}
-	cmd.Flag.StringVar(&app.HebMaPrefixFile, "ma_prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer")
-	cmd.Flag.StringVar(&app.HebMaLexiconFile, "ma_lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer")
+	cmd.Flag.StringVar(&app.HebMaPrefixFile, "ma_prefix", app.DEFAULT_HEB_MA_PREFIX_FILE, "Prefix file for morphological analyzer")
+	cmd.Flag.StringVar(&app.HebMaLexiconFile, "ma_lexicon", app.DEFAULT_HEB_MA_LEXICON_FILE, "Lexicon file for morphological analyzer")
+	cmd.Flag.StringVar(&EnabledEndpointNames, "endpoints", "ma,md,dep,pipeline,joint,batch", "Comma separated endpoints to enable, only models they need are loaded: [ma,md,dep,pipeline,joint,batch]")
+	cmd.Flag.StringVar(&Host, "host", "", "Host/address to listen on (default all interfaces)")
+	cmd.Flag.IntVar(&Port, "port", 8000, "Port to listen on")
+	cmd.Flag.StringVar(&TLSCertFile, "tls_cert", "", "TLS certificate file (serve HTTPS if set along with -tls_key)")
+	cmd.Flag.StringVar(&TLSKeyFile, "tls_key", "", "TLS private key file")
+	registerGRPCFlags(cmd)
+	cmd.Flag.IntVar(&ShutdownTimeout, "shutdown_timeout", 60, "Seconds to wait for in-flight requests on shutdown; 0 = wait indefinitely")
 	cmd.Flag.BoolVar(&app.HebMaAlwaysnnp, "ma_always_nnp", false, "Always add NNP to tokens and prefixed subtokens")
 	cmd.Flag.BoolVar(&app.HebMaNnpnofeats, "ma_add_nnp_no_feats", false, "Add NNP in lex but without features")
 	cmd.Flag.BoolVar(&app.HebMaShowoov, "ma_show_oov", false, "Output OOV tokens")
 	cmd.Flag.BoolVar(&lex.LOG_FAILURES, "ma_show_lex_error", false, "Log errors encountered when loading the lexicon")
-	cmd.Flag.IntVar(&app.BeamSize, "beam", 64, "Beam size")
+	cmd.Flag.IntVar(&app.BeamSize, "beam", app.DEFAULT_BEAM_SIZE, "Beam size")
+	cmd.Flag.IntVar(&MaxBeamSize, "max_beam", 256, "Max beam size a request may ask for")
+	cmd.Flag.IntVar(&Workers, "workers", runtime.NumCPU(), "Number of parser instances per component (concurrent requests)")
+	cmd.Flag.IntVar(&QueueSize, "queue", 64, "Max requests waiting for a free parser before responding 503")
+	cmd.Flag.DurationVar(&SentenceTimeout, "sentence_timeout", 0, "Time budget for parsing a single sentence (e.g. 10s); 0 = no limit")
+	cmd.Flag.BoolVar(&CompleteGreedily, "timeout_greedy", false, "When a sentence runs out of time complete its best candidate greedily instead of failing")
 	cmd.Flag.BoolVar(&app.UsePOP, "use_end_token", true, "Use end token (pop)")
 	cmd.Flag.BoolVar(&lattice.IGNORE_LEMMA, "nolemma", true, "Ignore lemmas")
 	//cmd.Flag.BoolVar(&conll.IGNORE_LEMMA, "conll_nolemma", true, "Ignore lemmas")
 	cmd.Flag.StringVar(&conll.WORD_TYPE, "conll_wordtype", "form", "Word type [form, lemma, lemma+f (=lemma if present else form)]")
-	cmd.Flag.StringVar(&app.MdParamFuncName, "md_param_func", "Funcs_Main_POS_Both_Prop", "MD param func types: ["+types.AllParamFuncNames+"]")
-	cmd.Flag.StringVar(&app.MdModelName, "md_model_name", "md_model_temp_i9.b64", "MD model file")
-	cmd.Flag.StringVar(&app.DepModelName, "dep_model_name", "dep_zeager_model_temp_i18.b64", "Dep model file")
-	cmd.Flag.StringVar(&app.DepFeaturesFile, "dep_features", "zhangnivre2011.yaml", "Dep features file")
-	cmd.Flag.StringVar(&app.DepLabelsFile, "dep_labels", "hebtb.labels.conf", "Dep labels file")
-	cmd.Flag.StringVar(&app.JointFeaturesFile, "joint_features", "jointzeager.yaml", "Joint features file")
-	cmd.Flag.StringVar(&app.JointModelFile, "joint_model_name", "joint_arc_zeager_model_temp_i33.b64", "Joint model file")
-	cmd.Flag.StringVar(&app.JointStrategy, "joint_strategy", "ArcGreedy", "Joint Strategy: ["+joint.JointStrategies+"]")
-	cmd.Flag.StringVar(&app.OracleStrategy, "joint_oracle_strategy", "ArcGreedy", "Oracle Strategy: ["+joint.OracleStrategies+"]")
+	cmd.Flag.StringVar(&app.MdParamFuncName, "md_param_func", app.DEFAULT_MD_PARAM_FUNC, "MD param func types: ["+types.AllParamFuncNames+"]")
+	cmd.Flag.StringVar(&app.MdModelName, "md_model_name", app.DEFAULT_MD_MODEL_FILE, "MD model file")
+	cmd.Flag.StringVar(&app.MdFeaturesFile, "md_features", app.DEFAULT_MD_FEATURES_FILE, "MD features file")
+	cmd.Flag.StringVar(&app.DepModelName, "dep_model_name", app.DEFAULT_DEP_MODEL_FILE, "Dep model file")
+	cmd.Flag.StringVar(&app.DepFeaturesFile, "dep_features", app.DEFAULT_DEP_FEATURES_FILE, "Dep features file")
+	cmd.Flag.StringVar(&app.DepLabelsFile, "dep_labels", app.DEFAULT_LABELS_FILE, "Dep labels file")
+	cmd.Flag.StringVar(&app.JointFeaturesFile, "joint_features", app.DEFAULT_JOINT_FEATURES_FILE, "Joint features file")
+	cmd.Flag.StringVar(&app.JointModelFile, "joint_model_name", app.DEFAULT_JOINT_MODEL_FILE, "Joint model file")
+	cmd.Flag.StringVar(&app.JointStrategy, "joint_strategy", app.DEFAULT_JOINT_STRATEGY, "Joint Strategy: ["+joint.JointStrategies+"]")
+	cmd.Flag.StringVar(&app.OracleStrategy, "joint_oracle_strategy", app.DEFAULT_ORACLE_STRATEGY, "Oracle Strategy: ["+joint.OracleStrategies+"]")
+	cmd.Flag.BoolVar(&app.MmapModels, "mmap", false, "Memory map binary model files (see model convert) while loading them")
 	return cmd
 }
 
+type Endpoint struct {
+	Name       string
+	Path       string
+	Handler    http.HandlerFunc
+	Components []string
+}
+
+type Component struct {
+	Name       string
+	Initialize func(cmd *commander.Command, args []string)
+}
+
+var (
+	Endpoints = []Endpoint{
+		{"ma", "/yap/heb/ma", HebrewMorphAnalyzerHandler, []string{COMPONENT_MA}},
+		{"md", "/yap/heb/md", MorphDisambiguatorHandler, []string{COMPONENT_MD}},
+		{"dep", "/yap/heb/dep", DepParserHandler, []string{COMPONENT_DEP}},
+		{"pipeline", "/yap/heb/pipeline", HebrewPipelineHandler, []string{COMPONENT_MA, COMPONENT_MD, COMPONENT_DEP}},
+		{"joint", "/yap/heb/joint", HebrewJointHandler, []string{COMPONENT_MA, COMPONENT_JOINT}},
+		{"batch", "/yap/heb/batch", BatchHandler, []string{COMPONENT_MA, COMPONENT_JOINT}},
+	}
+
+	// in initialization order
+	Components = []Component{
+		{COMPONENT_MA, HebrewMorphAnalyazerInitialize},
+		{COMPONENT_MD, MorphDisambiguatorInitialize},
+		{COMPONENT_DEP, DepParserInitialize},
+		{COMPONENT_JOINT, JointParserInitialize},
+	}
+
+	EnabledEndpointNames string
+)
+
+// EnabledEndpoints returns the endpoints listed in EnabledEndpointNames;
+// only the components they require are loaded
+func EnabledEndpoints() []Endpoint {
+	names := make(map[string]bool)
+	for _, name := range strings.Split(EnabledEndpointNames, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) > 0 {
+			names[name] = true
+		}
+	}
+	enabled := make([]Endpoint, 0, len(Endpoints))
+	for _, endpoint := range Endpoints {
+		if names[endpoint.Name] {
+			enabled = append(enabled, endpoint)
+			delete(names, endpoint.Name)
+		}
+	}
+	for name := range names {
+		log.Fatalln("Unknown endpoint", name, "- must be one of", AllEndpointNames())
+	}
+	if len(enabled) == 0 {
+		log.Fatalln("No endpoints enabled, must be one or more of", AllEndpointNames())
+	}
+	return enabled
+}
+
+func AllEndpointNames() string {
+	names := make([]string, len(Endpoints))
+	for i, endpoint := range Endpoints {
+		names[i] = endpoint.Name
+	}
+	return strings.Join(names, ",")
+}
+
+// enableEndpoints routes the enabled endpoints (and the health and metrics
+// endpoints) and registers the components they require, returning the
+// router and the required components
+func enableEndpoints(enabled []Endpoint) (*mux.Router, map[string]bool) {
+	required := make(map[string]bool)
+	r := mux.NewRouter()
+	r.HandleFunc("/healthz", HealthHandler)
+	r.HandleFunc("/readyz", ReadyHandler)
+	r.HandleFunc("/metrics", MetricsHandler)
+	for _, endpoint := range enabled {
+		r.HandleFunc(endpoint.Path, instrument(endpoint.Name, requireComponents(endpoint.Handler, endpoint.Components...)))
+		for _, component := range endpoint.Components {
+			required[component] = true
+		}
+		log.Println("Enabled endpoint", endpoint.Path)
+	}
+	for _, component := range Components {
+		if required[component.Name] {
+			RegisterComponent(component.Name)
+		}
+	}
+	return r, required
+}
+
+// loadComponents initializes the required components in order; the
+// models of the others are never read
+func loadComponents(cmd *commander.Command, args []string, required map[string]bool) {
+	for _, component := range Components {
+		if !required[component.Name] {
+			log.Println("Skipping component", component.Name, "(not required by enabled endpoints)")
+			continue
+		}
+		component.Initialize(cmd, args)
+		SetComponentLoaded(component.Name)
+	}
+}
+
 func StartAPIServer(cmd *commander.Command, args []string) error {
-	HebrewMorphAnalyazerInitialize(cmd, args)
-	MorphDisambiguatorInitialize(cmd, args)
-	DepParserInitialize(cmd, args)
-	JointParserInitialize()
-	router = mux.NewRouter()
-	router.HandleFunc("/yap/heb/ma", HebrewMorphAnalyzerHandler)
-	router.HandleFunc("/yap/heb/md", MorphDisambiguatorHandler)
-	router.HandleFunc("/yap/heb/dep", DepParserHandler)
-	router.HandleFunc("/yap/heb/pipeline", HebrewPipelineHandler)
-	router.HandleFunc("/yap/heb/joint", HebrewJointHandler)
-	log.Fatal(http.ListenAndServe(":8000", router))
+	if (TLSCertFile == "") != (TLSKeyFile == "") {
+		log.Fatalln("Both -tls_cert and -tls_key must be given to serve TLS")
+	}
+	enabled := EnabledEndpoints()
+	var required map[string]bool
+	router, required = enableEndpoints(enabled)
+	server := &http.Server{
+		Addr:    net.JoinHostPort(Host, strconv.Itoa(Port)),
+		Handler: router,
+	}
+
+	// listen right away so health probes are answered while models load
+	go func() {
+		var err error
+		if TLSCertFile != "" {
+			log.Println("Listening (TLS) on", server.Addr)
+			err = server.ListenAndServeTLS(TLSCertFile, TLSKeyFile)
+		} else {
+			log.Println("Listening on", server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	stopGRPC := startGRPCServer(enabled)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+
+	loadComponents(cmd, args, required)
+	log.Println("All components loaded, ready for requests")
+
+	sig := <-stop
+	log.Println("Received", sig, "- draining in-flight requests")
+	SetShuttingDown()
+	ctx := context.Background()
+	if ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(ShutdownTimeout)*time.Second)
+		defer cancel()
+	}
+	if stopGRPC != nil {
+		stopGRPC(ctx)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("Shutdown did not complete cleanly:", err)
+		return err
+	}
+	log.Println("Server stopped")
 	return nil
 }
+
+// loadModel reads and validates a component's model, exiting with a
+// diagnostic if it can't be used
+func loadModel(component, file string) *app.Serialization {
+	serialization, err := app.LoadModel(file, component)
+	if err != nil {
+		log.Fatalln("Failed loading", component, "model:", err)
+	}
+	return serialization
+}
+
+// verifyFeatures exits with a diagnostic if a component's model does not
+// match its feature configuration
+func verifyFeatures(component string, serialization *app.Serialization, featureSetup *transition.FeatureSetup) {
+	if err := serialization.VerifyFeatures(featureSetup); err != nil {
+		log.Fatalln("Failed loading", component, "model:", err)
+	}
+}
@@ -0,0 +1,90 @@
+package webapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gonuts/commander"
+)
+
+func TestRequestLegacyKeys(t *testing.T) {
+	for body, expected := range map[string]Request{
+		`{"amb_lattice": "amb", "disamb_lattice": "disamb", "beam": 4}`: {AmbLattice: "amb", DisambLattice: "disamb", Options: Options{BeamSize: 4}},
+		// keys sent by clients of earlier versions
+		`{"AmbLattice": "amb", "DisambLattice": "disamb", "beam": 4}`: {AmbLattice: "amb", DisambLattice: "disamb", Options: Options{BeamSize: 4}},
+		`{"amb_lattice": "amb", "AmbLattice": "legacy"}`:              {AmbLattice: "amb"},
+		`{"text": "גנן", "structured": true}`:                         {Text: "גנן", Structured: true},
+	} {
+		var request Request
+		if err := json.Unmarshal([]byte(body), &request); err != nil {
+			t.Errorf("%v: %v", body, err)
+			continue
+		}
+		if request.Text != expected.Text || request.AmbLattice != expected.AmbLattice || request.DisambLattice != expected.DisambLattice ||
+			request.Structured != expected.Structured || request.Options != expected.Options {
+			t.Errorf("%v: decoded %+v, expected %+v", body, request, expected)
+		}
+	}
+}
+
+func TestEnabledEndpoints(t *testing.T) {
+	defer func(names string, all []Component) {
+		EnabledEndpointNames, Components = names, all
+		components, shuttingDown = make(map[string]bool), false
+	}(EnabledEndpointNames, Components)
+	components, shuttingDown = make(map[string]bool), false
+	EnabledEndpointNames = "ma, md"
+	var loaded []string
+	Components = nil
+	for _, name := range []string{COMPONENT_MA, COMPONENT_MD, COMPONENT_DEP, COMPONENT_JOINT} {
+		name := name
+		Components = append(Components, Component{name, func(*commander.Command, []string) {
+			loaded = append(loaded, name)
+		}})
+	}
+
+	enabled := EnabledEndpoints()
+	if len(enabled) != 2 || enabled[0].Name != "ma" || enabled[1].Name != "md" {
+		t.Fatalf("enabled endpoints %v, expected ma and md", enabled)
+	}
+	r, required := enableEndpoints(enabled)
+	if !reflect.DeepEqual(required, map[string]bool{COMPONENT_MA: true, COMPONENT_MD: true}) {
+		t.Errorf("required components %v, expected ma and md", required)
+	}
+	get := func(path, body string) (int, Data) {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest("GET", path, strings.NewReader(body)))
+		var data Data
+		json.NewDecoder(recorder.Body).Decode(&data)
+		return recorder.Code, data
+	}
+	if code, _ := getHealth(t, ReadyHandler); code != http.StatusServiceUnavailable {
+		t.Errorf("ready %v before loading, expected %v", code, http.StatusServiceUnavailable)
+	}
+	if code, data := get("/yap/heb/md", `{"amb_lattice": "x"}`); code != http.StatusServiceUnavailable || data.Error == nil {
+		t.Errorf("md responded %v %+v before loading, expected %v", code, data.Error, http.StatusServiceUnavailable)
+	}
+
+	loadComponents(nil, nil, required)
+	// the models of the components of disabled endpoints are never read
+	if !reflect.DeepEqual(loaded, []string{COMPONENT_MA, COMPONENT_MD}) {
+		t.Errorf("loaded components %v, expected ma and md", loaded)
+	}
+	expected := Health{"ok", true, map[string]bool{COMPONENT_MA: true, COMPONENT_MD: true}}
+	if code, h := getHealth(t, ReadyHandler); code != http.StatusOK || !reflect.DeepEqual(h, expected) {
+		t.Errorf("ready %v %+v after loading, expected %v %+v", code, h, http.StatusOK, expected)
+	}
+	// disabled endpoints aren't routed, their components are unavailable
+	if code, _ := get("/yap/heb/dep", `{}`); code != http.StatusNotFound {
+		t.Errorf("disabled dep endpoint responded %v, expected %v", code, http.StatusNotFound)
+	}
+	recorder := httptest.NewRecorder()
+	requireComponents(DepParserHandler, COMPONENT_DEP)(recorder, httptest.NewRequest("GET", "/", strings.NewReader(`{}`)))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("dep without its component responded %v, expected %v", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
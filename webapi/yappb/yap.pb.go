@@ -0,0 +1,1065 @@
+//go:build grpc
+// +build grpc
+
+// gRPC interface to the yap API server, mirroring the REST endpoints
+// (see webapi/grpc.go; build with -tags grpc)
+//
+// Regenerate the Go code with:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative yap.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: yap.proto
+
+package yappb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Per-request decoding and output settings, overriding the server defaults
+type Options struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Beam          int32                  `protobuf:"varint,1,opt,name=beam,proto3" json:"beam,omitempty"`
+	JointStrategy string                 `protobuf:"bytes,2,opt,name=joint_strategy,json=jointStrategy,proto3" json:"joint_strategy,omitempty"`
+	// spmrl (default) or ud
+	Format string `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`
+	// strip lemmas from the output only
+	StripLemmas bool `protobuf:"varint,4,opt,name=strip_lemmas,json=stripLemmas,proto3" json:"strip_lemmas,omitempty"`
+	// split raw text with the tokenizer; by default text is already
+	// tokenized: tokens separated by spaces, sentences by two spaces
+	Tokenize bool `protobuf:"varint,5,opt,name=tokenize,proto3" json:"tokenize,omitempty"`
+	// add the character offsets (start:end) of each morpheme as a last
+	// field of lattice, mapping and conll lines (spmrl only)
+	Offsets bool `protobuf:"varint,6,opt,name=offsets,proto3" json:"offsets,omitempty"`
+	// number of best analyses of each sentence to also return (md, dep
+	// and joint), in the response's kbest
+	Kbest         int32 `protobuf:"varint,7,opt,name=kbest,proto3" json:"kbest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Options) Reset() {
+	*x = Options{}
+	mi := &file_yap_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Options) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Options) ProtoMessage() {}
+
+func (x *Options) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Options.ProtoReflect.Descriptor instead.
+func (*Options) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Options) GetBeam() int32 {
+	if x != nil {
+		return x.Beam
+	}
+	return 0
+}
+
+func (x *Options) GetJointStrategy() string {
+	if x != nil {
+		return x.JointStrategy
+	}
+	return ""
+}
+
+func (x *Options) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *Options) GetStripLemmas() bool {
+	if x != nil {
+		return x.StripLemmas
+	}
+	return false
+}
+
+func (x *Options) GetTokenize() bool {
+	if x != nil {
+		return x.Tokenize
+	}
+	return false
+}
+
+func (x *Options) GetOffsets() bool {
+	if x != nil {
+		return x.Offsets
+	}
+	return false
+}
+
+func (x *Options) GetKbest() int32 {
+	if x != nil {
+		return x.Kbest
+	}
+	return 0
+}
+
+type TextRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Structured    bool                   `protobuf:"varint,2,opt,name=structured,proto3" json:"structured,omitempty"`
+	Options       *Options               `protobuf:"bytes,3,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TextRequest) Reset() {
+	*x = TextRequest{}
+	mi := &file_yap_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TextRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TextRequest) ProtoMessage() {}
+
+func (x *TextRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TextRequest.ProtoReflect.Descriptor instead.
+func (*TextRequest) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TextRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *TextRequest) GetStructured() bool {
+	if x != nil {
+		return x.Structured
+	}
+	return false
+}
+
+func (x *TextRequest) GetOptions() *Options {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+type LatticeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// in the lattice (spmrl) format
+	Lattice       string   `protobuf:"bytes,1,opt,name=lattice,proto3" json:"lattice,omitempty"`
+	Structured    bool     `protobuf:"varint,2,opt,name=structured,proto3" json:"structured,omitempty"`
+	Options       *Options `protobuf:"bytes,3,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LatticeRequest) Reset() {
+	*x = LatticeRequest{}
+	mi := &file_yap_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LatticeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LatticeRequest) ProtoMessage() {}
+
+func (x *LatticeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LatticeRequest.ProtoReflect.Descriptor instead.
+func (*LatticeRequest) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LatticeRequest) GetLattice() string {
+	if x != nil {
+		return x.Lattice
+	}
+	return ""
+}
+
+func (x *LatticeRequest) GetStructured() bool {
+	if x != nil {
+		return x.Structured
+	}
+	return false
+}
+
+func (x *LatticeRequest) GetOptions() *Options {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+type Response struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	MaLattice string                 `protobuf:"bytes,1,opt,name=ma_lattice,json=maLattice,proto3" json:"ma_lattice,omitempty"`
+	MdLattice string                 `protobuf:"bytes,2,opt,name=md_lattice,json=mdLattice,proto3" json:"md_lattice,omitempty"`
+	DepTree   string                 `protobuf:"bytes,3,opt,name=dep_tree,json=depTree,proto3" json:"dep_tree,omitempty"`
+	// set if structured was requested
+	Sentences []*Sentence `protobuf:"bytes,4,rep,name=sentences,proto3" json:"sentences,omitempty"`
+	// set if kbest was requested
+	Kbest         []*SentenceAnalyses `protobuf:"bytes,5,rep,name=kbest,proto3" json:"kbest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Response) Reset() {
+	*x = Response{}
+	mi := &file_yap_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Response) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Response) ProtoMessage() {}
+
+func (x *Response) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Response.ProtoReflect.Descriptor instead.
+func (*Response) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Response) GetMaLattice() string {
+	if x != nil {
+		return x.MaLattice
+	}
+	return ""
+}
+
+func (x *Response) GetMdLattice() string {
+	if x != nil {
+		return x.MdLattice
+	}
+	return ""
+}
+
+func (x *Response) GetDepTree() string {
+	if x != nil {
+		return x.DepTree
+	}
+	return ""
+}
+
+func (x *Response) GetSentences() []*Sentence {
+	if x != nil {
+		return x.Sentences
+	}
+	return nil
+}
+
+func (x *Response) GetKbest() []*SentenceAnalyses {
+	if x != nil {
+		return x.Kbest
+	}
+	return nil
+}
+
+type SentenceRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// echoed in the response
+	Id int32 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// a single sentence, tokens separated by white space
+	Text          string   `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	Structured    bool     `protobuf:"varint,3,opt,name=structured,proto3" json:"structured,omitempty"`
+	Options       *Options `protobuf:"bytes,4,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SentenceRequest) Reset() {
+	*x = SentenceRequest{}
+	mi := &file_yap_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SentenceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SentenceRequest) ProtoMessage() {}
+
+func (x *SentenceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SentenceRequest.ProtoReflect.Descriptor instead.
+func (*SentenceRequest) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SentenceRequest) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SentenceRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *SentenceRequest) GetStructured() bool {
+	if x != nil {
+		return x.Structured
+	}
+	return false
+}
+
+func (x *SentenceRequest) GetOptions() *Options {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+type SentenceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Response      *Response              `protobuf:"bytes,2,opt,name=response,proto3" json:"response,omitempty"`
+	Error         *Error                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SentenceResponse) Reset() {
+	*x = SentenceResponse{}
+	mi := &file_yap_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SentenceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SentenceResponse) ProtoMessage() {}
+
+func (x *SentenceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SentenceResponse.ProtoReflect.Descriptor instead.
+func (*SentenceResponse) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SentenceResponse) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SentenceResponse) GetResponse() *Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *SentenceResponse) GetError() *Error {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+type Error struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the equivalent HTTP status code
+	Code    int32  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// 1-based line of the offending input
+	Line          int32  `protobuf:"varint,3,opt,name=line,proto3" json:"line,omitempty"`
+	Token         string `protobuf:"bytes,4,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_yap_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Error) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Error) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Error) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+type Sentence struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tokens        []*Token               `protobuf:"bytes,1,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sentence) Reset() {
+	*x = Sentence{}
+	mi := &file_yap_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sentence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sentence) ProtoMessage() {}
+
+func (x *Sentence) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sentence.ProtoReflect.Descriptor instead.
+func (*Sentence) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Sentence) GetTokens() []*Token {
+	if x != nil {
+		return x.Tokens
+	}
+	return nil
+}
+
+// start and end are character offsets in the request's text
+// (end is exclusive)
+type Token struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Form          string                 `protobuf:"bytes,2,opt,name=form,proto3" json:"form,omitempty"`
+	Morphemes     []*Morpheme            `protobuf:"bytes,3,rep,name=morphemes,proto3" json:"morphemes,omitempty"`
+	Start         int32                  `protobuf:"varint,4,opt,name=start,proto3" json:"start,omitempty"`
+	End           int32                  `protobuf:"varint,5,opt,name=end,proto3" json:"end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Token) Reset() {
+	*x = Token{}
+	mi := &file_yap_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Token) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Token) ProtoMessage() {}
+
+func (x *Token) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Token.ProtoReflect.Descriptor instead.
+func (*Token) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Token) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Token) GetForm() string {
+	if x != nil {
+		return x.Form
+	}
+	return ""
+}
+
+func (x *Token) GetMorphemes() []*Morpheme {
+	if x != nil {
+		return x.Morphemes
+	}
+	return nil
+}
+
+func (x *Token) GetStart() int32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Token) GetEnd() int32 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+type Morpheme struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Form          string                 `protobuf:"bytes,2,opt,name=form,proto3" json:"form,omitempty"`
+	Lemma         string                 `protobuf:"bytes,3,opt,name=lemma,proto3" json:"lemma,omitempty"`
+	Cpos          string                 `protobuf:"bytes,4,opt,name=cpos,proto3" json:"cpos,omitempty"`
+	Pos           string                 `protobuf:"bytes,5,opt,name=pos,proto3" json:"pos,omitempty"`
+	Features      map[string]string      `protobuf:"bytes,6,rep,name=features,proto3" json:"features,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Head          int32                  `protobuf:"varint,7,opt,name=head,proto3" json:"head,omitempty"`
+	Relation      string                 `protobuf:"bytes,8,opt,name=relation,proto3" json:"relation,omitempty"`
+	Start         int32                  `protobuf:"varint,9,opt,name=start,proto3" json:"start,omitempty"`
+	End           int32                  `protobuf:"varint,10,opt,name=end,proto3" json:"end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Morpheme) Reset() {
+	*x = Morpheme{}
+	mi := &file_yap_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Morpheme) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Morpheme) ProtoMessage() {}
+
+func (x *Morpheme) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Morpheme.ProtoReflect.Descriptor instead.
+func (*Morpheme) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Morpheme) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Morpheme) GetForm() string {
+	if x != nil {
+		return x.Form
+	}
+	return ""
+}
+
+func (x *Morpheme) GetLemma() string {
+	if x != nil {
+		return x.Lemma
+	}
+	return ""
+}
+
+func (x *Morpheme) GetCpos() string {
+	if x != nil {
+		return x.Cpos
+	}
+	return ""
+}
+
+func (x *Morpheme) GetPos() string {
+	if x != nil {
+		return x.Pos
+	}
+	return ""
+}
+
+func (x *Morpheme) GetFeatures() map[string]string {
+	if x != nil {
+		return x.Features
+	}
+	return nil
+}
+
+func (x *Morpheme) GetHead() int32 {
+	if x != nil {
+		return x.Head
+	}
+	return 0
+}
+
+func (x *Morpheme) GetRelation() string {
+	if x != nil {
+		return x.Relation
+	}
+	return ""
+}
+
+func (x *Morpheme) GetStart() int32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Morpheme) GetEnd() int32 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+// The k best analyses of a sentence, best first
+type SentenceAnalyses struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Analyses      []*Analysis            `protobuf:"bytes,1,rep,name=analyses,proto3" json:"analyses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SentenceAnalyses) Reset() {
+	*x = SentenceAnalyses{}
+	mi := &file_yap_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SentenceAnalyses) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SentenceAnalyses) ProtoMessage() {}
+
+func (x *SentenceAnalyses) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SentenceAnalyses.ProtoReflect.Descriptor instead.
+func (*SentenceAnalyses) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SentenceAnalyses) GetAnalyses() []*Analysis {
+	if x != nil {
+		return x.Analyses
+	}
+	return nil
+}
+
+// One of the k best analyses of a sentence: its disambiguated lattice
+// (md, joint) and dependency tree (dep, joint)
+type Analysis struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 1-based
+	Rank          int32   `protobuf:"varint,1,opt,name=rank,proto3" json:"rank,omitempty"`
+	Score         float64 `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	MdLattice     string  `protobuf:"bytes,3,opt,name=md_lattice,json=mdLattice,proto3" json:"md_lattice,omitempty"`
+	DepTree       string  `protobuf:"bytes,4,opt,name=dep_tree,json=depTree,proto3" json:"dep_tree,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Analysis) Reset() {
+	*x = Analysis{}
+	mi := &file_yap_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Analysis) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Analysis) ProtoMessage() {}
+
+func (x *Analysis) ProtoReflect() protoreflect.Message {
+	mi := &file_yap_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Analysis.ProtoReflect.Descriptor instead.
+func (*Analysis) Descriptor() ([]byte, []int) {
+	return file_yap_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Analysis) GetRank() int32 {
+	if x != nil {
+		return x.Rank
+	}
+	return 0
+}
+
+func (x *Analysis) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Analysis) GetMdLattice() string {
+	if x != nil {
+		return x.MdLattice
+	}
+	return ""
+}
+
+func (x *Analysis) GetDepTree() string {
+	if x != nil {
+		return x.DepTree
+	}
+	return ""
+}
+
+var File_yap_proto protoreflect.FileDescriptor
+
+const file_yap_proto_rawDesc = "" +
+	"\n" +
+	"\tyap.proto\x12\x03yap\"\xcb\x01\n" +
+	"\aOptions\x12\x12\n" +
+	"\x04beam\x18\x01 \x01(\x05R\x04beam\x12%\n" +
+	"\x0ejoint_strategy\x18\x02 \x01(\tR\rjointStrategy\x12\x16\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\x12!\n" +
+	"\fstrip_lemmas\x18\x04 \x01(\bR\vstripLemmas\x12\x1a\n" +
+	"\btokenize\x18\x05 \x01(\bR\btokenize\x12\x18\n" +
+	"\aoffsets\x18\x06 \x01(\bR\aoffsets\x12\x14\n" +
+	"\x05kbest\x18\a \x01(\x05R\x05kbest\"i\n" +
+	"\vTextRequest\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12\x1e\n" +
+	"\n" +
+	"structured\x18\x02 \x01(\bR\n" +
+	"structured\x12&\n" +
+	"\aoptions\x18\x03 \x01(\v2\f.yap.OptionsR\aoptions\"r\n" +
+	"\x0eLatticeRequest\x12\x18\n" +
+	"\alattice\x18\x01 \x01(\tR\alattice\x12\x1e\n" +
+	"\n" +
+	"structured\x18\x02 \x01(\bR\n" +
+	"structured\x12&\n" +
+	"\aoptions\x18\x03 \x01(\v2\f.yap.OptionsR\aoptions\"\xbd\x01\n" +
+	"\bResponse\x12\x1d\n" +
+	"\n" +
+	"ma_lattice\x18\x01 \x01(\tR\tmaLattice\x12\x1d\n" +
+	"\n" +
+	"md_lattice\x18\x02 \x01(\tR\tmdLattice\x12\x19\n" +
+	"\bdep_tree\x18\x03 \x01(\tR\adepTree\x12+\n" +
+	"\tsentences\x18\x04 \x03(\v2\r.yap.SentenceR\tsentences\x12+\n" +
+	"\x05kbest\x18\x05 \x03(\v2\x15.yap.SentenceAnalysesR\x05kbest\"}\n" +
+	"\x0fSentenceRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12\x1e\n" +
+	"\n" +
+	"structured\x18\x03 \x01(\bR\n" +
+	"structured\x12&\n" +
+	"\aoptions\x18\x04 \x01(\v2\f.yap.OptionsR\aoptions\"o\n" +
+	"\x10SentenceResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12)\n" +
+	"\bresponse\x18\x02 \x01(\v2\r.yap.ResponseR\bresponse\x12 \n" +
+	"\x05error\x18\x03 \x01(\v2\n" +
+	".yap.ErrorR\x05error\"_\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x12\n" +
+	"\x04line\x18\x03 \x01(\x05R\x04line\x12\x14\n" +
+	"\x05token\x18\x04 \x01(\tR\x05token\".\n" +
+	"\bSentence\x12\"\n" +
+	"\x06tokens\x18\x01 \x03(\v2\n" +
+	".yap.TokenR\x06tokens\"\x80\x01\n" +
+	"\x05Token\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x12\n" +
+	"\x04form\x18\x02 \x01(\tR\x04form\x12+\n" +
+	"\tmorphemes\x18\x03 \x03(\v2\r.yap.MorphemeR\tmorphemes\x12\x14\n" +
+	"\x05start\x18\x04 \x01(\x05R\x05start\x12\x10\n" +
+	"\x03end\x18\x05 \x01(\x05R\x03end\"\xb8\x02\n" +
+	"\bMorpheme\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x12\n" +
+	"\x04form\x18\x02 \x01(\tR\x04form\x12\x14\n" +
+	"\x05lemma\x18\x03 \x01(\tR\x05lemma\x12\x12\n" +
+	"\x04cpos\x18\x04 \x01(\tR\x04cpos\x12\x10\n" +
+	"\x03pos\x18\x05 \x01(\tR\x03pos\x127\n" +
+	"\bfeatures\x18\x06 \x03(\v2\x1b.yap.Morpheme.FeaturesEntryR\bfeatures\x12\x12\n" +
+	"\x04head\x18\a \x01(\x05R\x04head\x12\x1a\n" +
+	"\brelation\x18\b \x01(\tR\brelation\x12\x14\n" +
+	"\x05start\x18\t \x01(\x05R\x05start\x12\x10\n" +
+	"\x03end\x18\n" +
+	" \x01(\x05R\x03end\x1a;\n" +
+	"\rFeaturesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"=\n" +
+	"\x10SentenceAnalyses\x12)\n" +
+	"\banalyses\x18\x01 \x03(\v2\r.yap.AnalysisR\banalyses\"n\n" +
+	"\bAnalysis\x12\x12\n" +
+	"\x04rank\x18\x01 \x01(\x05R\x04rank\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x01R\x05score\x12\x1d\n" +
+	"\n" +
+	"md_lattice\x18\x03 \x01(\tR\tmdLattice\x12\x19\n" +
+	"\bdep_tree\x18\x04 \x01(\tR\adepTree2\xb1\x03\n" +
+	"\x03Yap\x12*\n" +
+	"\aAnalyze\x12\x10.yap.TextRequest\x1a\r.yap.Response\x122\n" +
+	"\fDisambiguate\x12\x13.yap.LatticeRequest\x1a\r.yap.Response\x12.\n" +
+	"\bDepParse\x12\x13.yap.LatticeRequest\x1a\r.yap.Response\x12+\n" +
+	"\bPipeline\x12\x10.yap.TextRequest\x1a\r.yap.Response\x12(\n" +
+	"\x05Joint\x12\x10.yap.TextRequest\x1a\r.yap.Response\x12@\n" +
+	"\rAnalyzeStream\x12\x14.yap.SentenceRequest\x1a\x15.yap.SentenceResponse(\x010\x01\x12A\n" +
+	"\x0ePipelineStream\x12\x14.yap.SentenceRequest\x1a\x15.yap.SentenceResponse(\x010\x01\x12>\n" +
+	"\vJointStream\x12\x14.yap.SentenceRequest\x1a\x15.yap.SentenceResponse(\x010\x01B\x12Z\x10yap/webapi/yappbb\x06proto3"
+
+var (
+	file_yap_proto_rawDescOnce sync.Once
+	file_yap_proto_rawDescData []byte
+)
+
+func file_yap_proto_rawDescGZIP() []byte {
+	file_yap_proto_rawDescOnce.Do(func() {
+		file_yap_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_yap_proto_rawDesc), len(file_yap_proto_rawDesc)))
+	})
+	return file_yap_proto_rawDescData
+}
+
+var file_yap_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_yap_proto_goTypes = []any{
+	(*Options)(nil),          // 0: yap.Options
+	(*TextRequest)(nil),      // 1: yap.TextRequest
+	(*LatticeRequest)(nil),   // 2: yap.LatticeRequest
+	(*Response)(nil),         // 3: yap.Response
+	(*SentenceRequest)(nil),  // 4: yap.SentenceRequest
+	(*SentenceResponse)(nil), // 5: yap.SentenceResponse
+	(*Error)(nil),            // 6: yap.Error
+	(*Sentence)(nil),         // 7: yap.Sentence
+	(*Token)(nil),            // 8: yap.Token
+	(*Morpheme)(nil),         // 9: yap.Morpheme
+	(*SentenceAnalyses)(nil), // 10: yap.SentenceAnalyses
+	(*Analysis)(nil),         // 11: yap.Analysis
+	nil,                      // 12: yap.Morpheme.FeaturesEntry
+}
+var file_yap_proto_depIdxs = []int32{
+	0,  // 0: yap.TextRequest.options:type_name -> yap.Options
+	0,  // 1: yap.LatticeRequest.options:type_name -> yap.Options
+	7,  // 2: yap.Response.sentences:type_name -> yap.Sentence
+	10, // 3: yap.Response.kbest:type_name -> yap.SentenceAnalyses
+	0,  // 4: yap.SentenceRequest.options:type_name -> yap.Options
+	3,  // 5: yap.SentenceResponse.response:type_name -> yap.Response
+	6,  // 6: yap.SentenceResponse.error:type_name -> yap.Error
+	8,  // 7: yap.Sentence.tokens:type_name -> yap.Token
+	9,  // 8: yap.Token.morphemes:type_name -> yap.Morpheme
+	12, // 9: yap.Morpheme.features:type_name -> yap.Morpheme.FeaturesEntry
+	11, // 10: yap.SentenceAnalyses.analyses:type_name -> yap.Analysis
+	1,  // 11: yap.Yap.Analyze:input_type -> yap.TextRequest
+	2,  // 12: yap.Yap.Disambiguate:input_type -> yap.LatticeRequest
+	2,  // 13: yap.Yap.DepParse:input_type -> yap.LatticeRequest
+	1,  // 14: yap.Yap.Pipeline:input_type -> yap.TextRequest
+	1,  // 15: yap.Yap.Joint:input_type -> yap.TextRequest
+	4,  // 16: yap.Yap.AnalyzeStream:input_type -> yap.SentenceRequest
+	4,  // 17: yap.Yap.PipelineStream:input_type -> yap.SentenceRequest
+	4,  // 18: yap.Yap.JointStream:input_type -> yap.SentenceRequest
+	3,  // 19: yap.Yap.Analyze:output_type -> yap.Response
+	3,  // 20: yap.Yap.Disambiguate:output_type -> yap.Response
+	3,  // 21: yap.Yap.DepParse:output_type -> yap.Response
+	3,  // 22: yap.Yap.Pipeline:output_type -> yap.Response
+	3,  // 23: yap.Yap.Joint:output_type -> yap.Response
+	5,  // 24: yap.Yap.AnalyzeStream:output_type -> yap.SentenceResponse
+	5,  // 25: yap.Yap.PipelineStream:output_type -> yap.SentenceResponse
+	5,  // 26: yap.Yap.JointStream:output_type -> yap.SentenceResponse
+	19, // [19:27] is the sub-list for method output_type
+	11, // [11:19] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_yap_proto_init() }
+func file_yap_proto_init() {
+	if File_yap_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_yap_proto_rawDesc), len(file_yap_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_yap_proto_goTypes,
+		DependencyIndexes: file_yap_proto_depIdxs,
+		MessageInfos:      file_yap_proto_msgTypes,
+	}.Build()
+	File_yap_proto = out.File
+	file_yap_proto_goTypes = nil
+	file_yap_proto_depIdxs = nil
+}
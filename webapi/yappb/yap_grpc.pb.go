@@ -0,0 +1,394 @@
+//go:build grpc
+// +build grpc
+
+// gRPC interface to the yap API server, mirroring the REST endpoints
+// (see webapi/grpc.go; build with -tags grpc)
+//
+// Regenerate the Go code with:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative yap.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: yap.proto
+
+package yappb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Yap_Analyze_FullMethodName        = "/yap.Yap/Analyze"
+	Yap_Disambiguate_FullMethodName   = "/yap.Yap/Disambiguate"
+	Yap_DepParse_FullMethodName       = "/yap.Yap/DepParse"
+	Yap_Pipeline_FullMethodName       = "/yap.Yap/Pipeline"
+	Yap_Joint_FullMethodName          = "/yap.Yap/Joint"
+	Yap_AnalyzeStream_FullMethodName  = "/yap.Yap/AnalyzeStream"
+	Yap_PipelineStream_FullMethodName = "/yap.Yap/PipelineStream"
+	Yap_JointStream_FullMethodName    = "/yap.Yap/JointStream"
+)
+
+// YapClient is the client API for Yap service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type YapClient interface {
+	// Morphological analysis of raw text (tokens separated by white space)
+	Analyze(ctx context.Context, in *TextRequest, opts ...grpc.CallOption) (*Response, error)
+	// Morphological disambiguation of an ambiguous lattice
+	Disambiguate(ctx context.Context, in *LatticeRequest, opts ...grpc.CallOption) (*Response, error)
+	// Dependency parsing of a disambiguated lattice
+	DepParse(ctx context.Context, in *LatticeRequest, opts ...grpc.CallOption) (*Response, error)
+	// MA, MD and dependency parsing in sequence
+	Pipeline(ctx context.Context, in *TextRequest, opts ...grpc.CallOption) (*Response, error)
+	// MA followed by joint morpho-syntactic parsing
+	Joint(ctx context.Context, in *TextRequest, opts ...grpc.CallOption) (*Response, error)
+	// Sentence streaming: a response is sent for each request sentence, in
+	// order; a failing sentence is reported in its response's error
+	AnalyzeStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SentenceRequest, SentenceResponse], error)
+	PipelineStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SentenceRequest, SentenceResponse], error)
+	JointStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SentenceRequest, SentenceResponse], error)
+}
+
+type yapClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewYapClient(cc grpc.ClientConnInterface) YapClient {
+	return &yapClient{cc}
+}
+
+func (c *yapClient) Analyze(ctx context.Context, in *TextRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Yap_Analyze_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yapClient) Disambiguate(ctx context.Context, in *LatticeRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Yap_Disambiguate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yapClient) DepParse(ctx context.Context, in *LatticeRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Yap_DepParse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yapClient) Pipeline(ctx context.Context, in *TextRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Yap_Pipeline_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yapClient) Joint(ctx context.Context, in *TextRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Yap_Joint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yapClient) AnalyzeStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SentenceRequest, SentenceResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Yap_ServiceDesc.Streams[0], Yap_AnalyzeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SentenceRequest, SentenceResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Yap_AnalyzeStreamClient = grpc.BidiStreamingClient[SentenceRequest, SentenceResponse]
+
+func (c *yapClient) PipelineStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SentenceRequest, SentenceResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Yap_ServiceDesc.Streams[1], Yap_PipelineStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SentenceRequest, SentenceResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Yap_PipelineStreamClient = grpc.BidiStreamingClient[SentenceRequest, SentenceResponse]
+
+func (c *yapClient) JointStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SentenceRequest, SentenceResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Yap_ServiceDesc.Streams[2], Yap_JointStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SentenceRequest, SentenceResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Yap_JointStreamClient = grpc.BidiStreamingClient[SentenceRequest, SentenceResponse]
+
+// YapServer is the server API for Yap service.
+// All implementations must embed UnimplementedYapServer
+// for forward compatibility.
+type YapServer interface {
+	// Morphological analysis of raw text (tokens separated by white space)
+	Analyze(context.Context, *TextRequest) (*Response, error)
+	// Morphological disambiguation of an ambiguous lattice
+	Disambiguate(context.Context, *LatticeRequest) (*Response, error)
+	// Dependency parsing of a disambiguated lattice
+	DepParse(context.Context, *LatticeRequest) (*Response, error)
+	// MA, MD and dependency parsing in sequence
+	Pipeline(context.Context, *TextRequest) (*Response, error)
+	// MA followed by joint morpho-syntactic parsing
+	Joint(context.Context, *TextRequest) (*Response, error)
+	// Sentence streaming: a response is sent for each request sentence, in
+	// order; a failing sentence is reported in its response's error
+	AnalyzeStream(grpc.BidiStreamingServer[SentenceRequest, SentenceResponse]) error
+	PipelineStream(grpc.BidiStreamingServer[SentenceRequest, SentenceResponse]) error
+	JointStream(grpc.BidiStreamingServer[SentenceRequest, SentenceResponse]) error
+	mustEmbedUnimplementedYapServer()
+}
+
+// UnimplementedYapServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedYapServer struct{}
+
+func (UnimplementedYapServer) Analyze(context.Context, *TextRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Analyze not implemented")
+}
+func (UnimplementedYapServer) Disambiguate(context.Context, *LatticeRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Disambiguate not implemented")
+}
+func (UnimplementedYapServer) DepParse(context.Context, *LatticeRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DepParse not implemented")
+}
+func (UnimplementedYapServer) Pipeline(context.Context, *TextRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Pipeline not implemented")
+}
+func (UnimplementedYapServer) Joint(context.Context, *TextRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Joint not implemented")
+}
+func (UnimplementedYapServer) AnalyzeStream(grpc.BidiStreamingServer[SentenceRequest, SentenceResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method AnalyzeStream not implemented")
+}
+func (UnimplementedYapServer) PipelineStream(grpc.BidiStreamingServer[SentenceRequest, SentenceResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method PipelineStream not implemented")
+}
+func (UnimplementedYapServer) JointStream(grpc.BidiStreamingServer[SentenceRequest, SentenceResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method JointStream not implemented")
+}
+func (UnimplementedYapServer) mustEmbedUnimplementedYapServer() {}
+func (UnimplementedYapServer) testEmbeddedByValue()             {}
+
+// UnsafeYapServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to YapServer will
+// result in compilation errors.
+type UnsafeYapServer interface {
+	mustEmbedUnimplementedYapServer()
+}
+
+func RegisterYapServer(s grpc.ServiceRegistrar, srv YapServer) {
+	// If the following call pancis, it indicates UnimplementedYapServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Yap_ServiceDesc, srv)
+}
+
+func _Yap_Analyze_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YapServer).Analyze(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yap_Analyze_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YapServer).Analyze(ctx, req.(*TextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yap_Disambiguate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LatticeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YapServer).Disambiguate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yap_Disambiguate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YapServer).Disambiguate(ctx, req.(*LatticeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yap_DepParse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LatticeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YapServer).DepParse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yap_DepParse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YapServer).DepParse(ctx, req.(*LatticeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yap_Pipeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YapServer).Pipeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yap_Pipeline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YapServer).Pipeline(ctx, req.(*TextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yap_Joint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YapServer).Joint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yap_Joint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YapServer).Joint(ctx, req.(*TextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yap_AnalyzeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(YapServer).AnalyzeStream(&grpc.GenericServerStream[SentenceRequest, SentenceResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Yap_AnalyzeStreamServer = grpc.BidiStreamingServer[SentenceRequest, SentenceResponse]
+
+func _Yap_PipelineStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(YapServer).PipelineStream(&grpc.GenericServerStream[SentenceRequest, SentenceResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Yap_PipelineStreamServer = grpc.BidiStreamingServer[SentenceRequest, SentenceResponse]
+
+func _Yap_JointStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(YapServer).JointStream(&grpc.GenericServerStream[SentenceRequest, SentenceResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Yap_JointStreamServer = grpc.BidiStreamingServer[SentenceRequest, SentenceResponse]
+
+// Yap_ServiceDesc is the grpc.ServiceDesc for Yap service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Yap_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "yap.Yap",
+	HandlerType: (*YapServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Analyze",
+			Handler:    _Yap_Analyze_Handler,
+		},
+		{
+			MethodName: "Disambiguate",
+			Handler:    _Yap_Disambiguate_Handler,
+		},
+		{
+			MethodName: "DepParse",
+			Handler:    _Yap_DepParse_Handler,
+		},
+		{
+			MethodName: "Pipeline",
+			Handler:    _Yap_Pipeline_Handler,
+		},
+		{
+			MethodName: "Joint",
+			Handler:    _Yap_Joint_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AnalyzeStream",
+			Handler:       _Yap_AnalyzeStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "PipelineStream",
+			Handler:       _Yap_PipelineStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "JointStream",
+			Handler:       _Yap_JointStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "yap.proto",
+}